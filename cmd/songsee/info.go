@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/alecthomas/kong"
+	"github.com/steipete/songsee/internal/audio"
+)
+
+type infoCLI struct {
+	Input      string           `arg:"" help:"audio file path"`
+	JSON       bool             `name:"json" help:"print JSON instead of text"`
+	FFmpegPath string           `name:"ffmpeg" help:"path to ffmpeg binary (ffprobe is looked up next to it)"`
+	Timeout    time.Duration    `name:"timeout" help:"abort probing after this long, e.g. 10s (0 = no limit)"`
+	Version    kong.VersionFlag `name:"version" help:"print version"`
+}
+
+// infoReport is the JSON shape of songsee info.
+type infoReport struct {
+	File string `json:"file"`
+	audio.Info
+}
+
+func runInfo(args []string, stdout, stderr io.Writer) int {
+	cfg := infoCLI{}
+	ctx, code := parseArgs(&cfg, args, stdout, stderr,
+		kong.Name("songsee info"),
+		kong.Description("print audio format, duration and tags"),
+	)
+	if code >= 0 {
+		return code
+	}
+	if cfg.Input == "-" {
+		return dieUsage(stderr, ctx, "info needs a file path, not stdin")
+	}
+	if cfg.Timeout < 0 {
+		return dieUsage(stderr, ctx, "--timeout must be >= 0")
+	}
+
+	probeCtx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		probeCtx, cancel = context.WithTimeout(probeCtx, cfg.Timeout)
+		defer cancel()
+	}
+	info, err := audio.Probe(probeCtx, cfg.Input, audio.Options{FFmpegPath: cfg.FFmpegPath})
+	if err != nil {
+		return die(stderr, err)
+	}
+
+	if cfg.JSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(infoReport{File: cfg.Input, Info: info}); err != nil {
+			return die(stderr, err)
+		}
+		return 0
+	}
+
+	lines := [][2]string{
+		{"file", cfg.Input},
+		{"container", info.Container},
+		{"codec", info.Codec},
+		{"sample rate", fmt.Sprintf("%d Hz", info.SampleRate)},
+		{"channels", strconv.Itoa(info.Channels)},
+	}
+	if info.BitDepth > 0 {
+		lines = append(lines, [2]string{"bit depth", strconv.Itoa(info.BitDepth)})
+	}
+	lines = append(lines, [2]string{"duration", formatDuration(info.Duration)})
+	if info.Bitrate > 0 {
+		lines = append(lines, [2]string{"bitrate", fmt.Sprintf("%d kbps", (info.Bitrate+500)/1000)})
+	}
+	if info.Origin != nil {
+		lines = append(lines, [2]string{"origin", info.Origin.Format("2006-01-02T15:04:05.000")})
+	}
+	lines = append(lines, [2]string{"probe", info.Prober})
+	keys := make([]string, 0, len(info.Tags))
+	for key := range info.Tags {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		lines = append(lines, [2]string{"tag " + key, info.Tags[key]})
+	}
+
+	width := 0
+	for _, line := range lines {
+		width = max(width, len(line[0]))
+	}
+	for _, line := range lines {
+		_, _ = fmt.Fprintf(stdout, "%-*s  %s\n", width+1, line[0]+":", line[1])
+	}
+	return 0
+}
+
+// formatDuration prints seconds as m:ss.mmm, or "unknown" for 0.
+func formatDuration(sec float64) string {
+	if sec <= 0 {
+		return "unknown"
+	}
+	ms := int64(sec*1000 + 0.5)
+	return fmt.Sprintf("%d:%02d.%03d (%gs)", ms/60000, ms/1000%60, ms%1000, sec)
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"image"
@@ -13,9 +14,11 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/steipete/songsee/internal/audio"
+	"github.com/steipete/songsee/internal/dsp"
 	"github.com/steipete/songsee/internal/render"
 	"github.com/steipete/songsee/internal/viz"
 )
@@ -30,14 +33,37 @@ type cli struct {
 	Height     int              `help:"output height in pixels" default:"1080"`
 	WindowSize int              `name:"window" help:"FFT window size in samples" default:"2048"`
 	HopSize    int              `name:"hop" help:"hop size in samples" default:"512"`
+	FFTSize    int              `name:"fft-size" help:"FFT size in samples, zero-padding each window (0 = window size)"`
+	Pad        int              `name:"pad" help:"zero-padding factor: FFT size = window x pad (0 = no padding)"`
+	WindowFn   string           `name:"window-fn" help:"analysis window: hann, hamming, blackman-harris, flattop, rectangular, kaiser[:beta], gaussian[:sigma], tukey[:alpha]" default:"hann"`
 	MinFreq    float64          `name:"min-freq" help:"minimum frequency in Hz"`
 	MaxFreq    float64          `name:"max-freq" help:"maximum frequency in Hz (0 = Nyquist)"`
 	StartSec   float64          `name:"start" help:"start time in seconds"`
 	Duration   float64          `name:"duration" help:"duration in seconds (0 = full)"`
-	SampleRate int              `name:"sample-rate" help:"ffmpeg output sample rate" default:"44100"`
+	SampleRate int              `name:"sample-rate" help:"ffmpeg output sample rate (all inputs with --resample)" default:"44100"`
+	Resample   bool             `name:"resample" help:"resample natively decoded input to --sample-rate"`
+	Channels   string           `name:"channels" help:"channel mode: mix, left, right, mid, side, or all (one panel row per channel)" default:"mix"`
 	Style      string           `help:"palette style: classic, magma, inferno, viridis, gray" default:"classic"`
-	Viz        []string         `name:"viz" help:"visualizations (repeatable or comma-separated): spectrogram, mel, chroma, hpss, selfsim, loudness, tempogram, mfcc, flux"`
+	Viz        []string         `name:"viz" help:"visualizations (repeatable or comma-separated): spectrogram, mel, chroma, hpss, selfsim, loudness, tempogram, mfcc, flux, cqt, reassigned"`
+	FreqScale  string           `name:"freq-scale" help:"spectrogram frequency axis: linear, log, mel, erb" default:"linear"`
+	MelBands   int              `name:"mel-bands" help:"mel filterbank bands" default:"40"`
+	MelScale   string           `name:"mel-scale" help:"mel scale: slaney or htk" default:"slaney"`
+	MelNorm    string           `name:"mel-norm" help:"mel filter normalization: slaney (unit area) or none" default:"slaney"`
+	MFCCCoeffs int              `name:"mfcc-coeffs" help:"MFCC coefficients" default:"13"`
+	MFCCOrtho  bool             `name:"mfcc-ortho" help:"use the orthonormal DCT for MFCCs"`
+	MFCCLifter int              `name:"mfcc-lifter" help:"MFCC cepstral liftering parameter (0 = off, 22 is common)"`
+	MFCCCMVN   string           `name:"mfcc-cmvn" help:"MFCC normalization over time: none, mean, meanvar" default:"none"`
+	MFCCDeltas int              `name:"mfcc-deltas" help:"append MFCC deltas (1) or deltas and delta-deltas (2)"`
+	Chroma     string           `name:"chroma" help:"chroma mode: stft, tuned (tuning-corrected, harmonic-weighted), cqt, cens" default:"stft"`
+	ChordLabel bool             `name:"chord-labels" help:"draw estimated chords over the chroma panel"`
+	Report     string           `name:"report" help:"write estimated key and chords as JSON to this path ('-' for stdout)"`
+	BinsPerOct int              `name:"bins-per-octave" help:"cqt bins per octave (12 = one per semitone)" default:"12"`
 	FFmpegPath string           `name:"ffmpeg" help:"path to ffmpeg binary"`
+	Timeout    time.Duration    `name:"timeout" help:"abort decoding after this long, e.g. 30s (0 = no limit)"`
+	MaxDur     float64          `name:"max-duration" help:"fail when decoded audio is longer than this many seconds (0 = no limit)"`
+	Threads    int              `name:"threads" help:"spectrogram worker threads (0 = GOMAXPROCS)"`
+	Export     bool             `name:"export-audio" help:"also write the analyzed audio as WAV next to the image"`
+	ExportEnc  string           `name:"export-encoding" help:"WAV encoding for --export-audio: pcm16, pcm24, float32" default:"pcm16"`
 	Quiet      bool             `short:"q" help:"suppress stdout output"`
 	Verbose    bool             `short:"v" help:"verbose stderr output"`
 	Version    kong.VersionFlag `name:"version" help:"print version"`
@@ -52,49 +78,18 @@ func main() {
 }
 
 func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "info" {
+		return runInfo(args[1:], stdout, stderr)
+	}
+
 	formatSet := hasFlag(args, "--format")
 	cfg := cli{}
-	exitCode := -1
-
-	parser, err := kong.New(&cfg,
+	ctx, code := parseArgs(&cfg, args, stdout, stderr,
 		kong.Name("songsee"),
-		kong.Description("generate spectral visualizations"),
-		kong.Vars{"version": version},
-		kong.Writers(stdout, stderr),
-		kong.Exit(func(code int) { panic(exitPanic{code: code}) }),
+		kong.Description("generate spectral visualizations (see also: songsee info <file>)"),
 	)
-	if err != nil {
-		_, _ = fmt.Fprintln(stderr, "songsee:", err)
-		return 1
-	}
-
-	var ctx *kong.Context
-	func() {
-		defer func() {
-			if recovered := recover(); recovered != nil {
-				if exit, ok := recovered.(exitPanic); ok {
-					exitCode = exit.code
-					return
-				}
-				panic(recovered)
-			}
-		}()
-		ctx, err = parser.Parse(args)
-	}()
-	if exitCode >= 0 {
-		return exitCode
-	}
-	if err != nil {
-		if parseErr, ok := err.(*kong.ParseError); ok {
-			_, _ = fmt.Fprintln(stderr, "songsee:", parseErr)
-			if parseErr.Context != nil {
-				parseErr.Context.Stdout = stderr
-				_ = parseErr.Context.PrintUsage(false)
-			}
-			return 2
-		}
-		_, _ = fmt.Fprintln(stderr, "songsee:", err)
-		return 1
+	if code >= 0 {
+		return code
 	}
 
 	input := cfg.Input
@@ -115,12 +110,78 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	if cfg.WindowSize <= 0 || cfg.HopSize <= 0 {
 		return dieUsage(stderr, ctx, "--window and --hop must be > 0")
 	}
-	if !isPowerOfTwo(cfg.WindowSize) {
-		return dieUsage(stderr, ctx, "--window must be a power of two")
+	if cfg.FFTSize < 0 || cfg.Pad < 0 {
+		return dieUsage(stderr, ctx, "--fft-size and --pad must be >= 0")
+	}
+	if cfg.FFTSize > 0 && cfg.Pad > 0 {
+		return dieUsage(stderr, ctx, "use either --fft-size or --pad")
+	}
+	fftSize := cfg.FFTSize
+	if cfg.Pad > 0 {
+		fftSize = cfg.WindowSize * cfg.Pad
+	}
+	if fftSize > 0 && fftSize < cfg.WindowSize {
+		return dieUsage(stderr, ctx, "--fft-size must be >= --window")
 	}
 	if cfg.StartSec < 0 || cfg.Duration < 0 {
 		return dieUsage(stderr, ctx, "--start and --duration must be >= 0")
 	}
+	if cfg.Timeout < 0 || cfg.MaxDur < 0 {
+		return dieUsage(stderr, ctx, "--timeout and --max-duration must be >= 0")
+	}
+	if cfg.BinsPerOct <= 0 {
+		return dieUsage(stderr, ctx, "--bins-per-octave must be > 0")
+	}
+	if cfg.MelBands <= 0 {
+		return dieUsage(stderr, ctx, "--mel-bands must be > 0")
+	}
+	if cfg.MFCCCoeffs <= 0 || cfg.MFCCCoeffs > cfg.MelBands {
+		return dieUsage(stderr, ctx, "--mfcc-coeffs must be between 1 and --mel-bands")
+	}
+	if cfg.MFCCLifter < 0 || cfg.MFCCDeltas < 0 || cfg.MFCCDeltas > 2 {
+		return dieUsage(stderr, ctx, "--mfcc-lifter must be >= 0 and --mfcc-deltas 0, 1 or 2")
+	}
+	if cfg.Threads < 0 {
+		return dieUsage(stderr, ctx, "--threads must be >= 0")
+	}
+
+	if cfg.Resample && cfg.SampleRate <= 0 {
+		return dieUsage(stderr, ctx, "--sample-rate must be > 0 with --resample")
+	}
+
+	window, err := dsp.ParseWindow(cfg.WindowFn)
+	if err != nil {
+		return dieUsage(stderr, ctx, err.Error())
+	}
+	freqScale, err := render.ParseFreqScale(cfg.FreqScale)
+	if err != nil {
+		return dieUsage(stderr, ctx, err.Error())
+	}
+	melOpts := dsp.MelOptions{Bands: cfg.MelBands}
+	if melOpts.Scale, err = dsp.ParseMelScale(cfg.MelScale); err != nil {
+		return dieUsage(stderr, ctx, err.Error())
+	}
+	if melOpts.Norm, err = dsp.ParseMelNorm(cfg.MelNorm); err != nil {
+		return dieUsage(stderr, ctx, err.Error())
+	}
+	mfccOpts := dsp.MFCCOptions{Coeffs: cfg.MFCCCoeffs, Ortho: cfg.MFCCOrtho, Lifter: cfg.MFCCLifter, Deltas: cfg.MFCCDeltas}
+	if mfccOpts.CMVN, err = dsp.ParseCMVN(cfg.MFCCCMVN); err != nil {
+		return dieUsage(stderr, ctx, err.Error())
+	}
+	chromaMode, err := viz.ParseChromaMode(cfg.Chroma)
+	if err != nil {
+		return dieUsage(stderr, ctx, err.Error())
+	}
+
+	channelMode, err := audio.ParseChannelMode(cfg.Channels)
+	if err != nil {
+		return dieUsage(stderr, ctx, err.Error())
+	}
+
+	exportEnc, err := audio.ParseWAVEncoding(cfg.ExportEnc)
+	if err != nil {
+		return dieUsage(stderr, ctx, err.Error())
+	}
 
 	format := strings.ToLower(cfg.Format)
 	if format != "jpg" && format != "jpeg" && format != "png" {
@@ -153,17 +214,44 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 		}
 	}
 
+	exportPath := ""
+	if cfg.Export {
+		if output == "-" {
+			return dieUsage(stderr, ctx, "--export-audio needs a file --output")
+		}
+		exportPath = strings.TrimSuffix(output, filepath.Ext(output)) + ".wav"
+		if input != "-" && sameFile(exportPath, input) {
+			return dieUsage(stderr, ctx, fmt.Sprintf("--export-audio would overwrite the input %s; choose another --output", input))
+		}
+	}
+
+	if cfg.Report == "-" && output == "-" {
+		return dieUsage(stderr, ctx, "--report - needs a file --output")
+	}
+
 	if cfg.Verbose {
 		_, _ = fmt.Fprintf(stderr, "input: %s\n", input)
 		_, _ = fmt.Fprintf(stderr, "output: %s (%s)\n", output, format)
 	}
 
-	opts := audio.Options{SampleRate: cfg.SampleRate, FFmpegPath: cfg.FFmpegPath}
+	opts := audio.Options{
+		SampleRate:  cfg.SampleRate,
+		FFmpegPath:  cfg.FFmpegPath,
+		Start:       cfg.StartSec,
+		Duration:    cfg.Duration,
+		MaxDuration: cfg.MaxDur,
+	}
+	decodeCtx := context.Background()
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		decodeCtx, cancel = context.WithTimeout(decodeCtx, cfg.Timeout)
+		defer cancel()
+	}
 	var pcm audio.Audio
 	if input == "-" {
-		pcm, err = audio.DecodeReader(stdin, opts)
+		pcm, err = audio.DecodeReader(decodeCtx, stdin, opts)
 	} else {
-		pcm, err = audio.DecodeFile(input, opts)
+		pcm, err = audio.DecodeFile(decodeCtx, input, opts)
 	}
 	if err != nil {
 		return die(stderr, err)
@@ -171,16 +259,44 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 	if len(pcm.Samples) == 0 {
 		return die(stderr, errors.New("no samples decoded"))
 	}
+	// The BWF timeline origin uses the file's own rate, so take it before
+	// any resampling.
+	var origin *time.Time
+	if bwf := pcm.Metadata.BWF; bwf != nil {
+		if t := bwf.Origin(pcm.SampleRate); !t.IsZero() {
+			origin = &t
+		}
+	}
 	if cfg.Verbose {
-		_, _ = fmt.Fprintf(stderr, "decoded: %d samples @ %d Hz\n", len(pcm.Samples), pcm.SampleRate)
+		_, _ = fmt.Fprintf(stderr, "decoded: %d samples @ %d Hz, %d channels\n", len(pcm.Samples), pcm.SampleRate, pcm.NumChannels())
+		if info := pcm.Metadata.MP3; info != nil {
+			mode := "CBR"
+			if info.VBR {
+				mode = "VBR"
+			}
+			_, _ = fmt.Fprintf(stderr, "mp3: MPEG-%s layer %d, %s, %d kbps %s, delay %d, padding %d\n",
+				info.Version, info.Layer, info.ChannelMode, pcm.Metadata.Bitrate/1000, mode, info.EncoderDelay, info.EncoderPadding)
+		}
+		if bwf := pcm.Metadata.BWF; bwf != nil {
+			// The first rendered sample sits --start seconds past the file origin.
+			first := time.Time{}
+			if origin != nil {
+				first = origin.Add(time.Duration(cfg.StartSec * float64(time.Second)))
+			}
+			_, _ = fmt.Fprintf(stderr, "bwf: origin %s, originator %q\n", first.Format("2006-01-02T15:04:05.000"), bwf.Originator)
+		}
 	}
-	if cfg.StartSec > 0 || cfg.Duration > 0 {
-		pcm, err = audio.Slice(pcm, cfg.StartSec, cfg.Duration)
+	if cfg.Verbose && (cfg.StartSec > 0 || cfg.Duration > 0) {
+		_, _ = fmt.Fprintf(stderr, "slice: %0.2fs + %0.2fs => %d samples\n", cfg.StartSec, cfg.Duration, len(pcm.Samples))
+	}
+	if cfg.Resample && pcm.SampleRate != cfg.SampleRate {
+		from := pcm.SampleRate
+		pcm, err = audio.Resample(pcm, cfg.SampleRate)
 		if err != nil {
 			return die(stderr, err)
 		}
 		if cfg.Verbose {
-			_, _ = fmt.Fprintf(stderr, "slice: %0.2fs + %0.2fs => %d samples\n", cfg.StartSec, cfg.Duration, len(pcm.Samples))
+			_, _ = fmt.Fprintf(stderr, "resample: %d Hz -> %d Hz (%d samples)\n", from, pcm.SampleRate, len(pcm.Samples))
 		}
 	}
 
@@ -195,27 +311,74 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 		return dieUsage(stderr, ctx, err.Error())
 	}
 
-	layout, err := gridLayout(len(vizList), cfg.Width, cfg.Height, 8)
+	// Several signals get one row each, with the same panels in every row.
+	signals := audio.Signals(pcm, channelMode)
+	var layout grid
+	if len(signals) == 1 {
+		layout, err = gridLayout(len(vizList), cfg.Width, cfg.Height, 8)
+	} else {
+		layout, err = gridShape(len(vizList), len(signals), cfg.Width, cfg.Height, 8)
+	}
 	if err != nil {
 		return dieUsage(stderr, ctx, err.Error())
 	}
+	if cfg.Verbose {
+		names := make([]string, len(signals))
+		for i, signal := range signals {
+			names[i] = signal.Name
+		}
+		_, _ = fmt.Fprintf(stderr, "channels: %s\n", strings.Join(names, ", "))
+		calib := dsp.NewWindow(window, cfg.WindowSize)
+		_, _ = fmt.Fprintf(stderr, "window: %s (coherent gain %.4f, ENBW %.3f bins)\n", window, calib.CoherentGain, calib.ENBW)
+		if fftSize > cfg.WindowSize {
+			_, _ = fmt.Fprintf(stderr, "fft: %d samples (%.2f Hz bins)\n", fftSize, float64(pcm.SampleRate)/float64(fftSize))
+		}
+	}
 
-	ctxViz := viz.NewContext(pcm.Samples, pcm.SampleRate, cfg.WindowSize, cfg.HopSize)
-	panels := make([]render.Panel, 0, len(vizList))
-	for i, kind := range vizList {
-		panel, err := viz.Render(kind, ctxViz, viz.RenderOptions{
-			Width:   layout.CellWidth,
-			Height:  layout.CellHeight,
-			Palette: palette,
-			MinFreq: cfg.MinFreq,
-			MaxFreq: cfg.MaxFreq,
+	panels := make([]render.Panel, 0, len(vizList)*len(signals))
+	report := analysisReport{File: input, SampleRate: pcm.SampleRate, Origin: origin, Offset: cfg.StartSec, Chroma: string(chromaMode)}
+	for _, signal := range signals {
+		ctxViz := viz.NewContextWith(signal.Samples, pcm.SampleRate, dsp.SpectrogramOptions{
+			WindowSize: cfg.WindowSize,
+			HopSize:    cfg.HopSize,
+			FFTSize:    fftSize,
+			Window:     window,
+			Threads:    cfg.Threads,
 		})
-		if err != nil {
-			return die(stderr, err)
+		if cfg.Verbose && chromaMode != viz.ChromaSTFT {
+			tuning := ctxViz.Tuning()
+			_, _ = fmt.Fprintf(stderr, "chroma: %s, tuning %+.2f semitones (A4 = %.1f Hz)\n", chromaMode, tuning, dsp.MIDIToHz(69+tuning))
+		}
+		for _, kind := range vizList {
+			panel, err := viz.Render(kind, ctxViz, viz.RenderOptions{
+				Width:         layout.CellWidth,
+				Height:        layout.CellHeight,
+				Palette:       palette,
+				MinFreq:       cfg.MinFreq,
+				MaxFreq:       cfg.MaxFreq,
+				BinsPerOctave: cfg.BinsPerOct,
+				FreqScale:     freqScale,
+				Mel:           melOpts,
+				MFCC:          mfccOpts,
+				ChromaMode:    chromaMode,
+				ChordLabels:   cfg.ChordLabel,
+			})
+			if err != nil {
+				return die(stderr, err)
+			}
+			i := len(panels)
+			x := (i % layout.Cols) * (layout.CellWidth + layout.Gap)
+			y := (i / layout.Cols) * (layout.CellHeight + layout.Gap)
+			panels = append(panels, render.Panel{Image: panel, X: x, Y: y})
+		}
+		if cfg.Report != "" {
+			signalReport := newSignalReport(signal.Name, ctxViz, chromaMode, cfg.StartSec)
+			if cfg.Verbose {
+				_, _ = fmt.Fprintf(stderr, "%s: key %s (score %.2f), %d chord segments\n",
+					signal.Name, signalReport.Key, signalReport.KeyScore, len(signalReport.Chords))
+			}
+			report.Signals = append(report.Signals, signalReport)
 		}
-		x := (i % layout.Cols) * (layout.CellWidth + layout.Gap)
-		y := (i / layout.Cols) * (layout.CellHeight + layout.Gap)
-		panels = append(panels, render.Panel{Image: panel, X: x, Y: y})
 	}
 	img, err := render.Compose(layout.Width, layout.Height, panels, color.RGBA{0, 0, 0, 255})
 	if err != nil {
@@ -226,12 +389,82 @@ func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
 		return die(stderr, err)
 	}
 
-	if output != "-" && !cfg.Quiet {
+	if exportPath != "" {
+		// One WAV channel per analyzed signal, after slicing and resampling.
+		channels := make([][]float64, len(signals))
+		for i, signal := range signals {
+			channels[i] = signal.Samples
+		}
+		if err := audio.WriteWAVFile(exportPath, audio.NewAudio(pcm.SampleRate, channels), exportEnc); err != nil {
+			return die(stderr, err)
+		}
+		if cfg.Verbose {
+			_, _ = fmt.Fprintf(stderr, "export: %s (%d channels, %s)\n", exportPath, len(channels), exportEnc)
+		}
+	}
+
+	if cfg.Report != "" {
+		if err := writeReport(cfg.Report, report, stdout); err != nil {
+			return die(stderr, err)
+		}
+	}
+
+	// With --report - stdout carries only the JSON.
+	if output != "-" && cfg.Report != "-" && !cfg.Quiet {
 		_, _ = fmt.Fprintln(stdout, output)
+		if exportPath != "" {
+			_, _ = fmt.Fprintln(stdout, exportPath)
+		}
 	}
 	return 0
 }
 
+// parseArgs parses args into cfg. It returns an exit code >= 0 when the
+// command should stop (help, version, or a parse error).
+func parseArgs(cfg any, args []string, stdout, stderr io.Writer, options ...kong.Option) (*kong.Context, int) {
+	exitCode := -1
+	options = append(options,
+		kong.Vars{"version": version},
+		kong.Writers(stdout, stderr),
+		kong.Exit(func(code int) { panic(exitPanic{code: code}) }),
+	)
+	parser, err := kong.New(cfg, options...)
+	if err != nil {
+		_, _ = fmt.Fprintln(stderr, "songsee:", err)
+		return nil, 1
+	}
+
+	var ctx *kong.Context
+	func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				if exit, ok := recovered.(exitPanic); ok {
+					exitCode = exit.code
+					return
+				}
+				panic(recovered)
+			}
+		}()
+		ctx, err = parser.Parse(args)
+	}()
+	if exitCode >= 0 {
+		return nil, exitCode
+	}
+	if err != nil {
+		if parseErr, ok := err.(*kong.ParseError); ok {
+			_, _ = fmt.Fprintln(stderr, "songsee:", parseErr)
+			if parseErr.Context != nil {
+				parseErr.Context.Stdout = stderr
+				_ = parseErr.Context.PrintUsage(false)
+			}
+			return nil, 2
+		}
+		_, _ = fmt.Fprintln(stderr, "songsee:", err)
+		return nil, 1
+	}
+	return ctx, -1
+}
+
 type grid struct {
 	Cols       int
 	Rows       int
@@ -246,18 +479,22 @@ func gridLayout(count, width, height, gap int) (grid, error) {
 	if count <= 0 {
 		count = 1
 	}
-	if width <= 0 || height <= 0 {
-		return grid{}, fmt.Errorf("invalid output size")
-	}
 	cols := int(math.Ceil(math.Sqrt(float64(count))))
 	if cols < 1 {
 		cols = 1
 	}
 	rows := int(math.Ceil(float64(count) / float64(cols)))
+	return gridShape(cols, rows, width, height, gap)
+}
+
+func gridShape(cols, rows, width, height, gap int) (grid, error) {
+	if width <= 0 || height <= 0 {
+		return grid{}, fmt.Errorf("invalid output size")
+	}
 	cellWidth := (width - gap*(cols-1)) / cols
 	cellHeight := (height - gap*(rows-1)) / rows
 	if cellWidth <= 0 || cellHeight <= 0 {
-		return grid{}, fmt.Errorf("output too small for %d panels", count)
+		return grid{}, fmt.Errorf("output too small for %dx%d panels", cols, rows)
 	}
 	return grid{
 		Cols:       cols,
@@ -307,10 +544,6 @@ func dieUsage(stderr io.Writer, ctx *kong.Context, msg string) int {
 	return 2
 }
 
-func isPowerOfTwo(v int) bool {
-	return v > 0 && (v&(v-1)) == 0
-}
-
 func hasFlag(args []string, name string) bool {
 	for i := 0; i < len(args); i++ {
 		arg := args[i]
@@ -320,3 +553,16 @@ func hasFlag(args []string, name string) bool {
 	}
 	return false
 }
+
+// sameFile reports whether paths a and b name the same file, either as
+// the same absolute path or as links to one existing file.
+func sameFile(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	if errA == nil && errB == nil && absA == absB {
+		return true
+	}
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
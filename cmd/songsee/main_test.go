@@ -2,12 +2,17 @@ package main
 
 import (
 	"bytes"
+	"encoding/binary"
+	"encoding/json"
 	"image"
 	"image/png"
 	"math"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/steipete/songsee/internal/audio"
 )
 
 func TestRunMP3E2E(t *testing.T) {
@@ -106,15 +111,22 @@ func TestRunHelp(t *testing.T) {
 	}
 }
 
-func TestRunInvalidWindow(t *testing.T) {
-	stdout := &bytes.Buffer{}
-	stderr := &bytes.Buffer{}
-	exit := run([]string{"--window", "1000", "-"}, bytes.NewReader(nil), stdout, stderr)
-	if exit != 2 {
-		t.Fatalf("expected usage exit, got %d", exit)
-	}
-	if stderr.String() == "" {
-		t.Fatalf("expected stderr usage")
+func TestRunNonPowerOfTwoWindow(t *testing.T) {
+	wav := makeWAV(genSineMixSamples(8192), 44100, 1)
+	for _, window := range []string{"1764", "4410", "1009"} {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		exit := run([]string{"--window", window, "--hop", "441", "--width", "200", "--height", "100", "--format", "png", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+		if exit != 0 {
+			t.Fatalf("--window %s: exit %d stderr=%s", window, exit, stderr.String())
+		}
+		img, err := png.Decode(bytes.NewReader(stdout.Bytes()))
+		if err != nil {
+			t.Fatalf("--window %s: decode png: %v", window, err)
+		}
+		if flatImage(img) {
+			t.Fatalf("--window %s: image appears flat", window)
+		}
 	}
 }
 
@@ -249,6 +261,150 @@ func TestRunSliceError(t *testing.T) {
 	}
 }
 
+func TestRunResample(t *testing.T) {
+	wav := makeWAV(genSineMixSamples(48000), 48000, 1)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--verbose", "--resample", "--sample-rate", "22050", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("resample: 48000 Hz -> 22050 Hz (22050 samples)")) {
+		t.Fatalf("expected resample output, got %s", stderr.String())
+	}
+}
+
+func TestRunResampleBadRate(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--resample", "--sample-rate", "0", "-"}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 2 {
+		t.Fatalf("expected usage exit, got %d", exit)
+	}
+}
+
+func TestRunMaxDuration(t *testing.T) {
+	wav := makeWAV(make([]int16, 44100), 44100, 1)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--max-duration", "0.5", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 1 || !bytes.Contains(stderr.Bytes(), []byte("exceeds maximum duration of 0.5s")) {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	ffmpeg := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(ffmpeg, []byte("#!/bin/sh\nexec sleep 10\n"), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--timeout", "100ms", "--ffmpeg", ffmpeg, "--output", "-", "-"}, bytes.NewReader([]byte("not audio")), stdout, stderr)
+	if exit != 1 || !bytes.Contains(stderr.Bytes(), []byte("decode timed out")) {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+
+	exit = run([]string{"--timeout=-1s", "-"}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 2 {
+		t.Fatalf("expected usage exit, got %d", exit)
+	}
+}
+
+func TestRunInfoText(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"info", testdataPath(t, "sine.mp3")}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	for _, want := range []string{"codec:        mp3\n", "sample rate:  44100 Hz\n", "duration:     0:01.000 (1s)\n", "tag encoder:  Lavf62.3.100\n"} {
+		if !bytes.Contains(stdout.Bytes(), []byte(want)) {
+			t.Fatalf("missing %q in:\n%s", want, stdout.String())
+		}
+	}
+}
+
+func TestRunInfoJSON(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tone.wav")
+	if err := os.WriteFile(path, makeWAV(make([]int16, 2*8000), 8000, 2), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"info", "--json", path}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	var got struct {
+		File       string  `json:"file"`
+		Codec      string  `json:"codec"`
+		SampleRate int     `json:"sample_rate"`
+		Channels   int     `json:"channels"`
+		BitDepth   int     `json:"bit_depth"`
+		Duration   float64 `json:"duration"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+		t.Fatalf("unmarshal: %v\n%s", err, stdout.String())
+	}
+	if got.File != path || got.Codec != "pcm" || got.SampleRate != 8000 || got.Channels != 2 || got.BitDepth != 16 || got.Duration != 1 {
+		t.Fatalf("unexpected report: %+v", got)
+	}
+}
+
+func TestRunBWFOrigin(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "take.wav")
+	// Recorded at 10:00 with the first sample at 09:59:59.5.
+	wav := appendBext(makeWAV(make([]int16, 2*8000), 8000, 1), "2026-03-04", "10:00:00", (10*3600-1)*8000+4000)
+	if err := os.WriteFile(path, wav, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	const want = "2026-03-04T09:59:59.5Z"
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exit := run([]string{"info", path}, bytes.NewReader(nil), stdout, stderr); exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	if !bytes.Contains(stdout.Bytes(), []byte("origin:       2026-03-04T09:59:59.500\n")) {
+		t.Fatalf("missing origin in:\n%s", stdout.String())
+	}
+	stdout.Reset()
+	if exit := run([]string{"info", "--json", path}, bytes.NewReader(nil), stdout, stderr); exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	if !bytes.Contains(stdout.Bytes(), []byte(`"origin": "`+want+`"`)) {
+		t.Fatalf("missing origin in JSON:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	exit := run([]string{"--start", "0.5", "--report", "-", "--output", filepath.Join(dir, "take.jpg"), path}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	var report analysisReport
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Origin == nil || report.Origin.Format(time.RFC3339Nano) != want || report.Offset != 0.5 {
+		t.Fatalf("unexpected report origin: %s", stdout.String())
+	}
+}
+
+func TestRunInfoErrors(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	if exit := run([]string{"info", "-"}, bytes.NewReader(nil), stdout, stderr); exit != 2 {
+		t.Fatalf("expected usage exit for stdin, got %d", exit)
+	}
+	if exit := run([]string{"info", filepath.Join(t.TempDir(), "missing.wav")}, bytes.NewReader(nil), stdout, stderr); exit != 1 {
+		t.Fatalf("expected exit 1 for missing file, got %d", exit)
+	}
+	if exit := run([]string{"info"}, bytes.NewReader(nil), stdout, stderr); exit != 2 {
+		t.Fatalf("expected usage exit without input, got %d", exit)
+	}
+}
+
 func TestRunSliceVerbose(t *testing.T) {
 	samples := make([]int16, 44100)
 	wav := makeWAV(samples, 44100, 1)
@@ -307,6 +463,128 @@ func TestRunMultiVizLayout(t *testing.T) {
 	}
 }
 
+func TestRunChannelsAll(t *testing.T) {
+	mono := genSineMixSamples(22050)
+	stereo := make([]int16, 0, len(mono)*2)
+	for _, v := range mono {
+		stereo = append(stereo, v, v/4)
+	}
+	wav := makeWAV(stereo, 44100, 2)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{
+		"--verbose",
+		"--channels", "all",
+		"--viz", "spectrogram,loudness",
+		"--width", "400",
+		"--height", "200",
+		"--format", "png",
+		"--output", "-",
+		"-",
+	}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("channels: left, right")) {
+		t.Fatalf("expected channel list, got %s", stderr.String())
+	}
+	img, err := png.Decode(bytes.NewReader(stdout.Bytes()))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	if img.Bounds().Dx() != 400 || img.Bounds().Dy() != 200 {
+		t.Fatalf("size mismatch")
+	}
+}
+
+func TestRunExportAudio(t *testing.T) {
+	mono := genSineMixSamples(22050)
+	stereo := make([]int16, 0, len(mono)*2)
+	for _, v := range mono {
+		stereo = append(stereo, v, v/4)
+	}
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.wav")
+	if err := os.WriteFile(input, makeWAV(stereo, 44100, 2), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{
+		"--channels", "all",
+		"--start", "0.1",
+		"--duration", "0.2",
+		"--width", "200",
+		"--height", "100",
+		"--export-audio",
+		"--output", filepath.Join(dir, "out.png"),
+		input,
+	}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	exported := filepath.Join(dir, "out.wav")
+	if !bytes.Contains(stdout.Bytes(), []byte(exported)) {
+		t.Fatalf("expected export path in stdout, got %s", stdout.String())
+	}
+	pcm, err := audio.DecodeFile(t.Context(), exported, audio.Options{})
+	if err != nil {
+		t.Fatalf("decode export: %v", err)
+	}
+	if pcm.NumChannels() != 2 || len(pcm.Samples) != 8820 {
+		t.Fatalf("unexpected export: %d channels, %d samples", pcm.NumChannels(), len(pcm.Samples))
+	}
+	for i := 0; i < 8820; i++ {
+		if pcm.Channels[0][i] != float64(mono[4410+i])/32768 || pcm.Channels[1][i] != float64(mono[4410+i]/4)/32768 {
+			t.Fatalf("sample %d differs from the analyzed slice", i)
+		}
+	}
+
+	exit = run([]string{"--export-audio", "--output", "-", input}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 2 {
+		t.Fatalf("expected usage exit for stdout output, got %d", exit)
+	}
+	exit = run([]string{"--export-audio", "--export-encoding", "pcm8", input}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 2 {
+		t.Fatalf("expected usage exit for bad encoding, got %d", exit)
+	}
+}
+
+func TestRunExportAudioKeepsInput(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "song.wav")
+	original := makeWAV(genSineMixSamples(22050), 44100, 1)
+	if err := os.WriteFile(input, original, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	// The default output is song.jpg, so the export would land on song.wav.
+	exit := run([]string{"--export-audio", "--start", "0.1", input}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 2 {
+		t.Fatalf("expected usage exit, got %d stderr=%s", exit, stderr.String())
+	}
+	got, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, original) {
+		t.Fatalf("input was modified")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "song.jpg")); err == nil {
+		t.Fatalf("expected no image to be written")
+	}
+}
+
+func TestRunBadChannels(t *testing.T) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--channels", "surround", "input.wav"}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 2 {
+		t.Fatalf("expected usage exit, got %d", exit)
+	}
+}
+
 func TestRunVizCommaSeparated(t *testing.T) {
 	wav := makeWAV(genSineMixSamples(44100), 44100, 1)
 	stdout := &bytes.Buffer{}
@@ -328,6 +606,28 @@ func TestRunVizCommaSeparated(t *testing.T) {
 	}
 }
 
+func TestRunCQT(t *testing.T) {
+	wav := makeWAV(genSineMixSamples(44100), 44100, 1)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--viz", "cqt", "--bins-per-octave", "36", "--min-freq", "55", "--width", "200", "--height", "120", "--format", "png", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	img, err := png.Decode(bytes.NewReader(stdout.Bytes()))
+	if err != nil {
+		t.Fatalf("decode png: %v", err)
+	}
+	if img.Bounds().Dx() != 200 || img.Bounds().Dy() != 120 {
+		t.Fatalf("unexpected size %v", img.Bounds())
+	}
+
+	exit = run([]string{"--viz", "cqt", "--bins-per-octave", "0", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 2 {
+		t.Fatalf("expected usage exit, got %d", exit)
+	}
+}
+
 func TestGridLayout(t *testing.T) {
 	grid, err := gridLayout(3, 300, 200, 10)
 	if err != nil {
@@ -357,6 +657,16 @@ func TestGridLayoutDefaults(t *testing.T) {
 	}
 }
 
+func TestGridShape(t *testing.T) {
+	grid, err := gridShape(3, 2, 320, 210, 10)
+	if err != nil {
+		t.Fatalf("gridShape: %v", err)
+	}
+	if grid.Cols != 3 || grid.Rows != 2 || grid.CellWidth != 100 || grid.CellHeight != 100 {
+		t.Fatalf("unexpected grid: %+v", grid)
+	}
+}
+
 func TestGridLayoutInvalidSize(t *testing.T) {
 	if _, err := gridLayout(1, 0, 10, 0); err == nil {
 		t.Fatalf("expected error")
@@ -702,12 +1012,222 @@ func TestRunVerbose(t *testing.T) {
 	}
 }
 
-func TestIsPowerOfTwo(t *testing.T) {
-	if !isPowerOfTwo(8) {
-		t.Fatalf("expected power of two")
+func TestRunWindowFn(t *testing.T) {
+	wav := makeWAV(genSineMixSamples(8192), 44100, 1)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--verbose", "--window-fn", "kaiser:12", "--width", "200", "--height", "100", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("window: kaiser:12 (coherent gain")) {
+		t.Fatalf("expected window line, got %s", stderr.String())
+	}
+
+	exit = run([]string{"--window-fn", "bartlett", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 2 || !bytes.Contains(stderr.Bytes(), []byte("unknown window")) {
+		t.Fatalf("expected usage exit, got %d stderr=%s", exit, stderr.String())
+	}
+}
+
+func TestRunFreqScale(t *testing.T) {
+	wav := makeWAV(genSineMixSamples(8192), 44100, 1)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	var images [][]byte
+	for _, scale := range []string{"linear", "log"} {
+		stdout.Reset()
+		exit := run([]string{"--freq-scale", scale, "--width", "64", "--height", "64", "--format", "png", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+		if exit != 0 {
+			t.Fatalf("%s: exit %d stderr=%s", scale, exit, stderr.String())
+		}
+		images = append(images, bytes.Clone(stdout.Bytes()))
+	}
+	if bytes.Equal(images[0], images[1]) {
+		t.Fatalf("expected log axis to change the image")
+	}
+
+	exit := run([]string{"--freq-scale", "bark", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 2 || !bytes.Contains(stderr.Bytes(), []byte("unknown frequency scale")) {
+		t.Fatalf("expected usage exit, got %d stderr=%s", exit, stderr.String())
+	}
+}
+
+func TestRunMelOptions(t *testing.T) {
+	wav := makeWAV(genSineMixSamples(8192), 44100, 1)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--viz", "mel", "--mel-bands", "128", "--mel-scale", "slaney", "--mel-norm", "none", "--width", "64", "--height", "64", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+
+	for _, args := range [][]string{
+		{"--mel-bands", "0"},
+		{"--mel-scale", "bark"},
+		{"--mel-norm", "l2"},
+	} {
+		exit = run(append(args, "-"), bytes.NewReader(wav), stdout, stderr)
+		if exit != 2 {
+			t.Fatalf("%v: expected usage exit, got %d", args, exit)
+		}
+	}
+}
+
+func TestRunMFCCOptions(t *testing.T) {
+	wav := makeWAV(genSineMixSamples(8192), 44100, 1)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--viz", "mfcc", "--mfcc-coeffs", "20", "--mfcc-ortho", "--mfcc-lifter", "22", "--mfcc-cmvn", "meanvar", "--mfcc-deltas", "2", "--width", "64", "--height", "64", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+
+	for _, args := range [][]string{
+		{"--mfcc-coeffs", "41"},
+		{"--mfcc-deltas", "3"},
+		{"--mfcc-lifter=-1"},
+		{"--mfcc-cmvn", "global"},
+	} {
+		exit = run(append(args, "-"), bytes.NewReader(wav), stdout, stderr)
+		if exit != 2 {
+			t.Fatalf("%v: expected usage exit, got %d", args, exit)
+		}
+	}
+}
+
+func TestRunChromaModes(t *testing.T) {
+	wav := makeWAV(genSineMixSamples(8192), 44100, 1)
+	for _, mode := range []string{"tuned", "cqt", "cens"} {
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		exit := run([]string{"--verbose", "--viz", "chroma,selfsim", "--chroma", mode, "--width", "64", "--height", "32", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+		if exit != 0 {
+			t.Fatalf("%s: exit %d stderr=%s", mode, exit, stderr.String())
+		}
+		if !bytes.Contains(stderr.Bytes(), []byte("chroma: "+mode+", tuning ")) {
+			t.Fatalf("%s: expected tuning line, got %s", mode, stderr.String())
+		}
+	}
+	exit := run([]string{"--chroma", "pcp", "-"}, bytes.NewReader(wav), &bytes.Buffer{}, &bytes.Buffer{})
+	if exit != 2 {
+		t.Fatalf("expected usage exit, got %d", exit)
+	}
+}
+
+func TestRunReport(t *testing.T) {
+	// Two seconds of C major, then two of G7.
+	samples := make([]int16, 4*44100)
+	for i := range samples {
+		notes := []float64{60, 64, 67}
+		if i >= 2*44100 {
+			notes = []float64{67, 71, 74, 77}
+		}
+		v := 0.0
+		for _, note := range notes {
+			v += 0.2 * math.Sin(2*math.Pi*440*math.Exp2((note-69)/12)*float64(i)/44100)
+		}
+		samples[i] = int16(v * 30000)
+	}
+	wav := makeWAV(samples, 44100, 1)
+	outDir := t.TempDir()
+	reportPath := filepath.Join(outDir, "report.json")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--viz", "chroma", "--chord-labels", "--report", reportPath, "--width", "200", "--height", "100", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	data, err := os.ReadFile(reportPath)
+	if err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	var report analysisReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	if report.Chroma != "stft" || report.SampleRate != 44100 || len(report.Signals) != 1 {
+		t.Fatalf("unexpected report: %s", data)
+	}
+	signal := report.Signals[0]
+	if signal.Channel != "mix" || signal.Key != "C major" || signal.Tuning != nil {
+		t.Fatalf("unexpected signal: %+v", signal)
+	}
+	if len(signal.Chords) != 2 || signal.Chords[0].Chord != "C" || signal.Chords[1].Chord != "G7" {
+		t.Fatalf("unexpected chords: %+v", signal.Chords)
+	}
+	if change := signal.Chords[1].Start; math.Abs(change-2) > 0.1 {
+		t.Fatalf("chord change at %.2fs, want 2", change)
+	}
+
+	// Chord times stay positions in the input when a slice is analyzed.
+	exit = run([]string{"--start", "1", "--report", reportPath, "--output", filepath.Join(outDir, "slice.jpg"), "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	if data, err = os.ReadFile(reportPath); err != nil {
+		t.Fatalf("read report: %v", err)
+	}
+	report = analysisReport{}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("decode report: %v", err)
+	}
+	chords := report.Signals[0].Chords
+	if report.Offset != 1 || len(chords) != 2 || chords[0].Start != 1 || math.Abs(chords[1].Start-2) > 0.1 {
+		t.Fatalf("unexpected sliced report: %s", data)
+	}
+
+	// On stdout the JSON is all there is.
+	stdout.Reset()
+	exit = run([]string{"--chroma", "tuned", "--report", "-", "--output", filepath.Join(outDir, "out.jpg"), "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &report); err != nil || report.Signals[0].Tuning == nil {
+		t.Fatalf("expected JSON with tuning on stdout, got %s (%v)", stdout.String(), err)
+	}
+
+	exit = run([]string{"--report", "-", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 2 {
+		t.Fatalf("expected usage exit, got %d", exit)
+	}
+}
+
+func TestRunFFTSize(t *testing.T) {
+	wav := makeWAV(genSineMixSamples(8192), 44100, 1)
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--verbose", "--window", "441", "--pad", "4", "--threads", "3", "--width", "200", "--height", "100", "--output", "-", "-"}, bytes.NewReader(wav), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
+	}
+	if !bytes.Contains(stderr.Bytes(), []byte("fft: 1764 samples (25.00 Hz bins)")) {
+		t.Fatalf("expected fft line, got %s", stderr.String())
+	}
+
+	for _, args := range [][]string{
+		{"--fft-size", "1024", "--window", "2048"},
+		{"--fft-size", "4096", "--pad", "2"},
+		{"--pad=-1"},
+		{"--threads=-1"},
+	} {
+		exit = run(append(args, "-"), bytes.NewReader(wav), stdout, stderr)
+		if exit != 2 {
+			t.Fatalf("%v: expected usage exit, got %d", args, exit)
+		}
+	}
+}
+
+func TestRunVerboseMP3Info(t *testing.T) {
+	output := filepath.Join(t.TempDir(), "out.jpg")
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	exit := run([]string{"--verbose", "--output", output, testdataPath(t, "sine.mp3")}, bytes.NewReader(nil), stdout, stderr)
+	if exit != 0 {
+		t.Fatalf("exit %d stderr=%s", exit, stderr.String())
 	}
-	if isPowerOfTwo(0) {
-		t.Fatalf("unexpected power of two")
+	if !bytes.Contains(stderr.Bytes(), []byte("mp3: MPEG-1 layer 3, mono")) {
+		t.Fatalf("expected mp3 details, got %s", stderr.String())
 	}
 }
 
@@ -789,6 +1309,20 @@ func makeWAV(samples []int16, sampleRate, channels int) []byte {
 	return buf.Bytes()
 }
 
+// appendBext adds a minimal BWF bext chunk to a WAV from makeWAV.
+func appendBext(wav []byte, date, clock string, timeRef uint64) []byte {
+	body := make([]byte, 602)
+	copy(body[320:], date)
+	copy(body[330:], clock)
+	binary.LittleEndian.PutUint64(body[338:], timeRef)
+	out := append([]byte{}, wav...)
+	out = append(out, "bext"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	out = append(out, body...)
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out
+}
+
 func writeU16(buf *bytes.Buffer, v uint16) {
 	buf.WriteByte(byte(v))
 	buf.WriteByte(byte(v >> 8))
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+
+	"github.com/steipete/songsee/internal/viz"
+)
+
+// analysisReport is the JSON shape of --report. Offset is where the
+// analyzed slice starts in the input (--start); chord times include it, so
+// they are positions in the input file. Origin is the wall-clock time of
+// the input's first sample, from a BWF bext chunk.
+type analysisReport struct {
+	File       string         `json:"file"`
+	SampleRate int            `json:"sample_rate"`
+	Origin     *time.Time     `json:"origin,omitempty"`
+	Offset     float64        `json:"offset"`
+	Chroma     string         `json:"chroma"`
+	Signals    []signalReport `json:"signals"`
+}
+
+// signalReport holds the estimates for one analyzed channel. Tuning is in
+// semitones from A440 and only set for chroma modes that correct it.
+type signalReport struct {
+	Channel  string        `json:"channel"`
+	Tuning   *float64      `json:"tuning,omitempty"`
+	Key      string        `json:"key"`
+	KeyScore float64       `json:"key_score"`
+	Chords   []chordReport `json:"chords"`
+}
+
+// chordReport is one chord of the timeline, in seconds from the start of
+// the input.
+type chordReport struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Chord string  `json:"chord"`
+}
+
+// newSignalReport estimates key and chords for one signal whose first
+// sample sits offset seconds into the input.
+func newSignalReport(name string, ctx *viz.Context, mode viz.ChromaMode, offset float64) signalReport {
+	harmony := ctx.Harmony(mode)
+	report := signalReport{
+		Channel:  name,
+		Key:      harmony.Key.String(),
+		KeyScore: harmony.Key.Score,
+		Chords:   make([]chordReport, len(harmony.Chords)),
+	}
+	if mode != viz.ChromaSTFT {
+		tuning := ctx.Tuning()
+		report.Tuning = &tuning
+	}
+	rate := ctx.FrameRate()
+	for i, seg := range harmony.Chords {
+		report.Chords[i] = chordReport{
+			Start: offset + float64(seg.Start)/rate,
+			End:   offset + float64(seg.End)/rate,
+			Chord: seg.Chord.String(),
+		}
+	}
+	return report
+}
+
+// writeReport writes report as indented JSON to path, or stdout for "-".
+func writeReport(path string, report analysisReport, stdout io.Writer) error {
+	out := stdout
+	if path != "-" {
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
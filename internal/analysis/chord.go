@@ -0,0 +1,209 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/steipete/songsee/internal/dsp"
+)
+
+const (
+	defaultFrameRate = 44100.0 / 512
+	defaultDwell     = 1.0
+	// chordSharpness scales template similarity to a log-likelihood.
+	chordSharpness = 3
+	// silenceDB is how far below the loudest frame a frame is no chord.
+	silenceDB = 50
+)
+
+// Quality is the kind of a chord.
+type Quality string
+
+// Chord qualities.
+const (
+	Major    Quality = "maj"
+	Minor    Quality = "min"
+	Dominant Quality = "7"
+	// NoChord marks silence.
+	NoChord Quality = "N"
+)
+
+// chordIntervals lists the pitch classes of each quality above its root.
+var chordIntervals = map[Quality][]int{
+	Major:    {0, 4, 7},
+	Minor:    {0, 3, 7},
+	Dominant: {0, 4, 7, 10},
+}
+
+// Chord is a root and quality.
+type Chord struct {
+	// Root is the pitch class of the root, 0 = C. It is 0 for NoChord.
+	Root    int
+	Quality Quality
+}
+
+// String returns the chord symbol: "C", "Cm", "C7" or "N".
+func (c Chord) String() string {
+	switch c.Quality {
+	case NoChord:
+		return "N"
+	case Minor:
+		return PitchName(c.Root) + "m"
+	case Dominant:
+		return PitchName(c.Root) + "7"
+	default:
+		return PitchName(c.Root)
+	}
+}
+
+// ChordSegment is a chord held over frames [Start, End).
+type ChordSegment struct {
+	Chord Chord
+	Start int
+	End   int
+}
+
+// ChordOptions configures EstimateChords.
+type ChordOptions struct {
+	// FrameRate is the number of chroma frames per second (0 = 44100/512).
+	FrameRate float64
+	// Dwell is the expected time in seconds between chord changes (0 = 1).
+	// Longer values smooth more; values under a frame turn smoothing off.
+	Dwell float64
+}
+
+// chordVocabulary returns every major, minor and dominant seventh chord,
+// then NoChord.
+func chordVocabulary() []Chord {
+	chords := make([]Chord, 0, 37)
+	for _, quality := range []Quality{Major, Minor, Dominant} {
+		for root := 0; root < 12; root++ {
+			chords = append(chords, Chord{Root: root, Quality: quality})
+		}
+	}
+	return append(chords, Chord{Quality: NoChord})
+}
+
+// EstimateChords labels every frame of chroma (12 rows in dB) with a major,
+// minor or dominant seventh chord and returns the runs of equal labels.
+// Frames are scored by the cosine similarity of their chroma magnitudes
+// with binary chord templates; frames more than 50 dB below the loudest
+// are NoChord. A Viterbi pass over an HMM whose chords persist for Dwell
+// seconds on average then picks the most likely sequence, which removes
+// the flicker of frame-wise decisions.
+func EstimateChords(chroma dsp.FeatureMap, opts ChordOptions) []ChordSegment {
+	frames := chroma.Width
+	if frames == 0 {
+		return nil
+	}
+	frameRate := opts.FrameRate
+	if frameRate <= 0 {
+		frameRate = defaultFrameRate
+	}
+	dwell := opts.Dwell
+	if dwell <= 0 {
+		dwell = defaultDwell
+	}
+	chords := chordVocabulary()
+	states := len(chords)
+
+	templates := make([][12]float64, states)
+	for s, chord := range chords {
+		intervals := chordIntervals[chord.Quality]
+		for _, interval := range intervals {
+			templates[s][(chord.Root+interval)%12] = 1 / math.Sqrt(float64(len(intervals)))
+		}
+	}
+
+	energies := make([][12]float64, frames)
+	totals := make([]float64, frames)
+	loudest := 0.0
+	for f := range energies {
+		totals[f] = frameEnergy(chroma, f, &energies[f])
+		loudest = math.Max(loudest, totals[f])
+	}
+	silence := loudest * math.Pow(10, -silenceDB/10.0)
+
+	// Leaving a chord is equally likely towards any other, so the best
+	// predecessor of a state is either itself or the overall best state.
+	// At most, every state is equally likely next: no smoothing.
+	change := math.Min(1/(dwell*frameRate), float64(states-1)/float64(states))
+	stay, move := math.Log(1-change), math.Log(change/float64(states-1))
+
+	score := make([]float64, states)
+	next := make([]float64, states)
+	back := make([][]int32, frames)
+	emit := make([]float64, states)
+	for f := 0; f < frames; f++ {
+		chordEmissions(energies[f], totals[f] <= silence, templates, emit)
+		back[f] = make([]int32, states)
+		if f == 0 {
+			copy(score, emit)
+			continue
+		}
+		best := 0
+		for s := range score {
+			if score[s] > score[best] {
+				best = s
+			}
+		}
+		for s := range next {
+			from, prev := s, score[s]+stay
+			if alt := score[best] + move; s != best && alt > prev {
+				from, prev = best, alt
+			}
+			next[s] = prev + emit[s]
+			back[f][s] = int32(from)
+		}
+		score, next = next, score
+	}
+
+	state := 0
+	for s := range score {
+		if score[s] > score[state] {
+			state = s
+		}
+	}
+	path := make([]int, frames)
+	for f := frames - 1; f >= 0; f-- {
+		path[f] = state
+		state = int(back[f][state])
+	}
+
+	var segments []ChordSegment
+	for f, s := range path {
+		if n := len(segments); n > 0 && segments[n-1].Chord == chords[s] {
+			segments[n-1].End = f + 1
+			continue
+		}
+		segments = append(segments, ChordSegment{Chord: chords[s], Start: f, End: f + 1})
+	}
+	return segments
+}
+
+// chordEmissions writes the log-likelihood of every chord state for one
+// frame of energy shares. The last state is NoChord.
+func chordEmissions(energy [12]float64, silent bool, templates [][12]float64, out []float64) {
+	last := len(out) - 1
+	if silent {
+		for s := range out {
+			out[s] = 0
+		}
+		out[last] = chordSharpness
+		return
+	}
+	var mag [12]float64
+	norm := 0.0
+	for c, e := range energy {
+		mag[c] = math.Sqrt(e)
+		norm += e
+	}
+	norm = math.Sqrt(norm)
+	for s, template := range templates[:last] {
+		dot := 0.0
+		for c, w := range template {
+			dot += w * mag[c]
+		}
+		out[s] = chordSharpness * dot / norm
+	}
+	out[last] = 0
+}
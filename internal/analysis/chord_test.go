@@ -0,0 +1,58 @@
+package analysis
+
+import "testing"
+
+func TestEstimateChords(t *testing.T) {
+	// C, Am, G7 and silence, 1.5 s each.
+	chroma, rate := chordChroma(1.5, []float64{60, 64, 67}, []float64{57, 60, 64}, []float64{55, 59, 62, 65}, nil)
+	segments := EstimateChords(chroma, ChordOptions{FrameRate: rate})
+	want := []string{"C", "Am", "G7", "N"}
+	if len(segments) != len(want) {
+		t.Fatalf("expected %d segments, got %v", len(want), segments)
+	}
+	for i, seg := range segments {
+		if seg.Chord.String() != want[i] {
+			t.Fatalf("segment %d: expected %s, got %s", i, want[i], seg.Chord)
+		}
+		// Boundaries land within a window of the change.
+		if boundary := float64(seg.Start) / rate; i > 0 && (boundary < 1.5*float64(i)-0.25 || boundary > 1.5*float64(i)+0.1) {
+			t.Fatalf("segment %d starts at %.2fs", i, boundary)
+		}
+	}
+	if segments[0].Start != 0 || segments[len(segments)-1].End != chroma.Width {
+		t.Fatalf("segments do not cover all frames: %v", segments)
+	}
+}
+
+func TestEstimateChordsSmoothing(t *testing.T) {
+	// A 0.2 s blip of F inside C is absorbed unless chords may change quickly.
+	c, f := []float64{60, 64, 67}, []float64{65, 69, 72}
+	chroma, rate := chordChroma(0.1, append(append(repeat(c, 10), f, f), repeat(c, 10)...)...)
+	if segments := EstimateChords(chroma, ChordOptions{FrameRate: rate}); len(segments) != 1 || segments[0].Chord.String() != "C" {
+		t.Fatalf("expected one C segment, got %v", segments)
+	}
+	if segments := EstimateChords(chroma, ChordOptions{FrameRate: rate, Dwell: 0.01}); len(segments) != 3 || segments[1].Chord.String() != "F" {
+		t.Fatalf("expected the F blip, got %v", segments)
+	}
+}
+
+func repeat(notes []float64, n int) [][]float64 {
+	out := make([][]float64, n)
+	for i := range out {
+		out[i] = notes
+	}
+	return out
+}
+
+func TestChordString(t *testing.T) {
+	for chord, want := range map[Chord]string{
+		{Root: 0, Quality: Major}:    "C",
+		{Root: 9, Quality: Minor}:    "Am",
+		{Root: 7, Quality: Dominant}: "G7",
+		{Quality: NoChord}:           "N",
+	} {
+		if chord.String() != want {
+			t.Fatalf("expected %s, got %s", want, chord)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// Package analysis estimates musical key and chords from chroma.
+package analysis
+
+import (
+	"math"
+
+	"github.com/steipete/songsee/internal/dsp"
+)
+
+// pitchNames spells the pitch classes with sharps, 0 = C.
+var pitchNames = [12]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// PitchName returns the name of pitch class (0 = C), spelled with sharps.
+func PitchName(class int) string {
+	return pitchNames[((class%12)+12)%12]
+}
+
+// Krumhansl and Kessler's probe-tone ratings of each scale degree for major
+// and minor keys, starting at the tonic.
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+// Key is a major or minor key.
+type Key struct {
+	// Tonic is the pitch class of the tonic, 0 = C.
+	Tonic int
+	Minor bool
+	// Score is the correlation, in [-1, 1], of the chroma profile with the
+	// key's profile.
+	Score float64
+}
+
+func (k Key) String() string {
+	if k.Minor {
+		return PitchName(k.Tonic) + " minor"
+	}
+	return PitchName(k.Tonic) + " major"
+}
+
+// EstimateKey finds the key whose Krumhansl-Kessler profile, rotated to the
+// tonic, correlates best with the average chroma. chroma is a 12-row map in
+// dB as returned by the dsp chroma functions; each frame is normalized to
+// unit sum first, so loud passages do not outweigh quiet ones.
+func EstimateKey(chroma dsp.FeatureMap) Key {
+	profile := chromaProfile(chroma)
+	var best Key
+	best.Score = math.Inf(-1)
+	for tonic := 0; tonic < 12; tonic++ {
+		for _, minor := range []bool{false, true} {
+			ref := majorProfile
+			if minor {
+				ref = minorProfile
+			}
+			var rotated [12]float64
+			for degree, v := range ref {
+				rotated[(tonic+degree)%12] = v
+			}
+			if score := correlation(profile, rotated); score > best.Score {
+				best = Key{Tonic: tonic, Minor: minor, Score: score}
+			}
+		}
+	}
+	return best
+}
+
+// chromaProfile averages the frames of chroma as linear energy shares.
+func chromaProfile(chroma dsp.FeatureMap) [12]float64 {
+	var profile [12]float64
+	var frame [12]float64
+	for f := 0; f < chroma.Width; f++ {
+		if frameEnergy(chroma, f, &frame) > 0 {
+			for c, v := range frame {
+				profile[c] += v
+			}
+		}
+	}
+	return profile
+}
+
+// frameEnergy fills out with frame f of chroma as linear energy normalized
+// to unit sum and returns the total before normalization.
+func frameEnergy(chroma dsp.FeatureMap, f int, out *[12]float64) float64 {
+	total := 0.0
+	for c := range out {
+		out[c] = 0
+		if c < chroma.Height {
+			out[c] = math.Pow(10, chroma.At(f, c)/10)
+		}
+		total += out[c]
+	}
+	if total > 0 {
+		for c := range out {
+			out[c] /= total
+		}
+	}
+	return total
+}
+
+// correlation returns the Pearson correlation of a and b, or 0 when either
+// is constant.
+func correlation(a, b [12]float64) float64 {
+	var meanA, meanB float64
+	for i := range a {
+		meanA += a[i] / 12
+		meanB += b[i] / 12
+	}
+	var cov, varA, varB float64
+	for i := range a {
+		da, db := a[i]-meanA, b[i]-meanB
+		cov += da * db
+		varA += da * da
+		varB += db * db
+	}
+	if varA == 0 || varB == 0 {
+		return 0
+	}
+	return cov / math.Sqrt(varA*varB)
+}
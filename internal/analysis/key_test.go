@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/steipete/songsee/internal/dsp"
+)
+
+const testRate = 22050
+
+// chordChroma renders each chord (MIDI notes) for seconds and returns the
+// chroma of the whole signal with its frame rate.
+func chordChroma(seconds float64, chords ...[]float64) (dsp.FeatureMap, float64) {
+	per := int(seconds * testRate)
+	samples := make([]float64, per*len(chords))
+	for i, notes := range chords {
+		for _, note := range notes {
+			freq := dsp.MIDIToHz(note)
+			for n := 0; n < per; n++ {
+				samples[i*per+n] += 0.2 * math.Sin(2*math.Pi*freq*float64(n)/testRate)
+			}
+		}
+	}
+	spec := dsp.ComputeSpectrogramWith(samples, testRate, dsp.SpectrogramOptions{WindowSize: 4096, HopSize: 1024})
+	return dsp.Chroma(&spec), testRate / 1024.0
+}
+
+func TestEstimateKey(t *testing.T) {
+	cases := []struct {
+		chords [][]float64
+		want   string
+	}{
+		// C - F - G - C
+		{[][]float64{{60, 64, 67}, {65, 69, 72}, {67, 71, 74}, {60, 64, 67}}, "C major"},
+		// Am - Dm - E - Am
+		{[][]float64{{57, 60, 64}, {62, 65, 69}, {64, 68, 71}, {57, 60, 64}}, "A minor"},
+		// D - G - A - D
+		{[][]float64{{62, 66, 69}, {67, 71, 74}, {69, 73, 76}, {62, 66, 69}}, "D major"},
+	}
+	for _, c := range cases {
+		chroma, _ := chordChroma(1, c.chords...)
+		key := EstimateKey(chroma)
+		if key.String() != c.want || key.Score < 0.5 || key.Score > 1 {
+			t.Fatalf("expected %s, got %s (score %.2f)", c.want, key, key.Score)
+		}
+	}
+}
+
+func TestPitchName(t *testing.T) {
+	if PitchName(0) != "C" || PitchName(10) != "A#" || PitchName(-1) != "B" || PitchName(13) != "C#" {
+		t.Fatalf("unexpected pitch names")
+	}
+}
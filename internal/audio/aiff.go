@@ -0,0 +1,410 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// DecodeAIFFIf tries to decode AIFF or AIFF-C data, returning ok=false when not AIFF.
+func DecodeAIFFIf(r io.ReadSeeker) (Audio, bool, error) {
+	return decodeAIFFIf(r, Options{})
+}
+
+func decodeAIFFIf(r io.ReadSeeker, opts Options) (Audio, bool, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Audio{}, false, err
+	}
+	_, _ = r.Seek(0, io.SeekStart)
+	if !isAIFFHeader(header) {
+		return Audio{}, false, nil
+	}
+	pcm, err := decodeAIFF(r, opts)
+	if err != nil {
+		return Audio{}, true, err
+	}
+	pcm, err = opts.slice(pcm)
+	return pcm, true, err
+}
+
+func isAIFFHeader(header []byte) bool {
+	form := string(header[8:12])
+	return string(header[0:4]) == "FORM" && (form == "AIFF" || form == "AIFC")
+}
+
+type aiffCommon struct {
+	NumChannels int
+	NumFrames   int
+	SampleSize  int
+	SampleRate  float64
+	Compression string
+}
+
+// decodeAIFF decodes the whole stream. It refuses inputs longer than
+// opts.MaxDuration from the COMM frame count before decoding.
+func decodeAIFF(r io.Reader, opts Options) (Audio, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Audio{}, err
+	}
+	if !isAIFFHeader(header) {
+		return Audio{}, ErrUnsupported
+	}
+	compressed := string(header[8:12]) == "AIFC"
+
+	var (
+		commFound bool
+		comm      aiffCommon
+		data      []byte
+	)
+	for {
+		chunkHeader := make([]byte, 8)
+		_, err := io.ReadFull(r, chunkHeader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Audio{}, err
+		}
+		chunkID := string(chunkHeader[0:4])
+		chunkSize := int64(binary.BigEndian.Uint32(chunkHeader[4:8]))
+		if chunkID != "COMM" && chunkID != "SSND" {
+			if err := skipIFFChunk(r, chunkSize); err != nil {
+				return Audio{}, err
+			}
+			continue
+		}
+		if chunkID == "SSND" && commFound {
+			if err := checkAIFFLength(comm, chunkSize-8, opts); err != nil {
+				return Audio{}, err
+			}
+		}
+		buf, err := readIFFChunk(r, chunkSize)
+		if err != nil {
+			return Audio{}, err
+		}
+
+		switch chunkID {
+		case "COMM":
+			if err := parseAIFFCommon(buf, compressed, &comm); err != nil {
+				return Audio{}, err
+			}
+			commFound = true
+		case "SSND":
+			if len(buf) < 8 {
+				return Audio{}, errors.New("aiff: short SSND chunk")
+			}
+			offset := int(binary.BigEndian.Uint32(buf[0:4]))
+			if 8+offset > len(buf) {
+				return Audio{}, errors.New("aiff: invalid SSND offset")
+			}
+			data = buf[8+offset:]
+		}
+	}
+
+	if !commFound || data == nil {
+		return Audio{}, errors.New("aiff: missing COMM or SSND chunk")
+	}
+	// An SSND chunk that preceded COMM could not be checked when it was read.
+	if err := checkAIFFLength(comm, int64(len(data)), opts); err != nil {
+		return Audio{}, err
+	}
+	return decodeAIFFData(comm, data)
+}
+
+// checkAIFFLength fails with ErrTooLong when the COMM frame count, clamped
+// to size bytes of sound data, exceeds opts.MaxDuration. An invalid format
+// is left for the decode to report.
+func checkAIFFLength(comm aiffCommon, size int64, opts Options) error {
+	layout, err := newAIFFLayout(comm)
+	if err != nil {
+		return nil
+	}
+	return opts.checkLength(min(int64(comm.NumFrames), size/int64(layout.frameSize)), layout.sampleRate)
+}
+
+// NewAIFFSource parses the AIFF header from r and streams its sound data.
+// The COMM chunk must precede the SSND chunk.
+func NewAIFFSource(r io.Reader) (Source, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if !isAIFFHeader(header) {
+		return nil, ErrUnsupported
+	}
+	compressed := string(header[8:12]) == "AIFC"
+
+	var (
+		commFound bool
+		comm      aiffCommon
+	)
+	for {
+		chunkHeader := make([]byte, 8)
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			if err == io.EOF {
+				return nil, errors.New("aiff: missing COMM or SSND chunk")
+			}
+			return nil, err
+		}
+		chunkID := string(chunkHeader[0:4])
+		chunkSize := int64(binary.BigEndian.Uint32(chunkHeader[4:8]))
+
+		switch chunkID {
+		case "COMM":
+			buf, err := readIFFChunk(r, chunkSize)
+			if err != nil {
+				return nil, err
+			}
+			if err := parseAIFFCommon(buf, compressed, &comm); err != nil {
+				return nil, err
+			}
+			commFound = true
+		case "SSND":
+			if !commFound {
+				return nil, errors.New("aiff: SSND chunk before COMM chunk")
+			}
+			layout, err := newAIFFLayout(comm)
+			if err != nil {
+				return nil, err
+			}
+			if chunkSize < 8 {
+				return nil, errors.New("aiff: short SSND chunk")
+			}
+			prefix := make([]byte, 8)
+			if _, err := io.ReadFull(r, prefix); err != nil {
+				return nil, err
+			}
+			offset := int64(binary.BigEndian.Uint32(prefix[0:4]))
+			if 8+offset > chunkSize {
+				return nil, errors.New("aiff: invalid SSND offset")
+			}
+			if _, err := io.CopyN(io.Discard, r, offset); err != nil {
+				return nil, io.ErrUnexpectedEOF
+			}
+			frames := min(int64(comm.NumFrames), (chunkSize-8-offset)/int64(layout.frameSize))
+			return newAIFFSource(r, layout, frames), nil
+		default:
+			if err := skipIFFChunk(r, chunkSize); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// newAIFFSource decodes up to frames frames from r in blocks of
+// DefaultBlockSize. A truncated final frame ends the stream.
+func newAIFFSource(r io.Reader, layout aiffLayout, frames int64) Source {
+	var buf []byte
+	return &blockSource{
+		rate:     layout.sampleRate,
+		channels: layout.channels,
+		meta:     Metadata{Codec: "aiff"},
+		frames:   frames,
+		next: func() ([][]float64, error) {
+			n := int(min(frames, DefaultBlockSize))
+			if n == 0 {
+				return nil, io.EOF
+			}
+			if cap(buf) < n*layout.frameSize {
+				buf = make([]byte, n*layout.frameSize)
+			}
+			read, err := io.ReadFull(r, buf[:n*layout.frameSize])
+			frames -= int64(n)
+			if err == io.ErrUnexpectedEOF || err == io.EOF {
+				n = read / layout.frameSize
+				frames = 0
+			} else if err != nil {
+				return nil, err
+			}
+			return layout.decode(buf[:n*layout.frameSize], n), nil
+		},
+	}
+}
+
+// readIFFChunk reads a chunk body and its pad byte. The body is read through
+// a LimitReader so a bogus size cannot allocate more than the input holds.
+// A missing pad byte at the end of the stream is tolerated.
+func readIFFChunk(r io.Reader, size int64) ([]byte, error) {
+	buf, err := io.ReadAll(io.LimitReader(r, size))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(buf)) < size {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if size%2 == 1 {
+		_, _ = io.CopyN(io.Discard, r, 1)
+	}
+	return buf, nil
+}
+
+// skipIFFChunk discards a chunk body and its pad byte.
+func skipIFFChunk(r io.Reader, size int64) error {
+	if _, err := io.CopyN(io.Discard, r, size); err != nil {
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if size%2 == 1 {
+		_, _ = io.CopyN(io.Discard, r, 1)
+	}
+	return nil
+}
+
+func parseAIFFCommon(buf []byte, compressed bool, comm *aiffCommon) error {
+	if len(buf) < 18 {
+		return errors.New("aiff: short COMM chunk")
+	}
+	comm.NumChannels = int(int16(binary.BigEndian.Uint16(buf[0:2])))
+	comm.NumFrames = int(binary.BigEndian.Uint32(buf[2:6]))
+	comm.SampleSize = int(int16(binary.BigEndian.Uint16(buf[6:8])))
+	comm.SampleRate = parseExtended(buf[8:18])
+	comm.Compression = "NONE"
+	if compressed {
+		if len(buf) < 22 {
+			return errors.New("aiff: short AIFC COMM chunk")
+		}
+		comm.Compression = string(buf[18:22])
+	}
+	return nil
+}
+
+// parseExtended converts an 80-bit IEEE 754 extended float (big-endian).
+func parseExtended(b []byte) float64 {
+	exp := int(binary.BigEndian.Uint16(b[0:2]))
+	mantissa := binary.BigEndian.Uint64(b[2:10])
+	sign := 1.0
+	if exp&0x8000 != 0 {
+		sign = -1
+		exp &= 0x7FFF
+	}
+	if exp == 0 && mantissa == 0 {
+		return 0
+	}
+	return sign * math.Ldexp(float64(mantissa), exp-16383-63)
+}
+
+func decodeAIFFData(comm aiffCommon, data []byte) (Audio, error) {
+	layout, err := newAIFFLayout(comm)
+	if err != nil {
+		return Audio{}, err
+	}
+	pcm := NewAudio(layout.sampleRate, layout.decode(data, comm.NumFrames))
+	pcm.Metadata.Codec = "aiff"
+	return pcm, nil
+}
+
+// aiffLayout decodes sound data in the sample format described by COMM.
+type aiffLayout struct {
+	sampleRate int
+	channels   int
+	frameSize  int
+	// decode converts up to frames whole frames of data.
+	decode func(data []byte, frames int) [][]float64
+}
+
+func newAIFFLayout(comm aiffCommon) (aiffLayout, error) {
+	channels := comm.NumChannels
+	if channels < 1 {
+		return aiffLayout{}, errors.New("aiff: invalid channel count")
+	}
+	sampleRate := int(math.Round(comm.SampleRate))
+	if sampleRate <= 0 {
+		return aiffLayout{}, errors.New("aiff: invalid sample rate")
+	}
+	layout := aiffLayout{sampleRate: sampleRate, channels: channels}
+
+	bits := comm.SampleSize
+	switch comm.Compression {
+	case "NONE", "twos", "sowt":
+		if bits < 1 || bits > 32 {
+			return aiffLayout{}, fmt.Errorf("aiff: unsupported sample size %d", comm.SampleSize)
+		}
+		var order binary.ByteOrder = binary.BigEndian
+		if comm.Compression == "sowt" {
+			order = binary.LittleEndian
+		}
+		layout.decode = func(data []byte, frames int) [][]float64 {
+			return decodeAIFFPCM(data, bits, channels, frames, order)
+		}
+	case "fl32", "FL32", "fl64", "FL64":
+		bits = 32
+		if comm.Compression[2] == '6' {
+			bits = 64
+		}
+		layout.decode = func(data []byte, frames int) [][]float64 {
+			return decodeAIFFFloat(data, bits, channels, frames)
+		}
+	default:
+		return aiffLayout{}, fmt.Errorf("aiff: unsupported compression %q", comm.Compression)
+	}
+	layout.frameSize = (bits + 7) / 8 * channels
+	return layout, nil
+}
+
+// decodeAIFFPCM decodes signed PCM. Sample sizes that are not a multiple of
+// 8 bits are stored left-justified, so the container width sets the scale.
+func decodeAIFFPCM(data []byte, bits, channels, frames int, order binary.ByteOrder) [][]float64 {
+	bytesPerSample := (bits + 7) / 8
+	frames = aiffFrameCount(len(data), bytesPerSample*channels, frames)
+	scale := float64(int64(1) << (bytesPerSample*8 - 1))
+	out := makeChannels(channels, frames)
+	idx := 0
+	for i := 0; i < frames; i++ {
+		for ch := 0; ch < channels; ch++ {
+			b := data[idx : idx+bytesPerSample]
+			var v int32
+			switch bytesPerSample {
+			case 1:
+				v = int32(int8(b[0]))
+			case 2:
+				v = int32(int16(order.Uint16(b)))
+			case 3:
+				if order == binary.BigEndian {
+					v = int32(b[0])<<16 | int32(b[1])<<8 | int32(b[2])
+				} else {
+					v = int32(b[2])<<16 | int32(b[1])<<8 | int32(b[0])
+				}
+				if v&0x800000 != 0 {
+					v |= ^0xffffff
+				}
+			case 4:
+				v = int32(order.Uint32(b))
+			}
+			out[ch][i] = float64(v) / scale
+			idx += bytesPerSample
+		}
+	}
+	return out
+}
+
+func decodeAIFFFloat(data []byte, bits, channels, frames int) [][]float64 {
+	bytesPerSample := bits / 8
+	frames = aiffFrameCount(len(data), bytesPerSample*channels, frames)
+	out := makeChannels(channels, frames)
+	idx := 0
+	for i := 0; i < frames; i++ {
+		for ch := 0; ch < channels; ch++ {
+			if bits == 32 {
+				out[ch][i] = float64(math.Float32frombits(binary.BigEndian.Uint32(data[idx : idx+4])))
+			} else {
+				out[ch][i] = math.Float64frombits(binary.BigEndian.Uint64(data[idx : idx+8]))
+			}
+			idx += bytesPerSample
+		}
+	}
+	return out
+}
+
+// aiffFrameCount clamps the COMM frame count to the sound data present.
+func aiffFrameCount(size, frameSize, frames int) int {
+	if avail := size / frameSize; frames > avail {
+		return avail
+	}
+	return frames
+}
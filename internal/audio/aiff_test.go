@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeAIFF16Stereo(t *testing.T) {
+	frames := [][2]int16{{1000, -1000}, {16384, 0}, {-32768, -32768}}
+	var data []byte
+	for _, f := range frames {
+		data = binary.BigEndian.AppendUint16(data, uint16(f[0]))
+		data = binary.BigEndian.AppendUint16(data, uint16(f[1]))
+	}
+	pcm, ok, err := DecodeAIFFIf(bytesReader(makeAIFF("", 2, 16, 44100, len(frames), data)))
+	if err != nil {
+		t.Fatalf("DecodeAIFFIf: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected ok=true")
+	}
+	if pcm.SampleRate != 44100 {
+		t.Fatalf("sample rate = %d", pcm.SampleRate)
+	}
+	want := []float64{0, 0.25, -1}
+	for i, v := range want {
+		if math.Abs(pcm.Samples[i]-v) > 1e-9 {
+			t.Fatalf("sample %d = %f, want %f", i, pcm.Samples[i], v)
+		}
+	}
+}
+
+func TestDecodeAIFCSowt24(t *testing.T) {
+	data := []byte{0x00, 0x00, 0x40, 0x00, 0x00, 0xC0, 0xFF, 0xFF, 0xFF}
+	pcm, err := DecodeBytes(t.Context(), makeAIFF("sowt", 1, 24, 48000, 3, data), Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	want := []float64{0.5, -0.5, -1.0 / (1 << 23)}
+	for i, v := range want {
+		if math.Abs(pcm.Samples[i]-v) > 1e-12 {
+			t.Fatalf("sample %d = %f, want %f", i, pcm.Samples[i], v)
+		}
+	}
+}
+
+func TestDecodeAIFCFloat32(t *testing.T) {
+	var data []byte
+	for _, v := range []float32{0.5, -0.25, 1} {
+		data = binary.BigEndian.AppendUint32(data, math.Float32bits(v))
+	}
+	path := filepath.Join(t.TempDir(), "float.aifc")
+	if err := os.WriteFile(path, makeAIFF("fl32", 1, 32, 96000, 3, data), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	pcm, err := DecodeFile(t.Context(), path, Options{})
+	if err != nil {
+		t.Fatalf("DecodeFile: %v", err)
+	}
+	if pcm.SampleRate != 96000 || len(pcm.Samples) != 3 || pcm.Samples[1] != -0.25 {
+		t.Fatalf("unexpected decode: %d %v", pcm.SampleRate, pcm.Samples)
+	}
+}
+
+func TestDecodeAIFFOddSampleSize(t *testing.T) {
+	// 12-bit samples are left-justified in 16-bit containers.
+	data := []byte{0x40, 0x00, 0x80, 0x00}
+	pcm, err := DecodeBytes(t.Context(), makeAIFF("", 1, 12, 8000, 2, data), Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	if pcm.Samples[0] != 0.5 || pcm.Samples[1] != -1 {
+		t.Fatalf("samples = %v", pcm.Samples)
+	}
+}
+
+func TestDecodeAIFFUnsupportedCompression(t *testing.T) {
+	if _, err := DecodeBytes(t.Context(), makeAIFF("ima4", 1, 16, 44100, 1, make([]byte, 34)), Options{}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestDecodeAIFFMissingSSND(t *testing.T) {
+	data := makeAIFF("", 1, 16, 44100, 1, []byte{0, 0})
+	// Rename the SSND chunk so it is skipped as unknown.
+	copy(data[bytes.Index(data, []byte("SSND")):], "JUNK")
+	if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestDecodeAIFFOversizedChunk(t *testing.T) {
+	for _, id := range []string{"JUNK", "COMM", "SSND"} {
+		data := []byte("FORM\x00\x00\x00\x16AIFC")
+		data = append(data, id...)
+		data = binary.BigEndian.AppendUint32(data, 0xFFFFFFF0)
+		data = append(data, 0, 0, 0, 0, 0, 0)
+		if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
+			t.Fatalf("%s: expected error for truncated chunk", id)
+		}
+	}
+}
+
+func TestDecodeAIFFIfNotAIFF(t *testing.T) {
+	_, ok, err := DecodeAIFFIf(bytesReader([]byte("FORM\x00\x00\x00\x04WAVE")))
+	if err != nil {
+		t.Fatalf("DecodeAIFFIf error: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected ok=false")
+	}
+}
+
+func TestParseExtended(t *testing.T) {
+	for _, rate := range []float64{8000, 11025, 44100, 48000, 192000} {
+		if got := parseExtended(makeExtended(rate)); got != rate {
+			t.Fatalf("parseExtended(%v) = %v", rate, got)
+		}
+	}
+	if got := parseExtended(make([]byte, 10)); got != 0 {
+		t.Fatalf("zero = %v", got)
+	}
+}
+
+// makeAIFF builds an AIFF file, or AIFF-C when compression is set.
+func makeAIFF(compression string, channels, bits, sampleRate, frames int, data []byte) []byte {
+	comm := binary.BigEndian.AppendUint16(nil, uint16(channels))
+	comm = binary.BigEndian.AppendUint32(comm, uint32(frames))
+	comm = binary.BigEndian.AppendUint16(comm, uint16(bits))
+	comm = append(comm, makeExtended(float64(sampleRate))...)
+	form := "AIFF"
+	if compression != "" {
+		form = "AIFC"
+		comm = append(comm, compression...)
+		comm = append(comm, 0, 0) // empty pascal name, padded
+	}
+
+	body := []byte(form)
+	body = appendIFFChunk(body, "COMM", comm)
+	// A non-zero SSND offset exercises the skip.
+	ssnd := binary.BigEndian.AppendUint32(nil, 2)
+	ssnd = binary.BigEndian.AppendUint32(ssnd, 0)
+	ssnd = append(ssnd, 0xAA, 0xAA)
+	body = appendIFFChunk(body, "SSND", append(ssnd, data...))
+	return appendIFFChunk(nil, "FORM", body)
+}
+
+func appendIFFChunk(out []byte, id string, data []byte) []byte {
+	out = append(out, id...)
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	out = append(out, data...)
+	if len(data)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func makeExtended(v float64) []byte {
+	frac, exp := math.Frexp(v)
+	out := binary.BigEndian.AppendUint16(nil, uint16(exp-1+16383))
+	return binary.BigEndian.AppendUint64(out, uint64(frac*(1<<63))<<1)
+}
@@ -1,21 +1,145 @@
-// Package audio handles decoding audio into mono float samples.
+// Package audio handles decoding audio into float samples.
 package audio
 
-import "fmt"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
-// Audio holds mono samples in [-1,1] range.
+// Audio holds decoded samples in [-1,1] range.
 type Audio struct {
 	SampleRate int
-	Samples    []float64
+	// Samples is the mono mix (average of all channels).
+	Samples []float64
+	// Channels holds the per-channel samples, all of equal length.
+	Channels [][]float64
+	// Metadata describes the source stream, as far as the decoder knows it.
+	Metadata Metadata
+}
+
+// Metadata describes the encoded stream behind decoded audio.
+type Metadata struct {
+	// Codec names the source codec, such as "mp3" or "flac".
+	Codec string
+	// Bitrate is the (average) bitrate in bits per second, 0 when unknown.
+	Bitrate int
+	// MP3 holds frame header details for MP3 input.
+	MP3 *MP3Info
+	// BWF holds the Broadcast Wave bext chunk of WAV input.
+	BWF *BWFInfo
+}
+
+// MP3Info describes an MP3 stream as read from its frame headers.
+type MP3Info struct {
+	// Version is the MPEG version: "1", "2" or "2.5".
+	Version string
+	Layer   int
+	// ChannelMode is "stereo", "joint stereo", "dual channel" or "mono".
+	ChannelMode string
+	// VBR reports a variable bitrate stream.
+	VBR bool
+	// Frames counts audio frames, excluding a Xing/Info/VBRI header frame.
+	Frames int
+	// Encoder is the encoder tag from the LAME header, such as "LAME3.100".
+	Encoder string
+	// EncoderDelay and EncoderPadding are the silent samples the encoder
+	// added at the start and end; both are trimmed from the decoded audio.
+	EncoderDelay   int
+	EncoderPadding int
 }
 
 // Options controls decoding behavior.
 type Options struct {
 	SampleRate int
 	FFmpegPath string
+	// Start and Duration limit decoding to a time range in seconds; a zero
+	// Duration decodes to the end. Decoders that can seek skip straight to
+	// Start instead of decoding everything before it.
+	Start    float64
+	Duration float64
+	// MaxDuration caps the decoded length in seconds; 0 means no limit.
+	// Exceeding it fails with ErrTooLong.
+	MaxDuration float64
 }
 
 var (
 	// ErrUnsupported is returned when no decoder can handle the input.
 	ErrUnsupported = fmt.Errorf("unsupported audio format")
+	// ErrTimeout is returned when the decode context's deadline passes.
+	ErrTimeout = errors.New("decode timed out")
+	// ErrTooLong is returned when decoded audio exceeds Options.MaxDuration.
+	ErrTooLong = errors.New("decoded audio exceeds maximum duration")
 )
+
+// contextError maps a done context to ErrTimeout or its cancellation error.
+func contextError(ctx context.Context) error {
+	err := ctx.Err()
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+	return err
+}
+
+// maxFrames returns the MaxDuration limit in frames at sampleRate, or -1
+// when there is none.
+func (o Options) maxFrames(sampleRate int) int {
+	if o.MaxDuration <= 0 {
+		return -1
+	}
+	return int(o.MaxDuration * float64(sampleRate))
+}
+
+// checkLength fails with ErrTooLong when a stream whose header declares
+// frames frames at sampleRate would exceed MaxDuration once the range is
+// applied. It lets decoders refuse long inputs before decoding them; frames
+// <= 0 means the length is unknown.
+func (o Options) checkLength(frames int64, sampleRate int) error {
+	limit := o.maxFrames(sampleRate)
+	if limit < 0 || frames <= 0 {
+		return nil
+	}
+	frames -= int64(o.Start * float64(sampleRate))
+	if o.Duration > 0 {
+		frames = min(frames, int64(o.Duration*float64(sampleRate)))
+	}
+	if frames > int64(limit) {
+		return tooLongError(o)
+	}
+	return nil
+}
+
+func tooLongError(o Options) error {
+	return fmt.Errorf("%w of %gs", ErrTooLong, o.MaxDuration)
+}
+
+// NewAudio builds Audio from per-channel samples, computing the mono mix.
+func NewAudio(sampleRate int, channels [][]float64) Audio {
+	return Audio{SampleRate: sampleRate, Samples: mixDown(channels), Channels: channels}
+}
+
+// NumChannels returns the number of decoded channels.
+func (a Audio) NumChannels() int {
+	if len(a.Channels) == 0 && a.Samples != nil {
+		return 1
+	}
+	return len(a.Channels)
+}
+
+func mixDown(channels [][]float64) []float64 {
+	switch len(channels) {
+	case 0:
+		return nil
+	case 1:
+		return channels[0]
+	}
+	out := make([]float64, len(channels[0]))
+	for i := range out {
+		var sum float64
+		for _, ch := range channels {
+			sum += ch[i]
+		}
+		out[i] = sum / float64(len(channels))
+	}
+	return out
+}
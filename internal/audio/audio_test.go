@@ -14,7 +14,7 @@ func TestDecodeWAVBytes(t *testing.T) {
 		samples[i] = int16(2000 * math.Sin(2*math.Pi*float64(i)/50))
 	}
 	data := makeWAV(samples, 44100, 1)
-	pcm, err := DecodeBytes(data, Options{})
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
 	if err != nil {
 		t.Fatalf("DecodeBytes: %v", err)
 	}
@@ -52,7 +52,7 @@ func TestDecodeWAVIfValid(t *testing.T) {
 
 func TestDecodeMP3File(t *testing.T) {
 	path := testdataPath(t, "sine.mp3")
-	pcm, err := DecodeFile(path, Options{})
+	pcm, err := DecodeFile(t.Context(), path, Options{})
 	if err != nil {
 		t.Fatalf("DecodeFile: %v", err)
 	}
@@ -85,7 +85,7 @@ func TestDecodeFileUnknownExt(t *testing.T) {
 	if err := os.WriteFile(path, makeWAV([]int16{0, 1, -1}, 44100, 1), 0o644); err != nil {
 		t.Fatalf("write file: %v", err)
 	}
-	pcm, err := DecodeFile(path, Options{})
+	pcm, err := DecodeFile(t.Context(), path, Options{})
 	if err != nil {
 		t.Fatalf("DecodeFile: %v", err)
 	}
@@ -100,7 +100,7 @@ func TestDecodeFileWAV(t *testing.T) {
 	if err := os.WriteFile(path, makeWAV([]int16{0, 1, -1}, 44100, 1), 0o644); err != nil {
 		t.Fatalf("write file: %v", err)
 	}
-	pcm, err := DecodeFile(path, Options{})
+	pcm, err := DecodeFile(t.Context(), path, Options{})
 	if err != nil {
 		t.Fatalf("DecodeFile: %v", err)
 	}
@@ -115,13 +115,13 @@ func TestDecodeFileFFmpegFallbackError(t *testing.T) {
 	if err := os.WriteFile(path, []byte("garbagegarbagegarbage"), 0o644); err != nil {
 		t.Fatalf("write file: %v", err)
 	}
-	if _, err := DecodeFile(path, Options{}); err == nil {
+	if _, err := DecodeFile(t.Context(), path, Options{}); err == nil {
 		t.Fatalf("expected error")
 	}
 }
 
 func TestDecodeBytesFFmpegFallbackError(t *testing.T) {
-	_, err := DecodeBytes([]byte("not audio"), Options{})
+	_, err := DecodeBytes(t.Context(), []byte("not audio"), Options{})
 	if err == nil {
 		t.Fatalf("expected error for garbage data")
 	}
@@ -133,7 +133,7 @@ func TestDecodeMP3Bytes(t *testing.T) {
 	if err != nil {
 		t.Fatalf("ReadFile: %v", err)
 	}
-	pcm, err := DecodeBytes(data, Options{})
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
 	if err != nil {
 		t.Fatalf("DecodeBytes: %v", err)
 	}
@@ -145,7 +145,7 @@ func TestDecodeMP3Bytes(t *testing.T) {
 func TestDecodeReader(t *testing.T) {
 	samples := []int16{0, 1000, -1000, 0}
 	data := makeWAV(samples, 48000, 1)
-	pcm, err := DecodeReader(bytesReader(data), Options{})
+	pcm, err := DecodeReader(t.Context(), bytesReader(data), Options{})
 	if err != nil {
 		t.Fatalf("DecodeReader: %v", err)
 	}
@@ -155,7 +155,7 @@ func TestDecodeReader(t *testing.T) {
 }
 
 func TestDecodeReaderError(t *testing.T) {
-	_, err := DecodeReader(errReader{}, Options{})
+	_, err := DecodeReader(t.Context(), errReader{}, Options{})
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -220,7 +220,7 @@ func TestDecodeMP3IfNotMP3(t *testing.T) {
 }
 
 func TestDecodeWAVUnsupported(t *testing.T) {
-	_, err := decodeWAV(bytesReader([]byte("NOTWAVE12345")))
+	_, err := decodeWAV(bytesReader([]byte("NOTWAVE12345")), Options{})
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -0,0 +1,190 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// BWFInfo holds the Broadcast Wave Format bext chunk.
+type BWFInfo struct {
+	Description         string
+	Originator          string
+	OriginatorReference string
+	// OriginationTime is the recording's creation date and time. The bext
+	// chunk has no time zone, so it is read as UTC; zero when unset.
+	OriginationTime time.Time
+	// TimeReference is the position of the first sample, in samples since
+	// midnight of the origination date.
+	TimeReference uint64
+	Version       int
+	CodingHistory string
+}
+
+// Origin returns the timeline position of the first sample: midnight of the
+// origination date plus TimeReference at sampleRate. Recorders that leave
+// TimeReference at 0 get OriginationTime instead. Without an origination
+// date Origin returns the zero time.
+func (b BWFInfo) Origin(sampleRate int) time.Time {
+	if b.OriginationTime.IsZero() || b.TimeReference == 0 || sampleRate <= 0 {
+		return b.OriginationTime
+	}
+	year, month, day := b.OriginationTime.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	secs := b.TimeReference / uint64(sampleRate)
+	frac := b.TimeReference % uint64(sampleRate)
+	return midnight.Add(time.Duration(secs)*time.Second + time.Duration(frac)*time.Second/time.Duration(sampleRate))
+}
+
+// bext field offsets, from EBU Tech 3285.
+const (
+	bextOriginator    = 256
+	bextReference     = 288
+	bextDate          = 320
+	bextTime          = 330
+	bextTimeReference = 338
+	bextVersion       = 346
+	bextCodingHistory = 602
+)
+
+func parseBext(buf []byte) (*BWFInfo, error) {
+	if len(buf) < bextVersion+2 {
+		return nil, errors.New("wav: short bext chunk")
+	}
+	info := &BWFInfo{
+		Description:         bextString(buf[:bextOriginator]),
+		Originator:          bextString(buf[bextOriginator:bextReference]),
+		OriginatorReference: bextString(buf[bextReference:bextDate]),
+		TimeReference:       binary.LittleEndian.Uint64(buf[bextTimeReference:bextVersion]),
+		Version:             int(binary.LittleEndian.Uint16(buf[bextVersion : bextVersion+2])),
+	}
+	info.OriginationTime = parseBextTime(bextString(buf[bextDate:bextTime]), bextString(buf[bextTime:bextTimeReference]))
+	if len(buf) > bextCodingHistory {
+		info.CodingHistory = strings.TrimRight(bextString(buf[bextCodingHistory:]), "\r\n")
+	}
+	return info, nil
+}
+
+func bextString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// parseBextTime reads "yyyy-mm-dd" and "hh:mm:ss"; the spec allows any of
+// '-', '_', ':', ' ' or '.' as separator.
+func parseBextTime(date, clock string) time.Time {
+	normalize := func(s string, sep byte) string {
+		return strings.Map(func(r rune) rune {
+			if strings.ContainsRune("-_: .", r) {
+				return rune(sep)
+			}
+			return r
+		}, s)
+	}
+	if len(date) != 10 {
+		return time.Time{}
+	}
+	if len(clock) != 8 {
+		clock = "00:00:00"
+	}
+	t, err := time.Parse("2006-01-02 15:04:05", normalize(date, '-')+" "+normalize(clock, ':'))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// wavSizes holds the 64-bit sizes from an RF64/BW64 ds64 chunk, which
+// replace chunk sizes written as 0xFFFFFFFF.
+type wavSizes struct {
+	data   int64
+	chunks map[string]int64
+}
+
+// parseDS64 reads a ds64 chunk. limit is the number of bytes left in the
+// stream after the chunk, or -1 when unknown; sizes that are negative or
+// run past it are rejected before they reach an allocation or seek.
+func parseDS64(buf []byte, limit int64) (*wavSizes, error) {
+	if len(buf) < 24 {
+		return nil, errors.New("wav: short ds64 chunk")
+	}
+	size := func(b []byte) (int64, error) {
+		v := int64(binary.LittleEndian.Uint64(b))
+		if v < 0 || (limit >= 0 && v > limit) {
+			return 0, fmt.Errorf("wav: invalid ds64 size %d", uint64(v))
+		}
+		return v, nil
+	}
+	if int64(binary.LittleEndian.Uint64(buf[0:8])) < 0 {
+		return nil, errors.New("wav: invalid ds64 riff size")
+	}
+	data, err := size(buf[8:16])
+	if err != nil {
+		return nil, err
+	}
+	sizes := &wavSizes{data: data}
+	if len(buf) >= 28 {
+		count := int(binary.LittleEndian.Uint32(buf[24:28]))
+		for i, pos := 0, 28; i < count && pos+12 <= len(buf); i, pos = i+1, pos+12 {
+			if sizes.chunks == nil {
+				sizes.chunks = map[string]int64{}
+			}
+			v, err := size(buf[pos+4 : pos+12])
+			if err != nil {
+				return nil, err
+			}
+			sizes.chunks[string(buf[pos:pos+4])] = v
+		}
+	}
+	return sizes, nil
+}
+
+// seekRemaining returns the bytes between the current position of r and
+// its end, or -1 when r cannot report it.
+func seekRemaining(r io.Seeker) int64 {
+	pos, err := r.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return -1
+	}
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return -1
+	}
+	if _, err := r.Seek(pos, io.SeekStart); err != nil {
+		return -1
+	}
+	return end - pos
+}
+
+// chunkSize resolves the size of chunk id, looking up placeholder sizes in
+// the ds64 table. It is safe to call on a nil *wavSizes.
+func (s *wavSizes) chunkSize(id string, raw uint32) int64 {
+	if raw != wavStreamSize || s == nil {
+		return int64(raw)
+	}
+	if id == "data" && s.data > 0 {
+		return s.data
+	}
+	if size, ok := s.chunks[id]; ok {
+		return size
+	}
+	return int64(raw)
+}
+
+// isWAVHeader reports whether header starts a RIFF, RF64 or BW64 WAVE file.
+func isWAVHeader(header []byte) bool {
+	if len(header) < 12 || string(header[8:12]) != "WAVE" {
+		return false
+	}
+	switch string(header[0:4]) {
+	case "RIFF", "RF64", "BW64":
+		return true
+	}
+	return false
+}
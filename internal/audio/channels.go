@@ -0,0 +1,80 @@
+package audio
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ChannelMode selects which signals are derived from the decoded channels.
+type ChannelMode string
+
+// Channel modes.
+const (
+	ChannelsMix   ChannelMode = "mix"
+	ChannelsLeft  ChannelMode = "left"
+	ChannelsRight ChannelMode = "right"
+	ChannelsMid   ChannelMode = "mid"
+	ChannelsSide  ChannelMode = "side"
+	ChannelsAll   ChannelMode = "all"
+)
+
+// Signal is a named mono signal derived from decoded audio.
+type Signal struct {
+	Name    string
+	Samples []float64
+}
+
+// ParseChannelMode validates a channel mode name.
+func ParseChannelMode(name string) (ChannelMode, error) {
+	mode := ChannelMode(strings.ToLower(strings.TrimSpace(name)))
+	switch mode {
+	case "":
+		return ChannelsMix, nil
+	case ChannelsMix, ChannelsLeft, ChannelsRight, ChannelsMid, ChannelsSide, ChannelsAll:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown channel mode %q (use mix, left, right, mid, side, all)", name)
+	}
+}
+
+// Signals derives the mono signals for mode. Mono input has identical left,
+// right and mid signals and a silent side signal.
+func Signals(a Audio, mode ChannelMode) []Signal {
+	channels := a.Channels
+	if len(channels) == 0 {
+		channels = [][]float64{a.Samples}
+	}
+	left := channels[0]
+	right := left
+	if len(channels) > 1 {
+		right = channels[1]
+	}
+
+	switch mode {
+	case ChannelsLeft:
+		return []Signal{{Name: "left", Samples: left}}
+	case ChannelsRight:
+		return []Signal{{Name: "right", Samples: right}}
+	case ChannelsMid, ChannelsSide:
+		out := make([]float64, len(left))
+		sign := 1.0
+		if mode == ChannelsSide {
+			sign = -1
+		}
+		for i := range out {
+			out[i] = (left[i] + sign*right[i]) / 2
+		}
+		return []Signal{{Name: string(mode), Samples: out}}
+	case ChannelsAll:
+		if len(channels) == 2 {
+			return []Signal{{Name: "left", Samples: left}, {Name: "right", Samples: right}}
+		}
+		signals := make([]Signal, len(channels))
+		for i, ch := range channels {
+			signals[i] = Signal{Name: fmt.Sprintf("ch%d", i+1), Samples: ch}
+		}
+		return signals
+	default:
+		return []Signal{{Name: "mix", Samples: a.Samples}}
+	}
+}
@@ -0,0 +1,83 @@
+package audio
+
+import "testing"
+
+func TestDecodeWAVKeepsChannels(t *testing.T) {
+	data := makeWAV([]int16{16384, -16384, 8192, 0}, 44100, 2)
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	if pcm.NumChannels() != 2 {
+		t.Fatalf("channels = %d", pcm.NumChannels())
+	}
+	if pcm.Channels[0][0] != 0.5 || pcm.Channels[1][0] != -0.5 || pcm.Channels[0][1] != 0.25 {
+		t.Fatalf("unexpected channels: %v", pcm.Channels)
+	}
+	if pcm.Samples[0] != 0 || pcm.Samples[1] != 0.125 {
+		t.Fatalf("unexpected mix: %v", pcm.Samples)
+	}
+}
+
+func TestSignals(t *testing.T) {
+	a := NewAudio(10, [][]float64{{1, 0.5}, {0, 0.5}})
+	cases := []struct {
+		mode  ChannelMode
+		name  string
+		first float64
+	}{
+		{ChannelsMix, "mix", 0.5},
+		{ChannelsLeft, "left", 1},
+		{ChannelsRight, "right", 0},
+		{ChannelsMid, "mid", 0.5},
+		{ChannelsSide, "side", 0.5},
+	}
+	for _, c := range cases {
+		signals := Signals(a, c.mode)
+		if len(signals) != 1 || signals[0].Name != c.name || signals[0].Samples[0] != c.first {
+			t.Fatalf("%s: unexpected signals %+v", c.mode, signals)
+		}
+	}
+	all := Signals(a, ChannelsAll)
+	if len(all) != 2 || all[0].Name != "left" || all[1].Name != "right" {
+		t.Fatalf("all: unexpected signals %+v", all)
+	}
+}
+
+func TestSignalsMonoAndMultichannel(t *testing.T) {
+	mono := Audio{SampleRate: 10, Samples: []float64{0.25, -0.25}}
+	if s := Signals(mono, ChannelsRight); s[0].Samples[0] != 0.25 {
+		t.Fatalf("mono right = %v", s[0].Samples)
+	}
+	if s := Signals(mono, ChannelsSide); s[0].Samples[0] != 0 {
+		t.Fatalf("mono side = %v", s[0].Samples)
+	}
+	surround := NewAudio(10, [][]float64{{1}, {2}, {3}})
+	all := Signals(surround, ChannelsAll)
+	if len(all) != 3 || all[2].Name != "ch3" {
+		t.Fatalf("surround all = %+v", all)
+	}
+}
+
+func TestParseChannelMode(t *testing.T) {
+	if mode, err := ParseChannelMode(" Side "); err != nil || mode != ChannelsSide {
+		t.Fatalf("ParseChannelMode = %q, %v", mode, err)
+	}
+	if mode, err := ParseChannelMode(""); err != nil || mode != ChannelsMix {
+		t.Fatalf("ParseChannelMode empty = %q, %v", mode, err)
+	}
+	if _, err := ParseChannelMode("surround"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestSliceKeepsChannels(t *testing.T) {
+	a := NewAudio(10, [][]float64{{0, 1, 2, 3}, {4, 5, 6, 7}})
+	out, err := Slice(a, 0.1, 0.2)
+	if err != nil {
+		t.Fatalf("Slice: %v", err)
+	}
+	if len(out.Channels) != 2 || out.Channels[1][0] != 5 || len(out.Channels[1]) != 2 {
+		t.Fatalf("unexpected slice: %v", out.Channels)
+	}
+}
@@ -1,17 +1,71 @@
-// Package audio handles decoding audio into mono float samples.
 package audio
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 )
 
-// DecodeFile reads an audio file, decoding WAV/MP3 and falling back to ffmpeg.
-func DecodeFile(path string, opts Options) (Audio, error) {
+// nativeDecoder is a built-in decoder; ok=false means the input is not its
+// format.
+type nativeDecoder struct {
+	exts   []string
+	decode func(r io.ReadSeeker, opts Options) (Audio, bool, error)
+}
+
+// nativeDecoders lists the built-in decoders in probe order. WAV and MP3
+// seek to opts.Start themselves, FLAC and Ogg stop decoding once the range is
+// complete, and AIFF decodes everything and slices. All of them refuse
+// inputs longer than opts.MaxDuration before decoding when the header
+// declares the length.
+var nativeDecoders = []nativeDecoder{
+	{[]string{".wav", ".wave"}, decodeWAVIf},
+	{[]string{".aif", ".aiff", ".aifc"}, decodeAIFFIf},
+	{[]string{".flac"}, decodeFLACIf},
+	{[]string{".ogg", ".oga"}, decodeOggIf},
+	{[]string{".mp3"}, decodeMP3If},
+}
+
+// decodeNative tries the decoder matching ext first, then probes them all.
+func decodeNative(r io.ReadSeeker, ext string, opts Options) (Audio, bool, error) {
+	for _, d := range nativeDecoders {
+		if !slices.Contains(d.exts, ext) {
+			continue
+		}
+		if pcm, ok, err := d.decode(r, opts); ok {
+			return pcm, ok, err
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return Audio{}, true, err
+		}
+	}
+	for _, d := range nativeDecoders {
+		if pcm, ok, err := d.decode(r, opts); ok {
+			return pcm, ok, err
+		}
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return Audio{}, true, err
+		}
+	}
+	return Audio{}, false, nil
+}
+
+// DecodeFile reads an audio file, decoding WAV/AIFF/FLAC/Ogg/MP3 and falling back to ffmpeg.
+// Cancelling ctx stops the ffmpeg fallback.
+func DecodeFile(ctx context.Context, path string, opts Options) (Audio, error) {
+	if err := opts.checkRange(); err != nil {
+		return Audio{}, err
+	}
+	if err := contextError(ctx); err != nil {
+		return Audio{}, err
+	}
 	file, err := os.Open(path)
 	if err != nil {
 		return Audio{}, err
@@ -19,60 +73,154 @@ func DecodeFile(path string, opts Options) (Audio, error) {
 	defer func() { _ = file.Close() }()
 
 	ext := strings.ToLower(filepath.Ext(path))
-	if ext == ".wav" || ext == ".wave" {
-		if pcm, ok, err := DecodeWAVIf(file); ok {
-			return pcm, err
-		}
-	}
-	if ext == ".mp3" {
-		if pcm, ok, err := DecodeMP3If(file); ok {
-			return pcm, err
-		}
+	if pcm, ok, err := decodeNative(file, ext, opts); ok {
+		return checkDecoded(ctx, pcm, err, opts)
 	}
+	return decodeFFmpegFallback(ctx, path, nil, opts)
+}
 
-	if pcm, ok, err := DecodeWAVIf(file); ok {
-		return pcm, err
+// DecodeBytes decodes audio data from a byte slice.
+func DecodeBytes(ctx context.Context, data []byte, opts Options) (Audio, error) {
+	if err := opts.checkRange(); err != nil {
+		return Audio{}, err
 	}
-	if pcm, ok, err := DecodeMP3If(file); ok {
-		return pcm, err
+	if err := contextError(ctx); err != nil {
+		return Audio{}, err
 	}
+	if pcm, ok, err := decodeNative(bytes.NewReader(data), "", opts); ok {
+		return checkDecoded(ctx, pcm, err, opts)
+	}
+	return decodeFFmpegFallback(ctx, "", bytes.NewReader(data), opts)
+}
 
+func decodeFFmpegFallback(ctx context.Context, path string, stdin io.Reader, opts Options) (Audio, error) {
 	if opts.SampleRate == 0 {
 		opts.SampleRate = 44100
 	}
-	pcm, err := DecodeWithFFmpeg(path, nil, opts.SampleRate, opts.FFmpegPath)
+	pcm, err := DecodeWithFFmpeg(ctx, path, stdin, opts)
+	if errors.Is(err, ErrTimeout) || errors.Is(err, ErrTooLong) || errors.Is(err, context.Canceled) {
+		return Audio{}, err
+	}
 	if err != nil {
 		return Audio{}, fmt.Errorf("%w; ffmpeg fallback failed: %v", ErrUnsupported, err)
 	}
+	if opts.hasRange() && len(pcm.Samples) == 0 {
+		return Audio{}, errors.New("slice: start beyond end")
+	}
 	return pcm, nil
 }
 
-// DecodeBytes decodes audio data from a byte slice.
-func DecodeBytes(data []byte, opts Options) (Audio, error) {
-	reader := bytes.NewReader(data)
-	if pcm, ok, err := DecodeWAVIf(reader); ok {
-		return pcm, err
-	}
-	reader.Reset(data)
-	if pcm, ok, err := DecodeMP3If(reader); ok {
-		return pcm, err
+// checkDecoded applies the deadline and duration limit to a native decode.
+// Decoders check the limit up front when the header declares the length;
+// this catches inputs that do not.
+func checkDecoded(ctx context.Context, pcm Audio, err error, opts Options) (Audio, error) {
+	if err != nil {
+		return Audio{}, err
 	}
-
-	if opts.SampleRate == 0 {
-		opts.SampleRate = 44100
+	if err := contextError(ctx); err != nil {
+		return Audio{}, err
 	}
-	pcm, err := DecodeWithFFmpeg("", bytes.NewReader(data), opts.SampleRate, opts.FFmpegPath)
-	if err != nil {
-		return Audio{}, fmt.Errorf("%w; ffmpeg fallback failed: %v", ErrUnsupported, err)
+	if limit := opts.maxFrames(pcm.SampleRate); limit >= 0 && len(pcm.Samples) > limit {
+		return Audio{}, tooLongError(opts)
 	}
 	return pcm, nil
 }
 
-// DecodeReader decodes audio data from an io.Reader.
-func DecodeReader(r io.Reader, opts Options) (Audio, error) {
-	data, err := io.ReadAll(r)
+// sniffSize is how many leading bytes DecodeReader inspects to pick a decoder.
+const sniffSize = 64
+
+// DecodeReader decodes audio data from an io.Reader without buffering it
+// first: WAV, AIFF, FLAC, Ogg Vorbis/FLAC and MP3 stream through the native
+// decoders and other formats stream into ffmpeg's stdin. WAV and AIFF must
+// carry their format chunk before the sample data. With opts.Duration set,
+// decoding stops once the range is complete, so r may be an endless stream.
+func DecodeReader(ctx context.Context, r io.Reader, opts Options) (Audio, error) {
+	if err := opts.checkRange(); err != nil {
+		return Audio{}, err
+	}
+	if err := contextError(ctx); err != nil {
+		return Audio{}, err
+	}
+	br := bufio.NewReaderSize(r, 64*1024)
+	head, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF {
+		return Audio{}, err
+	}
+
+	format := sniffFormat(head)
+	if size := id3Size(head); size > 0 {
+		// ID3v2 can front FLAC as well as MP3. A tag too large to peek past
+		// is discarded; both decoders accept a stream without it.
+		tagged, err := br.Peek(size + 4)
+		if err == bufio.ErrBufferFull {
+			if _, err := br.Discard(size); err != nil {
+				return Audio{}, err
+			}
+			tagged, err = br.Peek(4)
+			size = 0
+		}
+		if err == nil && string(tagged[size:]) == "fLaC" {
+			format = "flac"
+		}
+	}
+
+	var src Source
+	switch format {
+	case "wav":
+		src, err = NewWAVSource(br)
+	case "mp3":
+		src, err = NewMP3Source(br)
+	case "aiff":
+		src, err = NewAIFFSource(br)
+	case "flac":
+		src, err = NewFLACSource(br)
+	case "ogg":
+		if codec := peekOggCodec(br); codec != "vorbis" && codec != "flac" {
+			return decodeFFmpegFallback(ctx, "", br, opts)
+		}
+		src, err = NewOggSource(br)
+	default:
+		return decodeFFmpegFallback(ctx, "", br, opts)
+	}
 	if err != nil {
 		return Audio{}, err
 	}
-	return DecodeBytes(data, opts)
+	defer func() { _ = src.Close() }()
+	return readSource(ctx, src, opts)
+}
+
+// peekOggCodec identifies the codec of the first Ogg page in br without
+// consuming it, so the stream can still go to ffmpeg.
+func peekOggCodec(br *bufio.Reader) string {
+	page, _ := br.Peek(27)
+	if len(page) < 27 {
+		return ""
+	}
+	start := 27 + int(page[26])
+	page, _ = br.Peek(start + 8)
+	if len(page) < start {
+		return ""
+	}
+	return oggCodec(page[start:])
+}
+
+// sniffFormat names the native format that head starts with, or returns ""
+// when ffmpeg has to decode it.
+func sniffFormat(head []byte) string {
+	switch {
+	case isWAVHeader(head):
+		return "wav"
+	case len(head) >= 12 && string(head[0:4]) == "FORM" && (string(head[8:12]) == "AIFF" || string(head[8:12]) == "AIFC"):
+		return "aiff"
+	case len(head) >= 4 && string(head[0:4]) == "fLaC":
+		return "flac"
+	case len(head) >= 4 && string(head[0:4]) == "OggS":
+		return "ogg"
+	case len(head) >= 3 && string(head[0:3]) == "ID3":
+		return "mp3"
+	}
+	if _, ok := parseMP3FrameHeader(head); ok {
+		return "mp3"
+	}
+	return ""
 }
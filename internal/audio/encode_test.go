@@ -0,0 +1,116 @@
+package audio
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeWAVRoundTrip(t *testing.T) {
+	left := []float64{0, 0.5, -0.5, 1, -1, 0.123}
+	right := []float64{0.25, -0.25, 0.75, -0.75, 0, 0.001}
+	center := []float64{1, 0, -1, 0, 1, 0}
+	for _, tc := range []struct {
+		enc   WAVEncoding
+		bits  int
+		codec string
+		tol   float64
+	}{
+		{WAVPCM16, 16, "pcm", 1.0 / (1 << 15)},
+		{WAVPCM24, 24, "pcm", 1.0 / (1 << 23)},
+		{WAVFloat32, 32, "float", 1e-7},
+	} {
+		for _, channels := range [][][]float64{{left}, {left, right}, {left, right, center}} {
+			buf := &bytes.Buffer{}
+			if err := EncodeWAV(buf, NewAudio(22050, channels), tc.enc); err != nil {
+				t.Fatalf("%s: %v", tc.enc, err)
+			}
+			pcm, err := DecodeBytes(t.Context(), buf.Bytes(), Options{})
+			if err != nil {
+				t.Fatalf("%s/%d: decode: %v", tc.enc, len(channels), err)
+			}
+			if pcm.SampleRate != 22050 || pcm.NumChannels() != len(channels) {
+				t.Fatalf("%s/%d: got %d Hz, %d channels", tc.enc, len(channels), pcm.SampleRate, pcm.NumChannels())
+			}
+			for ch := range channels {
+				for i, want := range channels[ch] {
+					if got := pcm.Channels[ch][i]; math.Abs(got-want) > tc.tol {
+						t.Fatalf("%s/%d ch%d[%d]: got %v want %v", tc.enc, len(channels), ch, i, got, want)
+					}
+				}
+			}
+
+			path := writeTemp(t, "a.wav", buf.Bytes())
+			info, err := Probe(t.Context(), path, Options{})
+			if err != nil || info.BitDepth != tc.bits || info.Codec != tc.codec {
+				t.Fatalf("%s: probe %+v %v", tc.enc, info, err)
+			}
+		}
+	}
+}
+
+func TestEncodeWAVExactPCM(t *testing.T) {
+	// Samples that came from 16-bit PCM survive a round trip unchanged.
+	samples := []float64{-1, -0.5, 0, 1.0 / (1 << 15), 32767.0 / (1 << 15)}
+	buf := &bytes.Buffer{}
+	if err := EncodeWAV(buf, NewAudio(8000, [][]float64{samples}), WAVPCM16); err != nil {
+		t.Fatalf("EncodeWAV: %v", err)
+	}
+	pcm, err := DecodeBytes(t.Context(), buf.Bytes(), Options{})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	for i, want := range samples {
+		if pcm.Samples[i] != want {
+			t.Fatalf("sample %d: got %v want %v", i, pcm.Samples[i], want)
+		}
+	}
+}
+
+func TestEncodeWAVClipsAndPads(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := EncodeWAV(buf, NewAudio(8000, [][]float64{{2, -3, math.NaN()}}), WAVPCM24); err != nil {
+		t.Fatalf("EncodeWAV: %v", err)
+	}
+	if buf.Len()%2 != 0 {
+		t.Fatalf("odd file size %d", buf.Len())
+	}
+	pcm, err := DecodeBytes(t.Context(), buf.Bytes(), Options{})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if pcm.Samples[0] != float64(1<<23-1)/(1<<23) || pcm.Samples[1] != -1 || pcm.Samples[2] != 0 {
+		t.Fatalf("unexpected samples: %v", pcm.Samples)
+	}
+}
+
+func TestEncodeWAVRF64Header(t *testing.T) {
+	data := wavHeader(1, 8000, 16, 1, 2, 4, true)
+	data = append(data, 0x00, 0x40, 0x00, 0xC0)
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if string(data[0:4]) != "RF64" || len(pcm.Samples) != 2 || pcm.Samples[0] != 0.5 || pcm.Samples[1] != -0.5 {
+		t.Fatalf("unexpected RF64 decode: %v", pcm.Samples)
+	}
+}
+
+func TestEncodeWAVErrors(t *testing.T) {
+	if err := EncodeWAV(&bytes.Buffer{}, Audio{Samples: []float64{0}}, WAVPCM16); err == nil {
+		t.Fatalf("expected error for missing sample rate")
+	}
+	if err := EncodeWAV(&bytes.Buffer{}, Audio{SampleRate: 8000, Channels: [][]float64{{0}, {}}}, WAVPCM16); err == nil {
+		t.Fatalf("expected error for ragged channels")
+	}
+	if err := WriteWAVFile(filepath.Join(t.TempDir(), "missing", "a.wav"), NewAudio(8000, [][]float64{{0}}), WAVPCM16); err == nil {
+		t.Fatalf("expected error for bad path")
+	}
+	if _, err := ParseWAVEncoding("pcm8"); err == nil {
+		t.Fatalf("expected error for unknown encoding")
+	}
+	if enc, err := ParseWAVEncoding(" Float32 "); err != nil || enc != WAVFloat32 {
+		t.Fatalf("ParseWAVEncoding: %v %v", enc, err)
+	}
+}
@@ -1,58 +1,142 @@
-// Package audio handles decoding audio into mono float samples.
 package audio
 
 import (
 	"bytes"
-	"encoding/binary"
+	"context"
 	"fmt"
 	"io"
-	"math"
 	"os/exec"
+	"strconv"
+	"time"
 )
 
-// DecodeWithFFmpeg uses ffmpeg to decode any input into mono float samples.
-func DecodeWithFFmpeg(path string, stdin io.Reader, sampleRate int, ffmpegPath string) (Audio, error) {
-	if sampleRate <= 0 {
-		sampleRate = 44100
-	}
-	ffmpeg, err := resolveFFmpeg(ffmpegPath)
+// DecodeWithFFmpeg uses ffmpeg to decode any input into float samples, keeping
+// the source channel layout. opts.Start and opts.Duration are passed on as
+// -ss and -t so ffmpeg seeks instead of decoding the whole input. ffmpeg is
+// killed when ctx is done or the output passes opts.MaxDuration.
+func DecodeWithFFmpeg(ctx context.Context, path string, stdin io.Reader, opts Options) (Audio, error) {
+	src, err := NewFFmpegSource(ctx, path, stdin, opts)
 	if err != nil {
 		return Audio{}, err
 	}
+	defer func() { _ = src.Close() }()
+	// ffmpeg already applied the range through -ss and -t.
+	return readSource(ctx, src, Options{MaxDuration: opts.MaxDuration})
+}
 
-	args := []string{"-hide_banner", "-loglevel", "error"}
-	if stdin != nil {
-		args = append(args, "-i", "pipe:0")
-	} else {
-		args = append(args, "-i", path)
+// ffmpegSource streams WAV output from an ffmpeg process.
+type ffmpegSource struct {
+	Source
+	ctx    context.Context
+	cmd    *exec.Cmd
+	stdout io.ReadCloser
+	stderr *bytes.Buffer
+	waited bool
+}
+
+// NewFFmpegSource starts ffmpeg on path (or stdin when non-nil) and streams
+// its decoded output. The process is killed when ctx is done; Close must be
+// called to reap it.
+func NewFFmpegSource(ctx context.Context, path string, stdin io.Reader, opts Options) (Source, error) {
+	if opts.SampleRate <= 0 {
+		opts.SampleRate = 44100
+	}
+	ffmpeg, err := resolveFFmpeg(opts.FFmpegPath)
+	if err != nil {
+		return nil, err
 	}
-	args = append(args, "-f", "f32le", "-ac", "1", "-ar", fmt.Sprintf("%d", sampleRate), "-")
 
-	cmd := exec.Command(ffmpeg, args...)
+	cmd := exec.CommandContext(ctx, ffmpeg, ffmpegArgs(path, stdin != nil, opts)...)
 	if stdin != nil {
 		cmd.Stdin = stdin
 	}
-	var stderr bytes.Buffer
-	cmd.Stderr = &stderr
-	out, err := cmd.Output()
+	// Do not let a blocked stdin copy hold up Wait after ffmpeg is killed.
+	cmd.WaitDelay = time.Second
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	src := &ffmpegSource{ctx: ctx, cmd: cmd, stdout: stdout, stderr: stderr}
+	wav, err := NewWAVSource(stdout)
 	if err != nil {
-		if stderr.Len() > 0 {
-			return Audio{}, fmt.Errorf("ffmpeg: %v: %s", err, stderr.String())
+		if waitErr := src.wait(); waitErr != nil {
+			return nil, waitErr
 		}
-		return Audio{}, err
+		return nil, fmt.Errorf("ffmpeg: %w", err)
 	}
+	src.Source = wav
+	return src, nil
+}
 
-	if len(out)%4 != 0 {
-		return Audio{}, fmt.Errorf("ffmpeg: unexpected pcm length")
+func (s *ffmpegSource) Read(buf [][]float64) (int, error) {
+	n, err := s.Source.Read(buf)
+	if err == io.EOF {
+		if waitErr := s.wait(); waitErr != nil {
+			return n, waitErr
+		}
+	} else if err != nil && s.ctx.Err() != nil {
+		return n, contextError(s.ctx)
 	}
+	return n, err
+}
 
-	samples := make([]float64, len(out)/4)
-	for i := 0; i < len(samples); i++ {
-		bits := binary.LittleEndian.Uint32(out[i*4 : i*4+4])
-		samples[i] = float64(math.Float32frombits(bits))
+// Close stops ffmpeg if it is still running and waits for it to exit.
+func (s *ffmpegSource) Close() error {
+	if s.waited {
+		return nil
 	}
+	_ = s.stdout.Close()
+	if s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+	}
+	s.waited = true
+	_ = s.cmd.Wait()
+	return nil
+}
+
+func (s *ffmpegSource) wait() error {
+	if s.waited {
+		return nil
+	}
+	s.waited = true
+	// Drain anything left so ffmpeg is not blocked writing to the pipe.
+	_, _ = io.Copy(io.Discard, s.stdout)
+	if err := s.cmd.Wait(); err != nil {
+		if ctxErr := contextError(s.ctx); ctxErr != nil {
+			return ctxErr
+		}
+		if s.stderr.Len() > 0 {
+			return fmt.Errorf("ffmpeg: %v: %s", err, s.stderr.String())
+		}
+		return err
+	}
+	return nil
+}
+
+func ffmpegArgs(path string, stdin bool, opts Options) []string {
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	if opts.Start > 0 {
+		// Before -i, -ss seeks the input rather than decoding up to it.
+		args = append(args, "-ss", formatSeconds(opts.Start))
+	}
+	if opts.Duration > 0 {
+		args = append(args, "-t", formatSeconds(opts.Duration))
+	}
+	if stdin {
+		args = append(args, "-i", "pipe:0")
+	} else {
+		args = append(args, "-i", path)
+	}
+	return append(args, "-f", "wav", "-c:a", "pcm_f32le", "-ar", fmt.Sprintf("%d", opts.SampleRate), "-")
+}
 
-	return Audio{SampleRate: sampleRate, Samples: samples}, nil
+func formatSeconds(sec float64) string {
+	return strconv.FormatFloat(sec, 'f', -1, 64)
 }
 
 func resolveFFmpeg(path string) (string, error) {
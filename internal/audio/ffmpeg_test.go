@@ -2,9 +2,17 @@ package audio
 
 import (
 	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestResolveFFmpeg(t *testing.T) {
@@ -44,7 +52,7 @@ func TestDecodeWithFFmpegFile(t *testing.T) {
 	if err := os.WriteFile(input, []byte("audio"), 0o644); err != nil {
 		t.Fatalf("WriteFile: %v", err)
 	}
-	pcm, err := DecodeWithFFmpeg(input, nil, 22050, ffmpegPath)
+	pcm, err := DecodeWithFFmpeg(t.Context(), input, nil, Options{SampleRate: 22050, FFmpegPath: ffmpegPath})
 	if err != nil {
 		t.Fatalf("DecodeWithFFmpeg: %v", err)
 	}
@@ -54,11 +62,14 @@ func TestDecodeWithFFmpegFile(t *testing.T) {
 	if len(pcm.Samples) == 0 {
 		t.Fatalf("empty samples")
 	}
+	if pcm.NumChannels() != 2 || pcm.Channels[0][1] != 0.25 || pcm.Samples[0] != 0 {
+		t.Fatalf("unexpected channels: %v", pcm.Channels)
+	}
 }
 
 func TestDecodeWithFFmpegStdin(t *testing.T) {
 	ffmpegPath := installFakeFFmpeg(t)
-	pcm, err := DecodeWithFFmpeg("", bytes.NewReader([]byte("audio")), 44100, ffmpegPath)
+	pcm, err := DecodeWithFFmpeg(t.Context(), "", bytes.NewReader([]byte("audio")), Options{SampleRate: 44100, FFmpegPath: ffmpegPath})
 	if err != nil {
 		t.Fatalf("DecodeWithFFmpeg stdin: %v", err)
 	}
@@ -68,7 +79,7 @@ func TestDecodeWithFFmpegStdin(t *testing.T) {
 }
 
 func TestDecodeWithFFmpegBadPath(t *testing.T) {
-	_, err := DecodeWithFFmpeg("missing.mp3", nil, 0, "/no/such/ffmpeg")
+	_, err := DecodeWithFFmpeg(t.Context(), "missing.mp3", nil, Options{FFmpegPath: "/no/such/ffmpeg"})
 	if err == nil {
 		t.Fatalf("expected error")
 	}
@@ -78,9 +89,107 @@ func installFakeFFmpeg(t *testing.T) string {
 	t.Helper()
 	dir := t.TempDir()
 	path := filepath.Join(dir, "ffmpeg")
-	script := "#!/bin/sh\nprintf '\\x00\\x00\\x00\\x00\\x00\\x00\\x00\\x3f'\n"
-	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+	// Stereo float WAV at 22050 Hz with unset sizes, as ffmpeg writes to a pipe.
+	wav := []byte("RIFF\xff\xff\xff\xffWAVEfmt ")
+	wav = binary.LittleEndian.AppendUint32(wav, 16)
+	wav = binary.LittleEndian.AppendUint16(wav, 3)
+	wav = binary.LittleEndian.AppendUint16(wav, 2)
+	wav = binary.LittleEndian.AppendUint32(wav, 22050)
+	wav = binary.LittleEndian.AppendUint32(wav, 22050*8)
+	wav = binary.LittleEndian.AppendUint16(wav, 8)
+	wav = binary.LittleEndian.AppendUint16(wav, 32)
+	wav = append(wav, "data\xff\xff\xff\xff"...)
+	for _, v := range []float32{0.5, -0.5, 0.25, 0} {
+		wav = binary.LittleEndian.AppendUint32(wav, math.Float32bits(v))
+	}
+	var script strings.Builder
+	script.WriteString("#!/bin/sh\nprintf '")
+	for _, b := range wav {
+		_, _ = fmt.Fprintf(&script, "\\%03o", b)
+	}
+	script.WriteString("'\n")
+	if err := os.WriteFile(path, []byte(script.String()), 0o755); err != nil {
 		t.Fatalf("WriteFile: %v", err)
 	}
 	return path
 }
+
+func TestDecodeWithFFmpegTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\nexec sleep 10\n"), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := DecodeBytes(ctx, []byte("not audio at all"), Options{FFmpegPath: path})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("timeout took %s", time.Since(start))
+	}
+}
+
+func TestDecodeWithFFmpegMaxDuration(t *testing.T) {
+	ffmpegPath := installFakeFFmpeg(t)
+	opts := Options{SampleRate: 22050, FFmpegPath: ffmpegPath, MaxDuration: 1.0 / 22050}
+	_, err := DecodeBytes(t.Context(), []byte("audio"), opts)
+	if !errors.Is(err, ErrTooLong) {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+	opts.MaxDuration = 1
+	if _, err := DecodeBytes(t.Context(), []byte("audio"), opts); err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+}
+
+// headerOnlyReader serves the first n bytes of its data and fails any read
+// past them, so a decode that gets further than the header errors.
+type headerOnlyReader struct {
+	*bytes.Reader
+	n int64
+}
+
+func (r headerOnlyReader) Read(p []byte) (int, error) {
+	pos, _ := r.Seek(0, io.SeekCurrent)
+	if pos >= r.n {
+		return 0, os.ErrInvalid
+	}
+	return r.Reader.Read(p[:min(int64(len(p)), r.n-pos)])
+}
+
+func TestDecodeLimitsFromHeader(t *testing.T) {
+	cases := map[string]struct {
+		data   []byte
+		header int64
+	}{
+		"wav":  {makeWAV(make([]int16, 1000), 100, 1), 44},
+		"aiff": {makeAIFF("", 1, 16, 100, 1000, make([]byte, 2000)), 12 + 8 + 18 + 8},
+		"flac": {makeFLAC([][]int64{flacSine(1000, 16, 0.5)}, 16, 100, 256, 0, flacSubVerbatim), 4 + 4 + 34},
+	}
+	for name, tc := range cases {
+		r := headerOnlyReader{bytes.NewReader(tc.data), tc.header}
+		if _, _, err := decodeNative(r, "", Options{MaxDuration: 5}); !errors.Is(err, ErrTooLong) {
+			t.Fatalf("%s: expected ErrTooLong from the header, got %v", name, err)
+		}
+		// The range counts: 4s from 6s on fits the limit, so decoding
+		// starts and runs into the unreadable sample data.
+		_, _ = r.Seek(0, io.SeekStart)
+		if _, _, err := decodeNative(r, "", Options{MaxDuration: 5, Start: 6}); err == nil || errors.Is(err, ErrTooLong) {
+			t.Fatalf("%s: expected a read error, got %v", name, err)
+		}
+	}
+}
+
+func TestDecodeLimitsNative(t *testing.T) {
+	data := makeWAV(make([]int16, 100), 100, 1)
+	if _, err := DecodeBytes(t.Context(), data, Options{MaxDuration: 0.5}); !errors.Is(err, ErrTooLong) {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if _, err := DecodeBytes(ctx, data, Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
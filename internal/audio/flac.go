@@ -0,0 +1,835 @@
+package audio
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/bits"
+	"slices"
+)
+
+// DecodeFLACIf tries to decode FLAC data, returning ok=false when not FLAC.
+func DecodeFLACIf(r io.ReadSeeker) (Audio, bool, error) {
+	return decodeFLACIf(r, Options{})
+}
+
+func decodeFLACIf(r io.ReadSeeker, opts Options) (Audio, bool, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header[:4]); err != nil {
+		return Audio{}, false, err
+	}
+	isFLAC := string(header[0:4]) == "fLaC"
+	if !isFLAC && string(header[0:3]) == "ID3" {
+		// Some taggers prepend an ID3v2 tag; only claim the stream when the
+		// FLAC marker follows it.
+		if _, err := io.ReadFull(r, header[4:]); err == nil {
+			if _, err := r.Seek(int64(id3Size(header)), io.SeekStart); err == nil {
+				magic := make([]byte, 4)
+				_, err = io.ReadFull(r, magic)
+				isFLAC = err == nil && string(magic) == "fLaC"
+			}
+		}
+	}
+	_, _ = r.Seek(0, io.SeekStart)
+	if !isFLAC {
+		return Audio{}, false, nil
+	}
+	src, err := NewFLACSource(r)
+	if err != nil {
+		return Audio{}, true, err
+	}
+	pcm, err := readSource(context.Background(), src, opts)
+	if err != nil {
+		return Audio{}, true, err
+	}
+	return pcm, true, nil
+}
+
+// id3Size returns the total size of a leading ID3v2 tag, including its header.
+func id3Size(header []byte) int {
+	if len(header) < 10 || string(header[0:3]) != "ID3" {
+		return 0
+	}
+	size := int(header[6]&0x7f)<<21 | int(header[7]&0x7f)<<14 | int(header[8]&0x7f)<<7 | int(header[9]&0x7f)
+	size += 10
+	if header[5]&0x10 != 0 {
+		size += 10
+	}
+	return size
+}
+
+type flacStreamInfo struct {
+	MinBlockSize  int
+	MaxBlockSize  int
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	TotalSamples  uint64
+	MD5           [16]byte
+}
+
+// NewFLACSource parses the FLAC metadata from r, skipping a leading ID3v2
+// tag, and decodes frames as they are read. The sample count and MD5 from
+// STREAMINFO are checked once the stream ends.
+func NewFLACSource(r io.Reader) (Source, error) {
+	magic := make([]byte, 10)
+	if _, err := io.ReadFull(r, magic[:4]); err != nil {
+		return nil, err
+	}
+	if string(magic[0:3]) == "ID3" {
+		if _, err := io.ReadFull(r, magic[4:]); err != nil {
+			return nil, errors.New("flac: truncated id3 tag")
+		}
+		if _, err := io.CopyN(io.Discard, r, int64(id3Size(magic)-10)); err != nil {
+			return nil, errors.New("flac: truncated id3 tag")
+		}
+		if _, err := io.ReadFull(r, magic[:4]); err != nil {
+			return nil, err
+		}
+	}
+	if string(magic[0:4]) != "fLaC" {
+		return nil, ErrUnsupported
+	}
+	info, err := readFLACMetadata(r)
+	if err != nil {
+		return nil, err
+	}
+	frames := &flacFrameReader{r: r, dec: flacFrameDecoder{info: info}}
+	return newFLACSource(info, frames.next), nil
+}
+
+// readFLACMetadata reads the metadata blocks that follow the "fLaC" marker.
+// Only STREAMINFO is kept; other blocks are skipped without buffering them.
+func readFLACMetadata(r io.Reader) (flacStreamInfo, error) {
+	meta := []byte("fLaC")
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return flacStreamInfo{}, errors.New("flac: truncated metadata")
+		}
+		length := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+		if header[0]&0x7f == 0 {
+			body, err := io.ReadAll(io.LimitReader(r, length))
+			if err != nil {
+				return flacStreamInfo{}, err
+			}
+			if int64(len(body)) < length {
+				return flacStreamInfo{}, errors.New("flac: truncated metadata block")
+			}
+			meta = append(append(meta, header...), body...)
+		} else {
+			if _, err := io.CopyN(io.Discard, r, length); err != nil {
+				return flacStreamInfo{}, errors.New("flac: truncated metadata block")
+			}
+			meta = append(meta, header[0], 0, 0, 0)
+		}
+		if header[0]&0x80 != 0 {
+			break
+		}
+	}
+	info, _, err := parseFLACMetadata(meta)
+	return info, err
+}
+
+// flacMaxFrameBytes caps how much input a single frame may span, so a
+// stream that never completes a frame cannot grow the buffer without bound.
+const flacMaxFrameBytes = 16 << 20
+
+// flacFrameReader splits a native FLAC stream into frames. It buffers only
+// the frame being decoded, reading more input when a frame is incomplete.
+type flacFrameReader struct {
+	r   io.Reader
+	dec flacFrameDecoder
+	mem []byte
+	buf []byte // unread bytes within mem
+	eof bool
+}
+
+// next decodes the next frame, or returns io.EOF once the frames end.
+func (f *flacFrameReader) next() ([][]int64, error) {
+	for {
+		if len(f.buf) >= 2 {
+			if f.buf[0] != 0xFF || f.buf[1]&0xFE != 0xF8 {
+				// Trailing tags or padding after the last frame.
+				return nil, io.EOF
+			}
+			n, block, err := f.dec.decodeFrame(f.buf)
+			if err == nil {
+				f.buf = f.buf[n:]
+				return block, nil
+			}
+			if err != io.ErrUnexpectedEOF || f.eof {
+				return nil, err
+			}
+		} else if f.eof {
+			return nil, io.EOF
+		}
+		if err := f.fill(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fill moves the unread bytes to the front of the buffer and reads more,
+// at least doubling what is buffered.
+func (f *flacFrameReader) fill() error {
+	if len(f.buf) >= flacMaxFrameBytes {
+		return errors.New("flac: frame too large")
+	}
+	want := max(64<<10, 2*len(f.buf))
+	if len(f.mem) < want {
+		f.mem = make([]byte, want)
+	}
+	n := copy(f.mem, f.buf)
+	m, err := io.ReadFull(f.r, f.mem[n:want])
+	f.buf = f.mem[:n+m]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		f.eof = true
+		return nil
+	}
+	return err
+}
+
+// newFLACSource converts frames from next into samples and validates the
+// stream against STREAMINFO when next reports io.EOF.
+func newFLACSource(info flacStreamInfo, next func() ([][]int64, error)) Source {
+	var (
+		hash  = md5.New()
+		total uint64
+		out   = make([][]float64, info.Channels)
+		scale = float64(int64(1) << (info.BitsPerSample - 1))
+	)
+	return &blockSource{
+		rate:     info.SampleRate,
+		channels: info.Channels,
+		meta:     Metadata{Codec: "flac"},
+		frames:   int64(info.TotalSamples),
+		next: func() ([][]float64, error) {
+			block, err := next()
+			if err == io.EOF {
+				return nil, checkFLACStream(info, total, hash)
+			}
+			if err != nil {
+				return nil, err
+			}
+			writeFLACHash(hash, block, info.BitsPerSample)
+			total += uint64(len(block[0]))
+			for ch, samples := range block {
+				out[ch] = slices.Grow(out[ch][:0], len(samples))[:len(samples)]
+				for i, v := range samples {
+					out[ch][i] = float64(v) / scale
+				}
+			}
+			return out, nil
+		},
+	}
+}
+
+// checkFLACStream compares the decoded sample count and MD5 with STREAMINFO.
+// It returns io.EOF when they match, or when STREAMINFO leaves them unset.
+func checkFLACStream(info flacStreamInfo, total uint64, hash hash.Hash) error {
+	if info.TotalSamples > 0 && total != info.TotalSamples {
+		return fmt.Errorf("flac: decoded %d samples, expected %d", total, info.TotalSamples)
+	}
+	if info.MD5 != ([16]byte{}) {
+		var sum [16]byte
+		copy(sum[:], hash.Sum(nil))
+		if sum != info.MD5 {
+			return errors.New("flac: md5 mismatch")
+		}
+	}
+	return io.EOF
+}
+
+func parseFLACMetadata(data []byte) (flacStreamInfo, int, error) {
+	var (
+		info  flacStreamInfo
+		found bool
+	)
+	pos := 4
+	for {
+		if pos+4 > len(data) {
+			return info, 0, errors.New("flac: truncated metadata")
+		}
+		last := data[pos]&0x80 != 0
+		blockType := data[pos] & 0x7f
+		length := int(data[pos+1])<<16 | int(data[pos+2])<<8 | int(data[pos+3])
+		pos += 4
+		if pos+length > len(data) {
+			return info, 0, errors.New("flac: truncated metadata block")
+		}
+		switch blockType {
+		case 0:
+			if length < 34 {
+				return info, 0, errors.New("flac: short streaminfo")
+			}
+			info = parseFLACStreamInfo(data[pos : pos+34])
+			found = true
+		case 127:
+			return info, 0, errors.New("flac: invalid metadata block")
+		}
+		pos += length
+		if last {
+			break
+		}
+	}
+	if !found {
+		return info, 0, errors.New("flac: missing streaminfo")
+	}
+	if info.Channels < 1 || info.Channels > 8 {
+		return info, 0, errors.New("flac: invalid channel count")
+	}
+	if info.BitsPerSample < 4 || info.BitsPerSample > 32 {
+		return info, 0, fmt.Errorf("flac: unsupported bit depth %d", info.BitsPerSample)
+	}
+	if info.SampleRate <= 0 {
+		return info, 0, errors.New("flac: invalid sample rate")
+	}
+	return info, pos, nil
+}
+
+func parseFLACStreamInfo(buf []byte) flacStreamInfo {
+	packed := binary.BigEndian.Uint64(buf[10:18])
+	info := flacStreamInfo{
+		MinBlockSize:  int(binary.BigEndian.Uint16(buf[0:2])),
+		MaxBlockSize:  int(binary.BigEndian.Uint16(buf[2:4])),
+		SampleRate:    int(packed >> 44),
+		Channels:      int(packed>>41&0x7) + 1,
+		BitsPerSample: int(packed>>36&0x1f) + 1,
+		TotalSamples:  packed & 0xfffffffff,
+	}
+	copy(info.MD5[:], buf[18:34])
+	return info
+}
+
+// writeFLACHash feeds interleaved little-endian samples into the MD5 hash,
+// matching the reference encoder's signature layout.
+func writeFLACHash(w io.Writer, block [][]int64, bitsPerSample int) {
+	width := (bitsPerSample + 7) / 8
+	frames := len(block[0])
+	buf := make([]byte, 0, frames*len(block)*width)
+	for i := 0; i < frames; i++ {
+		for ch := range block {
+			v := uint64(block[ch][i])
+			for b := 0; b < width; b++ {
+				buf = append(buf, byte(v>>(8*b)))
+			}
+		}
+	}
+	_, _ = w.Write(buf)
+}
+
+var (
+	flacBlockSizes = [16]int{0, 192, 576, 1152, 2304, 4608, 0, 0, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768}
+	flacDepths     = [8]int{0, 8, 12, 0, 16, 20, 24, 32}
+)
+
+type flacFrameDecoder struct {
+	info flacStreamInfo
+	buf  [][]int64
+}
+
+// decodeFrame decodes one frame and returns the consumed byte count along
+// with per-channel samples. The returned slices are reused between calls.
+func (d *flacFrameDecoder) decodeFrame(data []byte) (int, [][]int64, error) {
+	br := flacBits{buf: data}
+	sync, err := br.read(15)
+	if err != nil {
+		return 0, nil, err
+	}
+	if sync != 0x7FFC {
+		return 0, nil, errors.New("flac: lost frame sync")
+	}
+	if _, err := br.read(1); err != nil { // blocking strategy
+		return 0, nil, err
+	}
+	fields, err := br.read(16)
+	if err != nil {
+		return 0, nil, err
+	}
+	bsCode := int(fields >> 12 & 0xf)
+	srCode := int(fields >> 8 & 0xf)
+	chCode := int(fields >> 4 & 0xf)
+	ssCode := int(fields >> 1 & 0x7)
+	if fields&1 != 0 {
+		return 0, nil, errors.New("flac: reserved frame header bit set")
+	}
+	if err := br.skipUTF8(); err != nil {
+		return 0, nil, err
+	}
+
+	blockSize := flacBlockSizes[bsCode]
+	switch bsCode {
+	case 0:
+		return 0, nil, errors.New("flac: reserved block size")
+	case 6:
+		v, err := br.read(8)
+		if err != nil {
+			return 0, nil, err
+		}
+		blockSize = int(v) + 1
+	case 7:
+		v, err := br.read(16)
+		if err != nil {
+			return 0, nil, err
+		}
+		blockSize = int(v) + 1
+	}
+
+	switch {
+	case srCode == 12:
+		_, err = br.read(8)
+	case srCode == 13 || srCode == 14:
+		_, err = br.read(16)
+	case srCode == 15:
+		err = errors.New("flac: invalid sample rate code")
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	bps := d.info.BitsPerSample
+	if ssCode != 0 {
+		bps = flacDepths[ssCode]
+		if bps == 0 {
+			return 0, nil, errors.New("flac: reserved sample size")
+		}
+	}
+
+	channels := chCode + 1
+	if chCode >= 8 {
+		if chCode > 10 {
+			return 0, nil, errors.New("flac: reserved channel assignment")
+		}
+		channels = 2
+	}
+	if channels != d.info.Channels {
+		return 0, nil, errors.New("flac: channel count changed mid-stream")
+	}
+
+	headerLen := br.bytePos()
+	crc, err := br.read(8)
+	if err != nil {
+		return 0, nil, err
+	}
+	if byte(crc) != flacCRC8(data[:headerLen]) {
+		return 0, nil, errors.New("flac: frame header crc mismatch")
+	}
+
+	if len(d.buf) != channels {
+		d.buf = make([][]int64, channels)
+	}
+	for ch := 0; ch < channels; ch++ {
+		if cap(d.buf[ch]) < blockSize {
+			d.buf[ch] = make([]int64, blockSize)
+		}
+		d.buf[ch] = d.buf[ch][:blockSize]
+		chBits := bps
+		if ((chCode == 8 || chCode == 10) && ch == 1) || (chCode == 9 && ch == 0) {
+			// Side channels carry one extra bit.
+			chBits++
+		}
+		if err := decodeFLACSubframe(&br, d.buf[ch], chBits); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	br.align()
+	frameLen := br.bytePos()
+	footer, err := br.read(16)
+	if err != nil {
+		return 0, nil, err
+	}
+	if uint16(footer) != flacCRC16(data[:frameLen]) {
+		return 0, nil, errors.New("flac: frame crc mismatch")
+	}
+
+	switch chCode {
+	case 8: // left/side
+		left, side := d.buf[0], d.buf[1]
+		for i := range side {
+			side[i] = left[i] - side[i]
+		}
+	case 9: // side/right
+		side, right := d.buf[0], d.buf[1]
+		for i := range side {
+			side[i] += right[i]
+		}
+	case 10: // mid/side
+		mid, side := d.buf[0], d.buf[1]
+		for i := range mid {
+			m := mid[i]<<1 | side[i]&1
+			mid[i] = (m + side[i]) >> 1
+			side[i] = (m - side[i]) >> 1
+		}
+	}
+
+	return br.bytePos(), d.buf, nil
+}
+
+func decodeFLACSubframe(br *flacBits, out []int64, bps int) error {
+	header, err := br.read(8)
+	if err != nil {
+		return err
+	}
+	if header&0x80 != 0 {
+		return errors.New("flac: invalid subframe header")
+	}
+	kind := int(header >> 1 & 0x3f)
+	wasted := 0
+	if header&1 != 0 {
+		k, err := br.unary()
+		if err != nil {
+			return err
+		}
+		wasted = k + 1
+		bps -= wasted
+		if bps <= 0 {
+			return errors.New("flac: invalid wasted bits")
+		}
+	}
+
+	switch {
+	case kind == 0:
+		v, err := br.readSigned(uint(bps))
+		if err != nil {
+			return err
+		}
+		for i := range out {
+			out[i] = v
+		}
+	case kind == 1:
+		for i := range out {
+			v, err := br.readSigned(uint(bps))
+			if err != nil {
+				return err
+			}
+			out[i] = v
+		}
+	case kind >= 8 && kind <= 12:
+		if err := decodeFLACFixed(br, out, bps, kind-8); err != nil {
+			return err
+		}
+	case kind >= 32:
+		if err := decodeFLACLPC(br, out, bps, kind-31); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("flac: reserved subframe type %d", kind)
+	}
+
+	if wasted > 0 {
+		for i := range out {
+			out[i] <<= uint(wasted)
+		}
+	}
+	return nil
+}
+
+func decodeFLACFixed(br *flacBits, out []int64, bps, order int) error {
+	if order > len(out) {
+		return errors.New("flac: predictor order exceeds block size")
+	}
+	for i := 0; i < order; i++ {
+		v, err := br.readSigned(uint(bps))
+		if err != nil {
+			return err
+		}
+		out[i] = v
+	}
+	if err := decodeFLACResidual(br, out, order); err != nil {
+		return err
+	}
+	for i := order; i < len(out); i++ {
+		switch order {
+		case 1:
+			out[i] += out[i-1]
+		case 2:
+			out[i] += 2*out[i-1] - out[i-2]
+		case 3:
+			out[i] += 3*out[i-1] - 3*out[i-2] + out[i-3]
+		case 4:
+			out[i] += 4*out[i-1] - 6*out[i-2] + 4*out[i-3] - out[i-4]
+		}
+	}
+	return nil
+}
+
+func decodeFLACLPC(br *flacBits, out []int64, bps, order int) error {
+	if order > len(out) {
+		return errors.New("flac: predictor order exceeds block size")
+	}
+	for i := 0; i < order; i++ {
+		v, err := br.readSigned(uint(bps))
+		if err != nil {
+			return err
+		}
+		out[i] = v
+	}
+	precision, err := br.read(4)
+	if err != nil {
+		return err
+	}
+	if precision == 15 {
+		return errors.New("flac: invalid lpc precision")
+	}
+	shift, err := br.readSigned(5)
+	if err != nil {
+		return err
+	}
+	if shift < 0 {
+		return errors.New("flac: negative lpc shift")
+	}
+	coeffs := make([]int64, order)
+	for i := range coeffs {
+		c, err := br.readSigned(uint(precision + 1))
+		if err != nil {
+			return err
+		}
+		coeffs[i] = c
+	}
+	if err := decodeFLACResidual(br, out, order); err != nil {
+		return err
+	}
+	for i := order; i < len(out); i++ {
+		var sum int64
+		for j, c := range coeffs {
+			sum += c * out[i-1-j]
+		}
+		out[i] += sum >> uint(shift)
+	}
+	return nil
+}
+
+// decodeFLACResidual reads the Rice-coded residual into out[order:].
+func decodeFLACResidual(br *flacBits, out []int64, order int) error {
+	method, err := br.read(2)
+	if err != nil {
+		return err
+	}
+	var paramBits uint
+	switch method {
+	case 0:
+		paramBits = 4
+	case 1:
+		paramBits = 5
+	default:
+		return errors.New("flac: reserved residual coding method")
+	}
+	escape := uint64(1)<<paramBits - 1
+
+	partOrder, err := br.read(4)
+	if err != nil {
+		return err
+	}
+	partitions := 1 << partOrder
+	partSize := len(out) >> partOrder
+	if partSize<<partOrder != len(out) || partSize < order {
+		return errors.New("flac: invalid residual partition order")
+	}
+
+	idx := order
+	for p := 0; p < partitions; p++ {
+		count := partSize
+		if p == 0 {
+			count -= order
+		}
+		param, err := br.read(paramBits)
+		if err != nil {
+			return err
+		}
+		if param == escape {
+			raw, err := br.read(5)
+			if err != nil {
+				return err
+			}
+			for i := 0; i < count; i++ {
+				v, err := br.readSigned(uint(raw))
+				if err != nil {
+					return err
+				}
+				out[idx] = v
+				idx++
+			}
+			continue
+		}
+		for i := 0; i < count; i++ {
+			q, err := br.unary()
+			if err != nil {
+				return err
+			}
+			r, err := br.read(uint(param))
+			if err != nil {
+				return err
+			}
+			u := uint64(q)<<param | r
+			out[idx] = int64(u>>1) ^ -int64(u&1)
+			idx++
+		}
+	}
+	return nil
+}
+
+// flacBits is an MSB-first bit reader over an in-memory buffer.
+type flacBits struct {
+	buf   []byte
+	off   int
+	cache uint64
+	n     uint
+}
+
+func (b *flacBits) fill() {
+	for b.n <= 56 && b.off < len(b.buf) {
+		b.cache |= uint64(b.buf[b.off]) << (56 - b.n)
+		b.off++
+		b.n += 8
+	}
+}
+
+func (b *flacBits) read(n uint) (uint64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	if n > 32 {
+		hi, err := b.read(n - 32)
+		if err != nil {
+			return 0, err
+		}
+		lo, err := b.read(32)
+		if err != nil {
+			return 0, err
+		}
+		return hi<<32 | lo, nil
+	}
+	b.fill()
+	if b.n < n {
+		return 0, io.ErrUnexpectedEOF
+	}
+	v := b.cache >> (64 - n)
+	b.cache <<= n
+	b.n -= n
+	return v, nil
+}
+
+func (b *flacBits) readSigned(n uint) (int64, error) {
+	if n == 0 {
+		return 0, nil
+	}
+	v, err := b.read(n)
+	if err != nil {
+		return 0, err
+	}
+	shift := 64 - n
+	return int64(v<<shift) >> shift, nil
+}
+
+func (b *flacBits) unary() (int, error) {
+	count := 0
+	for {
+		b.fill()
+		if b.n == 0 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		lz := uint(bits.LeadingZeros64(b.cache))
+		if lz < b.n {
+			count += int(lz)
+			b.cache <<= lz + 1
+			b.n -= lz + 1
+			return count, nil
+		}
+		count += int(b.n)
+		b.cache = 0
+		b.n = 0
+	}
+}
+
+// skipUTF8 skips the UTF-8 style coded frame or sample number.
+func (b *flacBits) skipUTF8() error {
+	first, err := b.read(8)
+	if err != nil {
+		return err
+	}
+	extra := bits.LeadingZeros8(^uint8(first))
+	switch {
+	case extra == 0:
+		return nil
+	case extra == 1 || extra > 7:
+		return errors.New("flac: invalid coded number")
+	}
+	for i := 1; i < extra; i++ {
+		v, err := b.read(8)
+		if err != nil {
+			return err
+		}
+		if v&0xC0 != 0x80 {
+			return errors.New("flac: invalid coded number")
+		}
+	}
+	return nil
+}
+
+func (b *flacBits) align() {
+	drop := b.n % 8
+	b.cache <<= drop
+	b.n -= drop
+}
+
+// bytePos reports the number of whole bytes consumed so far.
+func (b *flacBits) bytePos() int {
+	return b.off - int(b.n/8)
+}
+
+var (
+	flacCRC8Table  = makeFLACCRC8Table()
+	flacCRC16Table = makeFLACCRC16Table()
+)
+
+func makeFLACCRC8Table() (t [256]byte) {
+	for i := range t {
+		c := byte(i)
+		for j := 0; j < 8; j++ {
+			if c&0x80 != 0 {
+				c = c<<1 ^ 0x07
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}
+
+func makeFLACCRC16Table() (t [256]uint16) {
+	for i := range t {
+		c := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if c&0x8000 != 0 {
+				c = c<<1 ^ 0x8005
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}
+
+func flacCRC8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc = flacCRC8Table[crc^b]
+	}
+	return crc
+}
+
+func flacCRC16(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc = crc<<8 ^ flacCRC16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
@@ -0,0 +1,333 @@
+package audio
+
+import (
+	"crypto/md5"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDecodeFLACFixedMono(t *testing.T) {
+	samples := flacSine(1000, 16, 0.5)
+	data := makeFLAC([][]int64{samples}, 16, 44100, 256, 0, flacSubFixed)
+	pcm, ok, err := DecodeFLACIf(bytesReader(data))
+	if err != nil {
+		t.Fatalf("DecodeFLACIf: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected ok=true")
+	}
+	if pcm.SampleRate != 44100 || pcm.Metadata.Codec != "flac" {
+		t.Fatalf("sample rate = %d, codec = %q", pcm.SampleRate, pcm.Metadata.Codec)
+	}
+	assertFLACSamples(t, pcm.Samples, [][]int64{samples}, 16)
+}
+
+func TestDecodeFLACStereoModes(t *testing.T) {
+	left := flacSine(700, 24, 0.4)
+	right := flacSine(700, 24, -0.3)
+	for _, tc := range []struct {
+		name   string
+		chCode int
+		kind   int
+	}{
+		{"independent-lpc", 1, flacSubLPC},
+		{"left-side", 8, flacSubVerbatim},
+		{"side-right", 9, flacSubFixed},
+		{"mid-side", 10, flacSubLPC},
+	} {
+		data := makeFLAC([][]int64{left, right}, 24, 48000, 192, tc.chCode, tc.kind)
+		pcm, err := DecodeBytes(t.Context(), data, Options{})
+		if err != nil {
+			t.Fatalf("%s: DecodeBytes: %v", tc.name, err)
+		}
+		assertFLACSamples(t, pcm.Samples, [][]int64{left, right}, 24)
+	}
+}
+
+func TestDecodeFLAC32BitSide(t *testing.T) {
+	left := []int64{math.MaxInt32, math.MinInt32, 0, 12345}
+	right := []int64{math.MinInt32, math.MaxInt32, -1, -12345}
+	data := makeFLAC([][]int64{left, right}, 32, 44100, 4, 8, flacSubVerbatim)
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	assertFLACSamples(t, pcm.Samples, [][]int64{left, right}, 32)
+}
+
+func TestDecodeFLACOddDepthConstant(t *testing.T) {
+	samples := make([]int64, 300)
+	for i := range samples {
+		samples[i] = -3
+	}
+	data := makeFLAC([][]int64{samples}, 12, 8000, 300, 0, flacSubConstant)
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	assertFLACSamples(t, pcm.Samples, [][]int64{samples}, 12)
+}
+
+func TestDecodeFLACMD5Mismatch(t *testing.T) {
+	data := makeFLAC([][]int64{flacSine(64, 16, 0.5)}, 16, 44100, 64, 0, flacSubVerbatim)
+	// MD5 lives in the last 16 bytes of STREAMINFO.
+	data[8+34-1] ^= 0xFF
+	if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
+		t.Fatalf("expected md5 error")
+	}
+}
+
+func TestDecodeFLACCorruptFrame(t *testing.T) {
+	data := makeFLAC([][]int64{flacSine(64, 16, 0.5)}, 16, 44100, 64, 0, flacSubVerbatim)
+	data[len(data)-5] ^= 0x10
+	if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
+		t.Fatalf("expected crc error")
+	}
+}
+
+func TestDecodeFLACBogusTotalSamples(t *testing.T) {
+	data := makeFLAC([][]int64{flacSine(64, 16, 0.5)}, 16, 44100, 64, 0, flacSubVerbatim)
+	// Claim the maximum 36-bit sample count; the low nibble of STREAMINFO
+	// byte 13 and bytes 14-17 hold it.
+	data[8+13] |= 0x0F
+	for i := 8 + 14; i < 8+18; i++ {
+		data[i] = 0xFF
+	}
+	if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
+		t.Fatalf("expected sample count error")
+	}
+}
+
+func TestDecodeFLACWithID3(t *testing.T) {
+	flac := makeFLAC([][]int64{flacSine(100, 16, 0.5)}, 16, 44100, 100, 0, flacSubFixed)
+	tag := []byte{'I', 'D', '3', 4, 0, 0, 0, 0, 0, 4, 0, 0, 0, 0}
+	path := filepath.Join(t.TempDir(), "tagged.flac")
+	if err := os.WriteFile(path, append(tag, flac...), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	pcm, err := DecodeFile(t.Context(), path, Options{})
+	if err != nil {
+		t.Fatalf("DecodeFile: %v", err)
+	}
+	if len(pcm.Samples) != 100 {
+		t.Fatalf("samples = %d", len(pcm.Samples))
+	}
+}
+
+func TestDecodeFLACIfNotFLAC(t *testing.T) {
+	_, ok, err := DecodeFLACIf(bytesReader([]byte("ID3\x04\x00\x00\x00\x00\x00\x00NOTFLAC")))
+	if err != nil {
+		t.Fatalf("DecodeFLACIf error: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected ok=false")
+	}
+}
+
+const (
+	flacSubConstant = iota
+	flacSubVerbatim
+	flacSubFixed
+	flacSubLPC
+)
+
+func assertFLACSamples(t *testing.T, got []float64, channels [][]int64, bps int) {
+	t.Helper()
+	if len(got) != len(channels[0]) {
+		t.Fatalf("samples = %d, want %d", len(got), len(channels[0]))
+	}
+	scale := float64(int64(1) << (bps - 1))
+	for i := range got {
+		want := 0.0
+		for _, ch := range channels {
+			want += float64(ch[i]) / scale
+		}
+		want /= float64(len(channels))
+		if math.Abs(got[i]-want) > 1e-12 {
+			t.Fatalf("sample %d = %f, want %f", i, got[i], want)
+		}
+	}
+}
+
+func flacSine(n, bps int, amp float64) []int64 {
+	out := make([]int64, n)
+	peak := float64(int64(1)<<(bps-1) - 1)
+	for i := range out {
+		out[i] = int64(math.Round(amp * peak * math.Sin(2*math.Pi*float64(i)/37)))
+	}
+	return out
+}
+
+// makeFLAC builds a minimal FLAC stream with fixed-size blocks, using the
+// given channel assignment code and subframe kind for every channel.
+func makeFLAC(channels [][]int64, bps, sampleRate, blockSize, chCode, kind int) []byte {
+	total := len(channels[0])
+	hash := md5.New()
+	writeFLACHash(hash, channels, bps)
+
+	w := &flacBitWriter{}
+	w.bytes([]byte("fLaC"))
+	w.write(0x80, 8) // last block, STREAMINFO
+	w.write(34, 24)
+	w.write(uint64(blockSize), 16)
+	w.write(uint64(blockSize), 16)
+	w.write(0, 24)
+	w.write(0, 24)
+	w.write(uint64(sampleRate), 20)
+	w.write(uint64(len(channels)-1), 3)
+	w.write(uint64(bps-1), 5)
+	w.write(uint64(total), 36)
+	w.bytes(hash.Sum(nil))
+
+	for frame, start := 0, 0; start < total; frame, start = frame+1, start+blockSize {
+		end := start + blockSize
+		if end > total {
+			end = total
+		}
+		block := make([][]int64, len(channels))
+		for ch := range channels {
+			block[ch] = channels[ch][start:end]
+		}
+		subs, extra := flacDecorrelate(block, chCode)
+
+		frameStart := len(w.out)
+		w.write(0x7FFC, 15)
+		w.write(0, 1)
+		w.write(7, 4) // 16-bit block size follows
+		w.write(0, 4)
+		w.write(uint64(chCode), 4)
+		w.write(0, 3)
+		w.write(0, 1)
+		w.write(uint64(frame), 8)
+		w.write(uint64(end-start-1), 16)
+		w.write(uint64(flacCRC8(w.out[frameStart:])), 8)
+		for ch, sub := range subs {
+			writeFLACSubframe(w, sub, bps+extra[ch], kind)
+		}
+		w.align()
+		w.write(uint64(flacCRC16(w.out[frameStart:])), 16)
+	}
+	return w.out
+}
+
+func flacDecorrelate(block [][]int64, chCode int) ([][]int64, []int) {
+	extra := make([]int, len(block))
+	if chCode < 8 {
+		return block, extra
+	}
+	left, right := block[0], block[1]
+	side := make([]int64, len(left))
+	for i := range side {
+		side[i] = left[i] - right[i]
+	}
+	switch chCode {
+	case 8:
+		extra[1] = 1
+		return [][]int64{left, side}, extra
+	case 9:
+		extra[0] = 1
+		return [][]int64{side, right}, extra
+	default:
+		mid := make([]int64, len(left))
+		for i := range mid {
+			mid[i] = (left[i] + right[i]) >> 1
+		}
+		extra[1] = 1
+		return [][]int64{mid, side}, extra
+	}
+}
+
+func writeFLACSubframe(w *flacBitWriter, samples []int64, bps, kind int) {
+	switch kind {
+	case flacSubConstant:
+		w.write(0, 8)
+		w.writeSigned(samples[0], bps)
+	case flacSubVerbatim:
+		w.write(1<<1, 8)
+		for _, v := range samples {
+			w.writeSigned(v, bps)
+		}
+	case flacSubFixed:
+		order := 2
+		w.write(uint64(8+order)<<1, 8)
+		for i := 0; i < order; i++ {
+			w.writeSigned(samples[i], bps)
+		}
+		residual := make([]int64, 0, len(samples))
+		for i := order; i < len(samples); i++ {
+			residual = append(residual, samples[i]-(2*samples[i-1]-samples[i-2]))
+		}
+		writeFLACResidual(w, residual)
+	case flacSubLPC:
+		order, precision, shift := 2, 14, 10
+		coeffs := []int64{1900, -950}
+		w.write(uint64(32+order-1)<<1, 8)
+		for i := 0; i < order; i++ {
+			w.writeSigned(samples[i], bps)
+		}
+		w.write(uint64(precision-1), 4)
+		w.write(uint64(shift), 5)
+		for _, c := range coeffs {
+			w.writeSigned(c, precision)
+		}
+		residual := make([]int64, 0, len(samples))
+		for i := order; i < len(samples); i++ {
+			pred := (coeffs[0]*samples[i-1] + coeffs[1]*samples[i-2]) >> uint(shift)
+			residual = append(residual, samples[i]-pred)
+		}
+		writeFLACResidual(w, residual)
+	}
+}
+
+func writeFLACResidual(w *flacBitWriter, residual []int64) {
+	const param = 12
+	w.write(1, 2) // 5-bit Rice parameters
+	w.write(0, 4) // single partition
+	w.write(param, 5)
+	for _, v := range residual {
+		u := uint64(v << 1)
+		if v < 0 {
+			u = uint64(-v<<1) - 1
+		}
+		for q := u >> param; q > 0; q-- {
+			w.write(0, 1)
+		}
+		w.write(1, 1)
+		w.write(u&(1<<param-1), param)
+	}
+}
+
+type flacBitWriter struct {
+	out []byte
+	cur byte
+	n   uint
+}
+
+func (w *flacBitWriter) write(v uint64, n uint) {
+	for i := int(n) - 1; i >= 0; i-- {
+		w.cur = w.cur<<1 | byte(v>>uint(i)&1)
+		w.n++
+		if w.n == 8 {
+			w.out = append(w.out, w.cur)
+			w.cur, w.n = 0, 0
+		}
+	}
+}
+
+func (w *flacBitWriter) writeSigned(v int64, n int) {
+	w.write(uint64(v)&(1<<uint(n)-1), uint(n))
+}
+
+func (w *flacBitWriter) bytes(b []byte) {
+	for _, c := range b {
+		w.write(uint64(c), 8)
+	}
+}
+
+func (w *flacBitWriter) align() {
+	for w.n != 0 {
+		w.write(0, 1)
+	}
+}
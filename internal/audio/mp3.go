@@ -1,15 +1,22 @@
 package audio
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/binary"
 	"errors"
 	"io"
+	"strings"
 
 	"github.com/hajimehoshi/go-mp3"
 )
 
 // DecodeMP3If tries to decode MP3 data, returning ok=false when not MP3.
 func DecodeMP3If(r io.ReadSeeker) (Audio, bool, error) {
+	return decodeMP3If(r, Options{})
+}
+
+func decodeMP3If(r io.ReadSeeker, opts Options) (Audio, bool, error) {
 	header := make([]byte, 4)
 	if _, err := io.ReadFull(r, header); err != nil {
 		return Audio{}, false, err
@@ -19,15 +26,71 @@ func DecodeMP3If(r io.ReadSeeker) (Audio, bool, error) {
 	if !isMP3 {
 		return Audio{}, false, nil
 	}
-	pcm, err := decodeMP3(r)
+	pcm, err := decodeMP3(r, opts)
 	if err != nil {
 		return Audio{}, true, err
 	}
 	return pcm, true, nil
 }
 
-func decodeMP3(r io.Reader) (Audio, error) {
-	dec, err := mp3.NewDecoder(r)
+func decodeMP3(r io.Reader, opts Options) (Audio, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Audio{}, err
+	}
+	stream, err := parseMP3Stream(data)
+	if err != nil {
+		return Audio{}, err
+	}
+	lead, tail := stream.trim(len(stream.offsets) * stream.samplesPerFrame)
+	if err := opts.checkLength(int64(tail-lead), stream.sampleRate); err != nil {
+		return Audio{}, err
+	}
+	if opts.hasRange() {
+		return decodeMP3Range(data, stream, opts)
+	}
+
+	dec, err := mp3.NewDecoder(bytes.NewReader(data))
+	if err != nil {
+		return Audio{}, err
+	}
+	pcm, err := io.ReadAll(dec)
+	if err != nil {
+		return Audio{}, err
+	}
+	// go-mp3 always emits 16-bit little-endian stereo.
+	if len(pcm)%4 != 0 {
+		return Audio{}, errors.New("mp3: truncated pcm frame")
+	}
+
+	start, end := stream.trim(len(pcm) / 4)
+	return stream.audio(dec.SampleRate(), pcm[start*4:end*4])
+}
+
+// mp3SeekPreroll is the number of frames decoded and discarded ahead of a
+// seek target. It covers the MDCT overlap with the previous frame and a bit
+// reservoir that reaches back up to 511 bytes, i.e. several frames at low
+// bitrates.
+const mp3SeekPreroll = 10
+
+// decodeMP3Range decodes only the frames that cover the range selected by
+// opts, starting mp3SeekPreroll frames early so the first samples are exact.
+func decodeMP3Range(data []byte, stream mp3Stream, opts Options) (Audio, error) {
+	spf := stream.samplesPerFrame
+	lead, tail := stream.trim(len(stream.offsets) * spf)
+	start, end, err := sliceRange(tail-lead, stream.sampleRate, opts.Start, opts.Duration)
+	if err != nil {
+		return Audio{}, err
+	}
+	start += lead
+	end += lead
+
+	from := max(start/spf-mp3SeekPreroll, 0)
+	to := len(data)
+	if last := (end - 1) / spf; last+1 < len(stream.offsets) {
+		to = stream.offsets[last+1]
+	}
+	dec, err := mp3.NewDecoder(bytes.NewReader(data[stream.offsets[from]:to]))
 	if err != nil {
 		return Audio{}, err
 	}
@@ -35,31 +98,318 @@ func decodeMP3(r io.Reader) (Audio, error) {
 	if err != nil {
 		return Audio{}, err
 	}
-	if len(pcm)%2 != 0 {
-		return Audio{}, errors.New("mp3: odd pcm length")
+	skip := start - from*spf
+	frames := min(len(pcm)/4-skip, end-start)
+	if frames <= 0 {
+		return Audio{}, errors.New("slice: start beyond end")
 	}
+	return stream.audio(dec.SampleRate(), pcm[skip*4:(skip+frames)*4])
+}
 
-	channels := 1
-	if len(pcm)%4 == 0 {
-		channels = 2
+// audio converts go-mp3 output, which is always 16-bit little-endian
+// stereo, into Audio with the stream's channel count and metadata.
+func (s mp3Stream) audio(sampleRate int, pcm []byte) (Audio, error) {
+	channels := 2
+	if s.info.ChannelMode == "mono" {
+		channels = 1
 	}
-	frames := len(pcm) / (2 * channels)
-	out := make([]float64, frames)
+	frames := len(pcm) / 4
+	out := makeChannels(channels, frames)
 
 	buf := bytes.NewReader(pcm)
 	for i := 0; i < frames; i++ {
-		var sum float64
-		for ch := 0; ch < channels; ch++ {
+		for ch := 0; ch < 2; ch++ {
 			var sample int16
 			if err := binaryRead(buf, &sample); err != nil {
 				return Audio{}, err
 			}
-			sum += float64(sample) / 32768.0
+			if ch < channels {
+				out[ch][i] = float64(sample) / 32768.0
+			}
+		}
+	}
+
+	pcmOut := NewAudio(sampleRate, out)
+	info := s.info
+	pcmOut.Metadata = Metadata{Codec: "mp3", Bitrate: s.bitrate, MP3: &info}
+	return pcmOut, nil
+}
+
+// mp3DecoderDelay is the fixed latency of the layer III synthesis filterbank.
+const mp3DecoderDelay = 529
+
+// mp3Stream summarizes the frame headers of an MP3 stream.
+type mp3Stream struct {
+	info            MP3Info
+	samplesPerFrame int
+	sampleRate      int
+	bitrate         int
+	// offsets holds the byte offset of every frame, including a header frame.
+	offsets []int
+	// headerFrame is set when the first frame is a Xing/Info/VBRI header
+	// that decodes to silence rather than audio.
+	headerFrame bool
+	// gapless is set when a LAME header supplies delay and padding.
+	gapless bool
+}
+
+// trim returns the range of decoded samples that belong to the original
+// signal, given the number of samples produced by the decoder.
+func (s mp3Stream) trim(decoded int) (int, int) {
+	start := 0
+	if s.headerFrame {
+		start = s.samplesPerFrame
+	}
+	end := decoded
+	if s.gapless {
+		start += s.info.EncoderDelay + mp3DecoderDelay
+		end = start + s.info.Frames*s.samplesPerFrame - s.info.EncoderDelay - s.info.EncoderPadding
+	}
+	if end > decoded {
+		end = decoded
+	}
+	if start > end {
+		start = end
+	}
+	return start, end
+}
+
+type mp3FrameHeader struct {
+	version     string
+	layer       int
+	protected   bool
+	bitrate     int
+	sampleRate  int
+	padding     bool
+	channelMode int
+}
+
+var (
+	mp3Bitrates = map[string][16]int{
+		"1/1": {0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448, -1},
+		"1/2": {0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384, -1},
+		"1/3": {0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, -1},
+		"2/1": {0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256, -1},
+		"2/2": {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160, -1},
+	}
+	mp3SampleRates = map[string][3]int{
+		"1":   {44100, 48000, 32000},
+		"2":   {22050, 24000, 16000},
+		"2.5": {11025, 12000, 8000},
+	}
+	mp3ChannelModes = [4]string{"stereo", "joint stereo", "dual channel", "mono"}
+)
+
+func parseMP3FrameHeader(b []byte) (mp3FrameHeader, bool) {
+	if len(b) < 4 || b[0] != 0xFF || b[1]&0xE0 != 0xE0 {
+		return mp3FrameHeader{}, false
+	}
+	var h mp3FrameHeader
+	switch (b[1] >> 3) & 0x03 {
+	case 0:
+		h.version = "2.5"
+	case 2:
+		h.version = "2"
+	case 3:
+		h.version = "1"
+	default:
+		return h, false
+	}
+	h.layer = 4 - int((b[1]>>1)&0x03)
+	if h.layer == 4 {
+		return h, false
+	}
+	h.protected = b[1]&0x01 == 0
+
+	table := "1/" + string(rune('0'+h.layer))
+	if h.version != "1" {
+		table = "2/2"
+		if h.layer == 1 {
+			table = "2/1"
+		}
+	}
+	h.bitrate = mp3Bitrates[table][b[2]>>4] * 1000
+	rateIndex := int((b[2] >> 2) & 0x03)
+	if h.bitrate <= 0 || rateIndex == 3 {
+		// Free format and reserved values are not supported.
+		return h, false
+	}
+	h.sampleRate = mp3SampleRates[h.version][rateIndex]
+	h.padding = b[2]&0x02 != 0
+	h.channelMode = int(b[3] >> 6)
+	return h, true
+}
+
+func (h mp3FrameHeader) samplesPerFrame() int {
+	switch {
+	case h.layer == 1:
+		return 384
+	case h.layer == 3 && h.version != "1":
+		return 576
+	default:
+		return 1152
+	}
+}
+
+func (h mp3FrameHeader) frameSize() int {
+	pad := 0
+	if h.padding {
+		pad = 1
+	}
+	if h.layer == 1 {
+		return (12*h.bitrate/h.sampleRate + pad) * 4
+	}
+	return h.samplesPerFrame()/8*h.bitrate/h.sampleRate + pad
+}
+
+// sideInfoSize returns the layer III side information length in bytes.
+func (h mp3FrameHeader) sideInfoSize() int {
+	mono := h.channelMode == 3
+	switch {
+	case h.version == "1" && mono:
+		return 17
+	case h.version == "1":
+		return 32
+	case mono:
+		return 9
+	default:
+		return 17
+	}
+}
+
+// findMP3Frame returns the position and header of the first frame at or
+// after pos.
+func findMP3Frame(data []byte, pos int) (int, mp3FrameHeader, bool) {
+	for ; pos+4 <= len(data); pos++ {
+		if h, ok := parseMP3FrameHeader(data[pos:]); ok {
+			return pos, h, true
 		}
-		out[i] = sum / float64(channels)
+	}
+	return 0, mp3FrameHeader{}, false
+}
+
+// newMP3Stream describes a stream from its first frame, which starts data.
+func newMP3Stream(data []byte, first mp3FrameHeader) mp3Stream {
+	stream := mp3Stream{samplesPerFrame: first.samplesPerFrame(), sampleRate: first.sampleRate}
+	stream.info = MP3Info{
+		Version:     first.version,
+		Layer:       first.layer,
+		ChannelMode: mp3ChannelModes[first.channelMode],
+	}
+	stream.readInfoFrame(data[:min(first.frameSize(), len(data))], first)
+	return stream
+}
+
+// parseMP3Stream walks the frame headers of data, reading the Xing/Info,
+// LAME and VBRI headers from the first frame when present.
+func parseMP3Stream(data []byte) (mp3Stream, error) {
+	pos, first, ok := findMP3Frame(data, id3Size(data))
+	if !ok {
+		return mp3Stream{}, errors.New("mp3: no frame header found")
+	}
+	stream := newMP3Stream(data[pos:], first)
+
+	var (
+		frames    int
+		bytesSum  int
+		bitrate   int
+		varies    bool
+		firstSeen bool
+	)
+	for pos+4 <= len(data) {
+		h, ok := parseMP3FrameHeader(data[pos:])
+		if !ok || h.sampleRate != first.sampleRate {
+			break
+		}
+		size := h.frameSize()
+		stream.offsets = append(stream.offsets, pos)
+		if !firstSeen && stream.headerFrame {
+			firstSeen = true
+			pos += size
+			continue
+		}
+		firstSeen = true
+		if frames > 0 && h.bitrate != bitrate {
+			varies = true
+		}
+		bitrate = h.bitrate
+		frames++
+		bytesSum += size
+		pos += size
+	}
+	if stream.info.Frames == 0 {
+		stream.info.Frames = frames
+	}
+	// Without an info header, VBR shows up as varying frame bitrates.
+	stream.info.VBR = stream.info.VBR || (!stream.headerFrame && varies)
+	if frames > 0 && !stream.info.VBR {
+		stream.bitrate = bitrate
+	} else if frames > 0 {
+		seconds := float64(frames*stream.samplesPerFrame) / float64(first.sampleRate)
+		stream.bitrate = int(float64(bytesSum*8)/seconds + 0.5)
+	}
+	return stream, nil
+}
+
+// readInfoFrame inspects the first frame for a Xing/Info, LAME or VBRI header.
+func (s *mp3Stream) readInfoFrame(frame []byte, h mp3FrameHeader) {
+	if h.layer != 3 {
+		return
+	}
+	if off := 4 + 32; len(frame) >= off+18 && string(frame[off:off+4]) == "VBRI" {
+		s.headerFrame = true
+		s.info.VBR = true
+		s.info.Frames = int(binary.BigEndian.Uint32(frame[off+14 : off+18]))
+		return
 	}
 
-	return Audio{SampleRate: dec.SampleRate(), Samples: out}, nil
+	off := 4 + h.sideInfoSize()
+	if h.protected {
+		off += 2
+	}
+	if len(frame) < off+8 {
+		return
+	}
+	id := string(frame[off : off+4])
+	if id != "Xing" && id != "Info" {
+		return
+	}
+	s.headerFrame = true
+	s.info.VBR = id == "Xing"
+	flags := binary.BigEndian.Uint32(frame[off+4 : off+8])
+	pos := off + 8
+	if flags&0x01 != 0 && len(frame) >= pos+4 {
+		s.info.Frames = int(binary.BigEndian.Uint32(frame[pos : pos+4]))
+		pos += 4
+	}
+	if flags&0x02 != 0 {
+		pos += 4
+	}
+	if flags&0x04 != 0 {
+		pos += 100
+	}
+	if flags&0x08 != 0 {
+		pos += 4
+	}
+
+	// LAME extension: 9-byte encoder string, delay/padding at offset 21.
+	if len(frame) < pos+24 || !isPrintableASCII(frame[pos:pos+4]) {
+		return
+	}
+	tag := frame[pos : pos+24]
+	s.info.Encoder = strings.TrimRight(string(tag[0:9]), "\x00 ")
+	s.info.EncoderDelay = int(tag[21])<<4 | int(tag[22])>>4
+	s.info.EncoderPadding = int(tag[22]&0x0F)<<8 | int(tag[23])
+	s.gapless = s.info.Frames > 0
+}
+
+func isPrintableASCII(b []byte) bool {
+	for _, c := range b {
+		if c < 0x20 || c > 0x7E {
+			return false
+		}
+	}
+	return true
 }
 
 func binaryRead(r io.Reader, v *int16) error {
@@ -70,3 +420,116 @@ func binaryRead(r io.Reader, v *int16) error {
 	*v = int16(b[0]) | int16(b[1])<<8
 	return nil
 }
+
+// mp3Source streams MP3 through go-mp3, trimming the Xing/Info frame and
+// the LAME encoder delay and padding.
+type mp3Source struct {
+	dec      *mp3.Decoder
+	channels int
+	// skip counts decoded frames still to drop; left counts frames still
+	// to deliver, or -1 when the stream length is unknown.
+	skip int
+	left int
+	pcm  []byte
+	meta Metadata
+}
+
+// NewMP3Source reads the first MP3 frame headers from r and streams the
+// decoded audio.
+func NewMP3Source(r io.Reader) (Source, error) {
+	br := bufio.NewReaderSize(r, 16*1024)
+	head, _ := br.Peek(10)
+	if size := id3Size(head); size > 0 {
+		if _, err := br.Discard(size); err != nil {
+			return nil, err
+		}
+	}
+	// Peek far enough to hold the largest layer III frame.
+	peek, err := br.Peek(4096)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	pos, first, ok := findMP3Frame(peek, 0)
+	if !ok {
+		return nil, errors.New("mp3: no frame header found")
+	}
+	stream := newMP3Stream(peek[pos:], first)
+	if !stream.info.VBR {
+		// Without walking the stream, the first frame gives the CBR rate.
+		stream.bitrate = first.bitrate
+	}
+	if _, err := br.Discard(pos); err != nil {
+		return nil, err
+	}
+
+	dec, err := mp3.NewDecoder(br)
+	if err != nil {
+		return nil, err
+	}
+	info := stream.info
+	src := &mp3Source{dec: dec, channels: 2, left: -1, meta: Metadata{Codec: "mp3", Bitrate: stream.bitrate, MP3: &info}}
+	if stream.info.ChannelMode == "mono" {
+		src.channels = 1
+	}
+	if stream.headerFrame {
+		src.skip = stream.samplesPerFrame
+	}
+	if stream.gapless {
+		src.skip += stream.info.EncoderDelay + mp3DecoderDelay
+		src.left = stream.info.Frames*stream.samplesPerFrame - stream.info.EncoderDelay - stream.info.EncoderPadding
+	}
+	return src, nil
+}
+
+func (s *mp3Source) SampleRate() int { return s.dec.SampleRate() }
+
+func (s *mp3Source) Channels() int { return s.channels }
+
+func (s *mp3Source) Read(buf [][]float64) (int, error) {
+	frames := len(buf[0])
+	if s.left >= 0 && frames > s.left {
+		frames = s.left
+	}
+	if frames == 0 {
+		return 0, io.EOF
+	}
+	for s.skip > 0 {
+		n := min(s.skip, DefaultBlockSize)
+		got, err := s.readPCM(n)
+		s.skip -= got
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := s.readPCM(frames)
+	for i := 0; i < n; i++ {
+		for ch := 0; ch < s.channels; ch++ {
+			off := i*4 + ch*2
+			buf[ch][i] = float64(int16(binary.LittleEndian.Uint16(s.pcm[off:off+2]))) / 32768.0
+		}
+	}
+	if s.left >= 0 {
+		s.left -= n
+	}
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+// readPCM reads up to frames stereo 16-bit frames from go-mp3 into s.pcm.
+func (s *mp3Source) readPCM(frames int) (int, error) {
+	size := frames * 4
+	if cap(s.pcm) < size {
+		s.pcm = make([]byte, size)
+	}
+	n, err := io.ReadFull(s.dec, s.pcm[:size])
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return n / 4, err
+}
+
+func (s *mp3Source) Close() error { return nil }
+
+func (s *mp3Source) metadata() Metadata { return s.meta }
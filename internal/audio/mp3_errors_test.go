@@ -6,7 +6,7 @@ import (
 )
 
 func TestDecodeMP3Error(t *testing.T) {
-	if _, err := decodeMP3(bytes.NewReader([]byte("not mp3"))); err == nil {
+	if _, err := decodeMP3(bytes.NewReader([]byte("not mp3")), Options{}); err == nil {
 		t.Fatalf("expected error")
 	}
 }
@@ -0,0 +1,109 @@
+package audio
+
+import (
+	"math"
+	"os"
+	"testing"
+)
+
+func TestDecodeMP3GaplessMono(t *testing.T) {
+	data, err := os.ReadFile(testdataPath(t, "sine.mp3"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	// The fixture is one second of a 440 Hz sine at 1/8 amplitude.
+	if len(pcm.Samples) != 44100 || pcm.NumChannels() != 1 {
+		t.Fatalf("samples = %d, channels = %d", len(pcm.Samples), pcm.NumChannels())
+	}
+	info := pcm.Metadata.MP3
+	if pcm.Metadata.Codec != "mp3" || info == nil {
+		t.Fatalf("missing mp3 metadata: %+v", pcm.Metadata)
+	}
+	if info.ChannelMode != "mono" || info.Version != "1" || info.Layer != 3 || info.Frames != 40 {
+		t.Fatalf("unexpected frame info: %+v", info)
+	}
+	if info.EncoderDelay != 576 || info.EncoderPadding != 1404 || info.Encoder != "Lavc62.11" {
+		t.Fatalf("unexpected encoder info: %+v", info)
+	}
+	if !info.VBR || pcm.Metadata.Bitrate <= 0 {
+		t.Fatalf("unexpected bitrate info: %+v", pcm.Metadata)
+	}
+
+	// Sample-accurate timeline: the reference lines up with no shift.
+	errAt := func(shift int) float64 {
+		var sum float64
+		for i := 2000; i < 40000; i++ {
+			d := pcm.Samples[i] - 0.125*math.Sin(2*math.Pi*440*float64(i+shift)/44100)
+			sum += d * d
+		}
+		return sum
+	}
+	if errAt(0) >= errAt(-1) || errAt(0) >= errAt(1) {
+		t.Fatalf("decoded audio is not aligned to the source")
+	}
+}
+
+func TestParseMP3FrameHeader(t *testing.T) {
+	h, ok := parseMP3FrameHeader([]byte{0xFF, 0xFB, 0x40, 0xC0})
+	if !ok {
+		t.Fatalf("expected header")
+	}
+	if h.version != "1" || h.layer != 3 || h.bitrate != 56000 || h.sampleRate != 44100 || h.channelMode != 3 {
+		t.Fatalf("unexpected header: %+v", h)
+	}
+	if h.frameSize() != 182 || h.samplesPerFrame() != 1152 || h.sideInfoSize() != 17 {
+		t.Fatalf("unexpected sizes: %d %d %d", h.frameSize(), h.samplesPerFrame(), h.sideInfoSize())
+	}
+
+	h, ok = parseMP3FrameHeader([]byte{0xFF, 0xF3, 0x82, 0x00})
+	if !ok || h.version != "2" || h.bitrate != 64000 || h.sampleRate != 22050 || h.samplesPerFrame() != 576 {
+		t.Fatalf("unexpected mpeg2 header: %+v", h)
+	}
+	for _, bad := range [][]byte{{0xFF, 0xEB, 0x40, 0xC0}, {0xFF, 0xFB, 0xF0, 0xC0}, {0xFF, 0xFB, 0x4C, 0xC0}, {0xFF, 0xF9, 0x40, 0xC0}} {
+		if _, ok := parseMP3FrameHeader(bad); ok {
+			t.Fatalf("expected invalid header %x", bad)
+		}
+	}
+}
+
+func TestParseMP3StreamCBR(t *testing.T) {
+	// Three 128 kbps stereo frames without an info header, then an ID3v1 tag.
+	header := []byte{0xFF, 0xFB, 0x90, 0x00}
+	var data []byte
+	for i := 0; i < 3; i++ {
+		frame := make([]byte, 417)
+		copy(frame, header)
+		data = append(data, frame...)
+	}
+	data = append(data, "TAG"...)
+	stream, err := parseMP3Stream(append([]byte{0, 0}, data...))
+	if err != nil {
+		t.Fatalf("parseMP3Stream: %v", err)
+	}
+	if stream.info.Frames != 3 || stream.info.VBR || stream.bitrate != 128000 || stream.info.ChannelMode != "stereo" {
+		t.Fatalf("unexpected stream: %+v", stream)
+	}
+	if start, end := stream.trim(3456); start != 0 || end != 3456 {
+		t.Fatalf("trim = %d, %d", start, end)
+	}
+}
+
+func TestMP3Trim(t *testing.T) {
+	stream := mp3Stream{
+		info:            MP3Info{Frames: 10, EncoderDelay: 576, EncoderPadding: 1000},
+		samplesPerFrame: 1152,
+		headerFrame:     true,
+		gapless:         true,
+	}
+	start, end := stream.trim(11 * 1152)
+	if start != 1152+576+529 || end-start != 10*1152-576-1000 {
+		t.Fatalf("trim = %d, %d", start, end)
+	}
+	if start, end := stream.trim(100); start != 100 || end != 100 {
+		t.Fatalf("short trim = %d, %d", start, end)
+	}
+}
@@ -0,0 +1,272 @@
+package audio
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// DecodeOggIf tries to decode Ogg data, returning ok=false when not Ogg or
+// when the stream carries a codec without a native decoder (such as Opus),
+// so callers can fall back to ffmpeg.
+func DecodeOggIf(r io.ReadSeeker) (Audio, bool, error) {
+	return decodeOggIf(r, Options{})
+}
+
+func decodeOggIf(r io.ReadSeeker, opts Options) (Audio, bool, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Audio{}, false, err
+	}
+	_, _ = r.Seek(0, io.SeekStart)
+	if string(header) != "OggS" {
+		return Audio{}, false, nil
+	}
+
+	packets := newOggReader(r)
+	first, err := packets.next()
+	_, _ = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return Audio{}, true, err
+	}
+	switch oggCodec(first) {
+	case "vorbis":
+	case "flac":
+	default:
+		return Audio{}, false, nil
+	}
+
+	src, err := NewOggSource(r)
+	if err != nil {
+		return Audio{}, true, err
+	}
+	pcm, err := readSource(context.Background(), src, opts)
+	if err != nil {
+		return Audio{}, true, err
+	}
+	return pcm, true, nil
+}
+
+// oggCodec identifies the codec from the first packet of a logical stream.
+func oggCodec(packet []byte) string {
+	switch {
+	case len(packet) >= 7 && packet[0] == 1 && string(packet[1:7]) == "vorbis":
+		return "vorbis"
+	case len(packet) >= 5 && packet[0] == 0x7F && string(packet[1:5]) == "FLAC":
+		return "flac"
+	case len(packet) >= 8 && string(packet[0:8]) == "OpusHead":
+		return "opus"
+	default:
+		return ""
+	}
+}
+
+// NewOggSource reads the headers of the first logical stream in r and
+// decodes its packets as they are read. Vorbis and FLAC are supported; other
+// codecs return ErrUnsupported.
+func NewOggSource(r io.Reader) (Source, error) {
+	packets := newOggReader(r)
+	first, err := packets.next()
+	if err != nil {
+		return nil, err
+	}
+	switch oggCodec(first) {
+	case "vorbis":
+		return newVorbisSource(first, packets)
+	case "flac":
+		return newOggFLACSource(first, packets)
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+// newOggFLACSource decodes FLAC frames wrapped in Ogg packets.
+func newOggFLACSource(first []byte, packets *oggReader) (Source, error) {
+	// 0x7F "FLAC" major minor headerCount "fLaC" STREAMINFO block.
+	if len(first) < 13+4+34 || string(first[9:13]) != "fLaC" {
+		return nil, errors.New("ogg: invalid flac mapping header")
+	}
+	headers := int(binary.BigEndian.Uint16(first[7:9]))
+	info, _, err := parseFLACMetadata(append([]byte("fLaC"), setFLACLastBlock(first[13:13+4+34])...))
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < headers; i++ {
+		if _, err := packets.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	dec := flacFrameDecoder{info: info}
+	return newFLACSource(info, func() ([][]int64, error) {
+		for {
+			packet, err := packets.next()
+			if err != nil {
+				return nil, err
+			}
+			if len(packet) == 0 {
+				continue
+			}
+			_, block, err := dec.decodeFrame(packet)
+			return block, err
+		}
+	}), nil
+}
+
+func setFLACLastBlock(block []byte) []byte {
+	out := append([]byte(nil), block...)
+	out[0] |= 0x80
+	return out
+}
+
+// oggReader reassembles packets from the first logical stream of an Ogg
+// container. Pages belonging to other multiplexed streams are skipped.
+type oggReader struct {
+	r       io.Reader
+	serial  uint32
+	locked  bool
+	done    bool
+	lacing  []byte
+	body    []byte
+	seg     int
+	off     int
+	partial []byte
+
+	// granule is the granule position of the most recent page that ended
+	// a packet, or -1 when no such page has been seen.
+	granule int64
+}
+
+func newOggReader(r io.Reader) *oggReader {
+	return &oggReader{r: r, granule: -1}
+}
+
+// next returns the next complete packet, or io.EOF at the end of the stream.
+func (o *oggReader) next() ([]byte, error) {
+	for {
+		for o.seg < len(o.lacing) {
+			n := int(o.lacing[o.seg])
+			o.seg++
+			if o.off+n > len(o.body) {
+				return nil, errors.New("ogg: truncated page body")
+			}
+			o.partial = append(o.partial, o.body[o.off:o.off+n]...)
+			o.off += n
+			if n < 255 {
+				packet := o.partial
+				o.partial = nil
+				return packet, nil
+			}
+		}
+		if o.done {
+			return nil, io.EOF
+		}
+		if err := o.readPage(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (o *oggReader) readPage() error {
+	for {
+		header := make([]byte, 27)
+		if _, err := io.ReadFull(o.r, header); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				o.done = true
+				o.lacing = nil
+				o.seg = 0
+				if len(o.partial) > 0 {
+					return errors.New("ogg: truncated packet")
+				}
+				return io.EOF
+			}
+			return err
+		}
+		if string(header[0:4]) != "OggS" {
+			return errors.New("ogg: lost page sync")
+		}
+		if header[4] != 0 {
+			return errors.New("ogg: unsupported page version")
+		}
+		flags := header[5]
+		granule := int64(binary.LittleEndian.Uint64(header[6:14]))
+		serial := binary.LittleEndian.Uint32(header[14:18])
+		crc := binary.LittleEndian.Uint32(header[22:26])
+		lacing := make([]byte, int(header[26]))
+		if _, err := io.ReadFull(o.r, lacing); err != nil {
+			return err
+		}
+		size := 0
+		for _, l := range lacing {
+			size += int(l)
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(o.r, body); err != nil {
+			return err
+		}
+
+		binary.LittleEndian.PutUint32(header[22:26], 0)
+		sum := oggCRC(0, header)
+		sum = oggCRC(sum, lacing)
+		sum = oggCRC(sum, body)
+		if sum != crc {
+			return errors.New("ogg: page crc mismatch")
+		}
+
+		if !o.locked {
+			o.serial = serial
+			o.locked = true
+		}
+		if serial != o.serial {
+			continue
+		}
+		if flags&0x01 == 0 && len(o.partial) > 0 {
+			return errors.New("ogg: missing continued packet")
+		}
+		o.lacing = lacing
+		o.body = body
+		o.seg = 0
+		o.off = 0
+		if completesPacket(lacing) {
+			o.granule = granule
+		}
+		if flags&0x04 != 0 {
+			o.done = true
+		}
+		return nil
+	}
+}
+
+func completesPacket(lacing []byte) bool {
+	for _, l := range lacing {
+		if l < 255 {
+			return true
+		}
+	}
+	return false
+}
+
+var oggCRCTable = makeOggCRCTable()
+
+func makeOggCRCTable() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 24
+		for j := 0; j < 8; j++ {
+			if c&0x80000000 != 0 {
+				c = c<<1 ^ 0x04C11DB7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}
+
+func oggCRC(crc uint32, data []byte) uint32 {
+	for _, b := range data {
+		crc = crc<<8 ^ oggCRCTable[byte(crc>>24)^b]
+	}
+	return crc
+}
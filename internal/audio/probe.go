@@ -0,0 +1,641 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Info describes an audio file as read from its headers, without decoding
+// the audio.
+type Info struct {
+	// Container is the file format, such as "wav", "ogg" or "mp3".
+	Container string `json:"container"`
+	// Codec names the audio encoding, such as "pcm", "vorbis" or "mp3".
+	Codec      string `json:"codec"`
+	SampleRate int    `json:"sample_rate"`
+	Channels   int    `json:"channels"`
+	// BitDepth is the stored sample size; 0 for lossy codecs.
+	BitDepth int `json:"bit_depth,omitempty"`
+	// Duration is the length in seconds, 0 when unknown.
+	Duration float64 `json:"duration"`
+	// Bitrate is the (average) bitrate in bits per second, 0 when unknown.
+	Bitrate int `json:"bitrate,omitempty"`
+	// Origin is the wall-clock time of the first sample, from a BWF bext
+	// chunk; nil when the file carries none.
+	Origin *time.Time `json:"origin,omitempty"`
+	// Tags holds ID3, RIFF INFO, AIFF text and Vorbis comment tags under
+	// lowercase keys such as "title" and "artist".
+	Tags map[string]string `json:"tags,omitempty"`
+	// Prober is "native" or "ffprobe".
+	Prober string `json:"prober"`
+}
+
+// Probe reads the metadata of the audio file at path. Formats without a
+// native decoder, and files the native probe cannot read, are handed to
+// ffprobe, looked up next to opts.FFmpegPath or in PATH.
+func Probe(ctx context.Context, path string, opts Options) (Info, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Info{}, err
+	}
+	defer func() { _ = file.Close() }()
+
+	head := make([]byte, sniffSize)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return Info{}, err
+	}
+	head = head[:n]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+
+	format := sniffFormat(head)
+	if size := id3Size(head); size > 0 && format == "mp3" {
+		// Some taggers prepend ID3v2 to FLAC files.
+		magic := make([]byte, 4)
+		if _, err := file.ReadAt(magic, int64(size)); err == nil && string(magic) == "fLaC" {
+			format = "flac"
+		}
+	}
+
+	var info Info
+	switch format {
+	case "wav":
+		info, err = probeWAV(file)
+	case "aiff":
+		info, err = probeAIFF(file)
+	case "flac":
+		info, err = probeFLAC(file)
+	case "ogg":
+		info, err = probeOgg(file)
+	case "mp3":
+		info, err = probeMP3(file)
+	default:
+		return probeFFprobe(ctx, path, opts)
+	}
+	if err != nil {
+		// Like decoding, hand what the native probe cannot read (an Ogg
+		// codec it does not know, a damaged header) to ffprobe. When that
+		// fails too, the native error is the more useful one.
+		fallback, ffErr := probeFFprobe(ctx, path, opts)
+		if ffErr == nil {
+			return fallback, nil
+		}
+		if errors.Is(err, ErrUnsupported) || contextError(ctx) != nil {
+			return Info{}, ffErr
+		}
+		return Info{}, err
+	}
+	info.Prober = "native"
+	if len(info.Tags) == 0 {
+		info.Tags = nil
+	}
+	return info, nil
+}
+
+func probeWAV(r io.ReadSeeker) (Info, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Info{}, err
+	}
+	info := Info{Container: "wav", Tags: map[string]string{}}
+	var (
+		fmtChunk  wavFormat
+		fmtFound  bool
+		sizes     *wavSizes
+		bwf       *BWFInfo
+		dataBytes int64 = -1
+	)
+	if string(header[0:4]) != "RIFF" {
+		info.Container = strings.ToLower(string(header[0:4]))
+	}
+	for {
+		chunkHeader := make([]byte, 8)
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			break
+		}
+		id := string(chunkHeader[0:4])
+		size := sizes.chunkSize(id, binary.LittleEndian.Uint32(chunkHeader[4:8]))
+		switch id {
+		case "fmt ", "LIST", "ds64", "bext":
+			buf, err := readIFFChunk(r, size)
+			if err != nil {
+				return Info{}, err
+			}
+			switch {
+			case id == "fmt ":
+				fmtFound = true
+				err = parseWavFormat(buf, &fmtChunk)
+			case id == "ds64":
+				sizes, err = parseDS64(buf, seekRemaining(r))
+			case id == "bext":
+				bwf, err = parseBext(buf)
+			case len(buf) >= 4 && string(buf[0:4]) == "INFO":
+				parseRIFFInfo(buf[4:], info.Tags)
+			}
+			if err != nil {
+				return Info{}, err
+			}
+			// readIFFChunk consumed the pad byte.
+			continue
+		case "data":
+			if size == wavStreamSize {
+				// Unset size: the data runs to the end of the file.
+				pos, _ := r.Seek(0, io.SeekCurrent)
+				end, err := r.Seek(0, io.SeekEnd)
+				if err != nil {
+					return Info{}, err
+				}
+				size = end - pos
+			}
+			dataBytes = size
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return Info{}, err
+			}
+		default:
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return Info{}, err
+			}
+		}
+		if size%2 == 1 {
+			_, _ = r.Seek(1, io.SeekCurrent)
+		}
+	}
+	if !fmtFound || dataBytes < 0 {
+		return Info{}, errors.New("wav: missing fmt or data chunk")
+	}
+
+	info.Codec = wavCodecName(fmtChunk)
+	info.SampleRate = int(fmtChunk.SampleRate)
+	info.Channels = int(fmtChunk.NumChannels)
+	info.BitDepth = int(fmtChunk.BitsPerSample)
+	if frameSize := info.Channels * info.BitDepth / 8; frameSize > 0 && info.SampleRate > 0 {
+		info.Duration = float64(dataBytes/int64(frameSize)) / float64(info.SampleRate)
+	}
+	info.Bitrate = info.SampleRate * info.Channels * info.BitDepth
+	if bwf != nil {
+		addBextTags(bwf, info.Tags)
+		if origin := bwf.Origin(info.SampleRate); !origin.IsZero() {
+			info.Origin = &origin
+		}
+	}
+	return info, nil
+}
+
+// wavCodecName names the sample encoding of a WAV fmt chunk.
+func wavCodecName(f wavFormat) string {
+	format := f.AudioFormat
+	if f.Extensible {
+		format = uint16(binary.LittleEndian.Uint32(f.SubFormat[0:4]))
+	}
+	switch format {
+	case 1:
+		return "pcm"
+	case 3:
+		return "float"
+	case 6:
+		return "alaw"
+	case 7:
+		return "mulaw"
+	default:
+		return fmt.Sprintf("wav format 0x%04x", format)
+	}
+}
+
+// addBextTags reports the BWF description and originator as tags.
+func addBextTags(bwf *BWFInfo, tags map[string]string) {
+	addTag(tags, "comment", bwf.Description)
+	addTag(tags, "originator", bwf.Originator)
+}
+
+func probeAIFF(r io.Reader) (Info, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return Info{}, err
+	}
+	compressed := string(header[8:12]) == "AIFC"
+	info := Info{Container: "aiff", Tags: map[string]string{}}
+	var (
+		comm      aiffCommon
+		commFound bool
+	)
+	for {
+		chunkHeader := make([]byte, 8)
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			break
+		}
+		id := string(chunkHeader[0:4])
+		size := int64(binary.BigEndian.Uint32(chunkHeader[4:8]))
+		name, isText := aiffTagNames[id]
+		if id != "COMM" && !isText {
+			if err := skipIFFChunk(r, size); err != nil {
+				break
+			}
+			continue
+		}
+		buf, err := readIFFChunk(r, size)
+		if err != nil {
+			return Info{}, err
+		}
+		if id == "COMM" {
+			if err := parseAIFFCommon(buf, compressed, &comm); err != nil {
+				return Info{}, err
+			}
+			commFound = true
+		} else {
+			addTag(info.Tags, name, string(buf))
+		}
+	}
+	if !commFound {
+		return Info{}, errors.New("aiff: missing COMM chunk")
+	}
+
+	info.SampleRate = int(comm.SampleRate)
+	info.Channels = comm.NumChannels
+	info.BitDepth = comm.SampleSize
+	switch comm.Compression {
+	case "NONE", "twos", "sowt":
+		info.Codec = "pcm"
+	case "fl32", "FL32":
+		info.Codec, info.BitDepth = "float", 32
+	case "fl64", "FL64":
+		info.Codec, info.BitDepth = "float", 64
+	default:
+		info.Codec = strings.TrimSpace(comm.Compression)
+	}
+	if comm.SampleRate > 0 {
+		info.Duration = float64(comm.NumFrames) / comm.SampleRate
+	}
+	if info.Codec == "pcm" || info.Codec == "float" {
+		info.Bitrate = info.SampleRate * info.Channels * info.BitDepth
+	}
+	return info, nil
+}
+
+func probeFLAC(r io.ReadSeeker) (Info, error) {
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return Info{}, err
+	}
+	start := int64(id3Size(head))
+	info := Info{Container: "flac", Codec: "flac", Tags: map[string]string{}}
+	if start > 0 {
+		tag := make([]byte, start)
+		if _, err := r.Seek(0, io.SeekStart); err != nil {
+			return Info{}, err
+		}
+		if _, err := io.ReadFull(r, tag); err != nil {
+			return Info{}, err
+		}
+		parseID3v2(tag, info.Tags)
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != "fLaC" {
+		return Info{}, ErrUnsupported
+	}
+
+	var streamInfo *flacStreamInfo
+	for {
+		blockHeader := make([]byte, 4)
+		if _, err := io.ReadFull(r, blockHeader); err != nil {
+			return Info{}, errors.New("flac: truncated metadata")
+		}
+		last := blockHeader[0]&0x80 != 0
+		blockType := blockHeader[0] & 0x7f
+		length := int64(blockHeader[1])<<16 | int64(blockHeader[2])<<8 | int64(blockHeader[3])
+		switch blockType {
+		case 0, 4:
+			buf := make([]byte, length)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return Info{}, err
+			}
+			if blockType == 4 {
+				parseVorbisComments(buf, info.Tags)
+			} else if len(buf) >= 34 {
+				si := parseFLACStreamInfo(buf)
+				streamInfo = &si
+			}
+		default:
+			if _, err := r.Seek(length, io.SeekCurrent); err != nil {
+				return Info{}, err
+			}
+		}
+		if last {
+			break
+		}
+	}
+	if streamInfo == nil {
+		return Info{}, errors.New("flac: missing streaminfo")
+	}
+	audioStart, _ := r.Seek(0, io.SeekCurrent)
+	end, _ := r.Seek(0, io.SeekEnd)
+	setFLACInfo(&info, *streamInfo, end-audioStart)
+	return info, nil
+}
+
+// setFLACInfo fills the stream fields from STREAMINFO; audioBytes is the
+// size of the frame data, used for the average bitrate.
+func setFLACInfo(info *Info, si flacStreamInfo, audioBytes int64) {
+	info.SampleRate = si.SampleRate
+	info.Channels = si.Channels
+	info.BitDepth = si.BitsPerSample
+	if si.SampleRate > 0 && si.TotalSamples > 0 {
+		info.Duration = float64(si.TotalSamples) / float64(si.SampleRate)
+		if audioBytes > 0 {
+			info.Bitrate = int(float64(audioBytes*8)/info.Duration + 0.5)
+		}
+	}
+}
+
+func probeOgg(r io.ReadSeeker) (Info, error) {
+	packets := newOggReader(r)
+	first, err := packets.next()
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Container: "ogg", Codec: oggCodec(first), Tags: map[string]string{}}
+	// preSkip is the number of granules to drop at the start (Opus only).
+	var preSkip int64
+	granuleRate := 0
+	switch info.Codec {
+	case "vorbis":
+		if _, err := newVorbisDecoder(first); err != nil {
+			return Info{}, err
+		}
+		info.Channels = int(first[11])
+		info.SampleRate = int(binary.LittleEndian.Uint32(first[12:16]))
+		granuleRate = info.SampleRate
+		if nominal := int32(binary.LittleEndian.Uint32(first[20:24])); nominal > 0 {
+			info.Bitrate = int(nominal)
+		}
+		if comment, err := packets.next(); err == nil && len(comment) > 7 && comment[0] == 3 {
+			parseVorbisComments(comment[7:], info.Tags)
+		}
+	case "opus":
+		if len(first) < 19 {
+			return Info{}, errors.New("opus: short identification header")
+		}
+		info.Channels = int(first[9])
+		preSkip = int64(binary.LittleEndian.Uint16(first[10:12]))
+		// Opus always decodes at 48 kHz, whatever the original input rate.
+		info.SampleRate = 48000
+		granuleRate = 48000
+		if tags, err := packets.next(); err == nil && len(tags) > 8 && string(tags[0:8]) == "OpusTags" {
+			parseVorbisComments(tags[8:], info.Tags)
+		}
+	case "flac":
+		if len(first) < 13+4+34 || string(first[9:13]) != "fLaC" {
+			return Info{}, errors.New("ogg: invalid flac mapping header")
+		}
+		si := parseFLACStreamInfo(first[17 : 17+34])
+		info.SampleRate = si.SampleRate
+		info.Channels = si.Channels
+		info.BitDepth = si.BitsPerSample
+		granuleRate = si.SampleRate
+		if comment, err := packets.next(); err == nil && len(comment) > 4 && comment[0]&0x7f == 4 {
+			parseVorbisComments(comment[4:], info.Tags)
+		}
+	default:
+		return Info{}, ErrUnsupported
+	}
+
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Info{}, err
+	}
+	if granule := lastOggGranule(r, size); granule > preSkip && granuleRate > 0 {
+		info.Duration = float64(granule-preSkip) / float64(granuleRate)
+		if info.Bitrate == 0 {
+			info.Bitrate = int(float64(size*8)/info.Duration + 0.5)
+		}
+	}
+	return info, nil
+}
+
+// lastOggGranule returns the granule position of the last page in the final
+// 64 KiB of the stream, or -1 when none is found.
+func lastOggGranule(r io.ReadSeeker, size int64) int64 {
+	start := max(size-64*1024, 0)
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return -1
+	}
+	tail, err := io.ReadAll(r)
+	if err != nil {
+		return -1
+	}
+	for i := bytes.LastIndex(tail, []byte("OggS")); i >= 0; i = bytes.LastIndex(tail[:i], []byte("OggS")) {
+		if i+14 > len(tail) || tail[i+4] != 0 {
+			continue
+		}
+		if granule := int64(binary.LittleEndian.Uint64(tail[i+6 : i+14])); granule >= 0 {
+			return granule
+		}
+	}
+	return -1
+}
+
+// probeMP3 reads the ID3 tags and the first frame, taking the frame count
+// from a Xing/Info or VBRI header and estimating it from the file size for
+// plain CBR streams, so large files are not read in full.
+func probeMP3(r io.ReadSeeker) (Info, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Info{}, err
+	}
+	info := Info{Container: "mp3", Codec: "mp3", Tags: map[string]string{}}
+
+	audioEnd := size
+	if size >= 128 {
+		tag := make([]byte, 128)
+		if _, err := r.Seek(size-128, io.SeekStart); err != nil {
+			return Info{}, err
+		}
+		if _, err := io.ReadFull(r, tag); err != nil {
+			return Info{}, err
+		}
+		if string(tag[0:3]) == "TAG" {
+			parseID3v1(tag, info.Tags)
+			audioEnd -= 128
+		}
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+	head := make([]byte, 10)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return Info{}, err
+	}
+	audioStart := int64(0)
+	if tagSize := int64(id3Size(head)); tagSize > 0 {
+		tag, err := io.ReadAll(io.LimitReader(io.MultiReader(bytes.NewReader(head), r), tagSize))
+		if err != nil {
+			return Info{}, err
+		}
+		parseID3v2(tag, info.Tags)
+		audioStart = tagSize
+	}
+
+	// 4 KiB holds the largest layer III frame, info header included.
+	if _, err := r.Seek(audioStart, io.SeekStart); err != nil {
+		return Info{}, err
+	}
+	peek, err := io.ReadAll(io.LimitReader(r, 4096))
+	if err != nil {
+		return Info{}, err
+	}
+	pos, first, ok := findMP3Frame(peek, 0)
+	if !ok {
+		return Info{}, errors.New("mp3: no frame header found")
+	}
+	stream := newMP3Stream(peek[pos:], first)
+	audioBytes := audioEnd - audioStart - int64(pos)
+	if stream.headerFrame {
+		audioBytes -= int64(first.frameSize())
+	}
+	if stream.info.Frames == 0 && audioBytes > 0 {
+		// CBR: every frame has the first frame's bitrate, padding averaged.
+		frameBytes := float64(first.samplesPerFrame()) / 8 * float64(first.bitrate) / float64(first.sampleRate)
+		if first.layer == 1 {
+			frameBytes = 48 * float64(first.bitrate) / float64(first.sampleRate)
+		}
+		stream.info.Frames = int(math.Round(float64(audioBytes) / frameBytes))
+	}
+
+	info.SampleRate = stream.sampleRate
+	info.Channels = 2
+	if stream.info.ChannelMode == "mono" {
+		info.Channels = 1
+	}
+	if stream.info.Layer != 3 {
+		info.Codec = "mp" + strconv.Itoa(stream.info.Layer)
+	}
+	decoded := stream.info.Frames * stream.samplesPerFrame
+	if stream.headerFrame {
+		decoded += stream.samplesPerFrame
+	}
+	start, end := stream.trim(decoded)
+	if stream.sampleRate > 0 {
+		info.Duration = float64(end-start) / float64(stream.sampleRate)
+	}
+	info.Bitrate = first.bitrate
+	if stream.info.VBR && stream.info.Frames > 0 && stream.sampleRate > 0 {
+		seconds := float64(stream.info.Frames*stream.samplesPerFrame) / float64(stream.sampleRate)
+		info.Bitrate = int(float64(audioBytes*8)/seconds + 0.5)
+	}
+	return info, nil
+}
+
+// ffprobeOutput is the subset of `ffprobe -print_format json` that Probe
+// reads.
+type ffprobeOutput struct {
+	Streams []struct {
+		CodecType        string            `json:"codec_type"`
+		CodecName        string            `json:"codec_name"`
+		SampleRate       string            `json:"sample_rate"`
+		Channels         int               `json:"channels"`
+		BitsPerSample    int               `json:"bits_per_sample"`
+		BitsPerRawSample string            `json:"bits_per_raw_sample"`
+		Duration         string            `json:"duration"`
+		BitRate          string            `json:"bit_rate"`
+		Tags             map[string]string `json:"tags"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string            `json:"format_name"`
+		Duration   string            `json:"duration"`
+		BitRate    string            `json:"bit_rate"`
+		Tags       map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+func probeFFprobe(ctx context.Context, path string, opts Options) (Info, error) {
+	ffprobe, err := resolveFFprobe(opts.FFmpegPath)
+	if err != nil {
+		return Info{}, fmt.Errorf("%w; ffprobe fallback failed: %v", ErrUnsupported, err)
+	}
+	cmd := exec.CommandContext(ctx, ffprobe, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", path)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return Info{}, ctxErr
+		}
+		return Info{}, fmt.Errorf("%w; ffprobe fallback failed: %v: %s", ErrUnsupported, err, strings.TrimSpace(stderr.String()))
+	}
+	return parseFFprobe(out)
+}
+
+func parseFFprobe(out []byte) (Info, error) {
+	var probe ffprobeOutput
+	if err := json.Unmarshal(out, &probe); err != nil {
+		return Info{}, fmt.Errorf("ffprobe: %w", err)
+	}
+	for _, s := range probe.Streams {
+		if s.CodecType != "audio" {
+			continue
+		}
+		info := Info{
+			Container: strings.Split(probe.Format.FormatName, ",")[0],
+			Codec:     s.CodecName,
+			Channels:  s.Channels,
+			BitDepth:  s.BitsPerSample,
+			Tags:      map[string]string{},
+			Prober:    "ffprobe",
+		}
+		info.SampleRate, _ = strconv.Atoi(s.SampleRate)
+		if bits, err := strconv.Atoi(s.BitsPerRawSample); err == nil && bits > 0 {
+			info.BitDepth = bits
+		}
+		info.Duration = parseFloatOr(s.Duration, parseFloatOr(probe.Format.Duration, 0))
+		info.Bitrate = int(parseFloatOr(s.BitRate, parseFloatOr(probe.Format.BitRate, 0)))
+		for _, tags := range []map[string]string{probe.Format.Tags, s.Tags} {
+			for k, v := range tags {
+				addTag(info.Tags, strings.ToLower(k), v)
+			}
+		}
+		if len(info.Tags) == 0 {
+			info.Tags = nil
+		}
+		return info, nil
+	}
+	return Info{}, fmt.Errorf("%w: no audio stream", ErrUnsupported)
+}
+
+func parseFloatOr(s string, fallback float64) float64 {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v
+	}
+	return fallback
+}
+
+// resolveFFprobe prefers an ffprobe next to an explicit ffmpeg binary.
+func resolveFFprobe(ffmpegPath string) (string, error) {
+	if ffmpegPath != "" {
+		candidate := filepath.Join(filepath.Dir(ffmpegPath), "ffprobe")
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		return "", errors.New("ffprobe not found in PATH")
+	}
+	return ffprobe, nil
+}
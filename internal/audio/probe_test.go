@@ -0,0 +1,233 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTemp(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func vorbisComments(pairs ...string) []byte {
+	out := binary.LittleEndian.AppendUint32(nil, 4)
+	out = append(out, "test"...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(pairs)))
+	for _, p := range pairs {
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(p)))
+		out = append(out, p...)
+	}
+	return out
+}
+
+func TestProbeWAV(t *testing.T) {
+	data := makeWAV(make([]int16, 2*22050), 44100, 2)
+	info := []byte("INFO")
+	for _, kv := range [][2]string{{"INAM", "Tone\x00"}, {"IART", "Band"}} {
+		info = append(info, kv[0]...)
+		info = binary.LittleEndian.AppendUint32(info, uint32(len(kv[1])))
+		info = append(info, kv[1]...)
+		if len(kv[1])%2 == 1 {
+			info = append(info, 0)
+		}
+	}
+	data = append(data, "LIST"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(info)))
+	data = append(data, info...)
+
+	got, err := Probe(t.Context(), writeTemp(t, "a.wav", data), Options{})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if got.Container != "wav" || got.Codec != "pcm" || got.SampleRate != 44100 || got.Channels != 2 || got.BitDepth != 16 {
+		t.Fatalf("unexpected info: %+v", got)
+	}
+	if got.Duration != 0.5 || got.Bitrate != 1411200 || got.Prober != "native" {
+		t.Fatalf("unexpected duration/bitrate: %+v", got)
+	}
+	if got.Tags["title"] != "Tone" || got.Tags["artist"] != "Band" {
+		t.Fatalf("unexpected tags: %v", got.Tags)
+	}
+}
+
+func TestProbeAIFF(t *testing.T) {
+	data := makeAIFF("", 1, 16, 8000, 4000, make([]byte, 8000))
+	data = appendIFFChunk(data, "NAME", []byte("Beep"))
+	binary.BigEndian.PutUint32(data[4:8], uint32(len(data)-8))
+	got, err := Probe(t.Context(), writeTemp(t, "a.aiff", data), Options{})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if got.Container != "aiff" || got.Codec != "pcm" || got.Duration != 0.5 || got.Tags["title"] != "Beep" {
+		t.Fatalf("unexpected info: %+v", got)
+	}
+}
+
+func TestProbeFLAC(t *testing.T) {
+	data := makeFLAC([][]int64{flacSine(4410, 16, 0.5)}, 16, 44100, 1024, 0, 1)
+	// Clear the last-block flag and append a VORBIS_COMMENT block.
+	data[4] &^= 0x80
+	comments := vorbisComments("TITLE=Sine", "TRACKNUMBER=3")
+	block := []byte{0x84, byte(len(comments) >> 16), byte(len(comments) >> 8), byte(len(comments))}
+	block = append(block, comments...)
+	data = append(data[:42:42], append(block, data[42:]...)...)
+
+	got, err := Probe(t.Context(), writeTemp(t, "a.flac", data), Options{})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if got.Codec != "flac" || got.SampleRate != 44100 || got.Channels != 1 || got.BitDepth != 16 || got.Duration != 0.1 {
+		t.Fatalf("unexpected info: %+v", got)
+	}
+	if got.Tags["title"] != "Sine" || got.Tags["track"] != "3" || got.Bitrate <= 0 {
+		t.Fatalf("unexpected tags: %+v", got)
+	}
+}
+
+func TestProbeOggOpus(t *testing.T) {
+	head := []byte("OpusHead\x01\x02")
+	head = binary.LittleEndian.AppendUint16(head, 312)
+	head = binary.LittleEndian.AppendUint32(head, 44100)
+	head = append(head, 0, 0, 0)
+	tags := append([]byte("OpusTags"), vorbisComments("ARTIST=Someone")...)
+	packets := [][]byte{head, tags, {0xFC, 0xFF, 0xFE}}
+	data := makeOggPages(packets, []int64{0, 0, 48000 + 312}, 255)
+
+	got, err := Probe(t.Context(), writeTemp(t, "a.opus", data), Options{})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if got.Container != "ogg" || got.Codec != "opus" || got.SampleRate != 48000 || got.Channels != 2 {
+		t.Fatalf("unexpected info: %+v", got)
+	}
+	if got.Duration != 1 || got.Tags["artist"] != "Someone" {
+		t.Fatalf("unexpected duration/tags: %+v", got)
+	}
+}
+
+func TestProbeMP3(t *testing.T) {
+	got, err := Probe(t.Context(), testdataPath(t, "sine.mp3"), Options{})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if got.Codec != "mp3" || got.SampleRate != 44100 || got.Channels != 1 || got.BitDepth != 0 {
+		t.Fatalf("unexpected info: %+v", got)
+	}
+	if math.Abs(got.Duration-1) > 1e-9 || got.Tags["encoder"] != "Lavf62.3.100" {
+		t.Fatalf("unexpected duration/tags: %+v", got)
+	}
+}
+
+// fakeFFprobe installs an ffprobe script printing out and returns the
+// ffmpeg path to pass as Options.FFmpegPath.
+func fakeFFprobe(t *testing.T, out string) string {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\ncat <<'JSON'\n" + out + "\nJSON\n"
+	if err := os.WriteFile(filepath.Join(dir, "ffprobe"), []byte(script), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return filepath.Join(dir, "ffmpeg")
+}
+
+func TestProbeFFprobeFallback(t *testing.T) {
+	ffmpeg := fakeFFprobe(t, `{"streams":[{"codec_type":"video","codec_name":"png"},
+ {"codec_type":"audio","codec_name":"aac","sample_rate":"48000","channels":6,"bits_per_sample":0,"duration":"12.5","bit_rate":"256000","tags":{"language":"eng"}}],
+ "format":{"format_name":"mov,mp4,m4a","duration":"12.6","tags":{"TITLE":"Surround"}}}`)
+	got, err := Probe(t.Context(), writeTemp(t, "a.m4a", []byte("....ftypM4A ")), Options{FFmpegPath: ffmpeg})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if got.Prober != "ffprobe" || got.Container != "mov" || got.Codec != "aac" || got.Channels != 6 || got.SampleRate != 48000 {
+		t.Fatalf("unexpected info: %+v", got)
+	}
+	if got.Duration != 12.5 || got.Bitrate != 256000 || got.Tags["title"] != "Surround" || got.Tags["language"] != "eng" {
+		t.Fatalf("unexpected details: %+v", got)
+	}
+}
+
+func TestProbeNativeFailureFallsBack(t *testing.T) {
+	ffmpeg := fakeFFprobe(t, `{"streams":[{"codec_type":"audio","codec_name":"speex","sample_rate":"16000","channels":1}],
+ "format":{"format_name":"ogg","duration":"2"}}`)
+	speex := makeOggPages([][]byte{append([]byte("Speex   "), make([]byte, 72)...)}, []int64{0}, 255)
+	path := writeTemp(t, "a.spx", speex)
+	got, err := Probe(t.Context(), path, Options{FFmpegPath: ffmpeg})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if got.Prober != "ffprobe" || got.Codec != "speex" || got.Duration != 2 {
+		t.Fatalf("unexpected info: %+v", got)
+	}
+
+	// Without ffprobe, a damaged native header keeps its own error.
+	t.Setenv("PATH", t.TempDir())
+	missing := filepath.Join(t.TempDir(), "ffmpeg")
+	bad := buildRIFF("RIFF", wavChunk("fmt ", 0xFFFFFFF0, fmtChunkBody(1, 1, 8000, 16)))
+	if _, err := Probe(t.Context(), writeTemp(t, "a.wav", bad), Options{FFmpegPath: missing}); err == nil || errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected the native error, got %v", err)
+	}
+	if _, err := Probe(t.Context(), path, Options{FFmpegPath: missing}); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestProbeMP3CBREstimate(t *testing.T) {
+	// 100 unpadded 128 kbps frames without an info header, then ID3v1.
+	var data []byte
+	for i := 0; i < 100; i++ {
+		frame := make([]byte, 417)
+		copy(frame, []byte{0xFF, 0xFB, 0x90, 0x00})
+		data = append(data, frame...)
+	}
+	tag := make([]byte, 128)
+	copy(tag, "TAGLong Song")
+	data = append(data, tag...)
+	got, err := Probe(t.Context(), writeTemp(t, "a.mp3", data), Options{})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if got.Channels != 2 || got.Bitrate != 128000 || got.Tags["title"] != "Long Song" {
+		t.Fatalf("unexpected info: %+v", got)
+	}
+	if want := 100 * 1152 / 44100.0; math.Abs(got.Duration-want) > 1e-9 {
+		t.Fatalf("duration %v, want %v", got.Duration, want)
+	}
+}
+
+func TestParseID3(t *testing.T) {
+	frame := func(id string, body []byte) []byte {
+		out := append([]byte(id), 0, 0, 0, byte(len(body)), 0, 0)
+		return append(out, body...)
+	}
+	var frames []byte
+	frames = append(frames, frame("TIT2", []byte("\x00Caf\xe9"))...)
+	frames = append(frames, frame("TPE1", []byte("\x01\xff\xfeA\x00B\x00"))...)
+	frames = append(frames, frame("COMM", []byte("\x00engdesc\x00hello"))...)
+	frames = append(frames, frame("TXXX", []byte("\x03MOOD\x00calm"))...)
+	tag := append([]byte("ID3\x03\x00\x00\x00\x00\x00"), byte(len(frames)))
+	tag = append(tag, frames...)
+
+	tags := map[string]string{}
+	parseID3v2(tag, tags)
+	if tags["title"] != "Café" || tags["artist"] != "AB" || tags["comment"] != "hello" || tags["mood"] != "calm" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+
+	v1 := make([]byte, 128)
+	copy(v1, "TAGOld Title")
+	copy(v1[33:], "Old Artist")
+	v1[126] = 7
+	parseID3v1(v1, tags)
+	if tags["title"] != "Café" || tags["track"] != "7" {
+		t.Fatalf("unexpected v1 merge: %v", tags)
+	}
+}
@@ -0,0 +1,90 @@
+package audio
+
+import (
+	"math"
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestDecodeWAVRange(t *testing.T) {
+	samples := make([]int16, 2*100)
+	for i := range samples {
+		samples[i] = int16(i * 100)
+	}
+	data := makeWAV(samples, 10, 2)
+	pcm, err := DecodeBytes(t.Context(), data, Options{Start: 2, Duration: 3})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	if len(pcm.Samples) != 30 || pcm.NumChannels() != 2 || pcm.Metadata.Codec != "wav" {
+		t.Fatalf("samples = %d, channels = %d", len(pcm.Samples), pcm.NumChannels())
+	}
+	if pcm.Channels[0][0] != float64(40*100)/32768 || pcm.Channels[1][29] != float64(99*100)/32768 {
+		t.Fatalf("unexpected range: %v", pcm.Channels[0][:2])
+	}
+
+	if _, err := DecodeBytes(t.Context(), data, Options{Start: 20}); err == nil {
+		t.Fatalf("expected error for start beyond end")
+	}
+	if _, err := DecodeBytes(t.Context(), data, Options{Start: -1}); err == nil {
+		t.Fatalf("expected error for negative start")
+	}
+}
+
+func TestDecodeMP3Range(t *testing.T) {
+	data, err := os.ReadFile(testdataPath(t, "sine.mp3"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	full, err := DecodeBytes(t.Context(), data, Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	want, err := Slice(full, 0.6, 0.25)
+	if err != nil {
+		t.Fatalf("Slice: %v", err)
+	}
+	got, err := DecodeBytes(t.Context(), data, Options{Start: 0.6, Duration: 0.25})
+	if err != nil {
+		t.Fatalf("DecodeBytes range: %v", err)
+	}
+	if len(got.Samples) != len(want.Samples) || got.Metadata.MP3 == nil {
+		t.Fatalf("samples = %d, want %d", len(got.Samples), len(want.Samples))
+	}
+	for i := range want.Samples {
+		if math.Abs(got.Samples[i]-want.Samples[i]) > 1e-3 {
+			t.Fatalf("sample %d = %f, want %f", i, got.Samples[i], want.Samples[i])
+		}
+	}
+
+	if _, err := DecodeBytes(t.Context(), data, Options{Start: 5}); err == nil {
+		t.Fatalf("expected error for start beyond end")
+	}
+}
+
+func TestDecodeAIFFRange(t *testing.T) {
+	frames := []byte{0x10, 0x00, 0x20, 0x00, 0x30, 0x00, 0x40, 0x00}
+	pcm, err := DecodeBytes(t.Context(), makeAIFF("", 1, 16, 4, 4, frames), Options{Start: 0.5})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	if len(pcm.Samples) != 2 || pcm.Samples[0] != 0.375 || pcm.Metadata.Codec != "aiff" {
+		t.Fatalf("unexpected audio: %+v", pcm)
+	}
+}
+
+func TestFFmpegArgsRange(t *testing.T) {
+	args := ffmpegArgs("in.opus", false, Options{SampleRate: 44100, Start: 90.5, Duration: 10})
+	ss := slices.Index(args, "-ss")
+	in := slices.Index(args, "-i")
+	if ss < 0 || ss > in || args[ss+1] != "90.5" {
+		t.Fatalf("expected input seek, got %v", args)
+	}
+	if i := slices.Index(args, "-t"); i < 0 || args[i+1] != "10" {
+		t.Fatalf("expected -t, got %v", args)
+	}
+	if slices.Contains(ffmpegArgs("in.opus", false, Options{SampleRate: 44100}), "-ss") {
+		t.Fatalf("unexpected -ss without start")
+	}
+}
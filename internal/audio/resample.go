@@ -0,0 +1,121 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+const (
+	// resampleZeros is the filter half-width in zero crossings of the sinc.
+	resampleZeros = 32
+	// resampleOversample is the number of kernel table entries per zero crossing.
+	resampleOversample = 512
+	// resampleBeta shapes the Kaiser window (about 90 dB stopband attenuation).
+	resampleBeta = 9.0
+	// resampleRolloff places the cutoff just below Nyquist to leave room
+	// for the transition band.
+	resampleRolloff = 0.945
+)
+
+var (
+	resampleKernelOnce sync.Once
+	resampleKernel     []float64
+)
+
+// Resample converts a to sampleRate with a Kaiser-windowed sinc filter. The
+// filter low-passes below the lower of the two Nyquist frequencies, so
+// downsampling does not alias.
+func Resample(a Audio, sampleRate int) (Audio, error) {
+	if sampleRate <= 0 || a.SampleRate <= 0 {
+		return Audio{}, fmt.Errorf("resample: invalid sample rate")
+	}
+	if sampleRate == a.SampleRate {
+		return a, nil
+	}
+	channels := a.Channels
+	if len(channels) == 0 {
+		channels = [][]float64{a.Samples}
+	}
+
+	out := make([][]float64, len(channels))
+	var wg sync.WaitGroup
+	for ch, samples := range channels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out[ch] = resampleChannel(samples, a.SampleRate, sampleRate)
+		}()
+	}
+	wg.Wait()
+
+	res := NewAudio(sampleRate, out)
+	res.Metadata = a.Metadata
+	return res, nil
+}
+
+func resampleChannel(in []float64, from, to int) []float64 {
+	kernel := sincKernel()
+	n := (int64(len(in))*int64(to) + int64(from) - 1) / int64(from)
+	out := make([]float64, n)
+
+	// Widen the kernel by 1/scale when downsampling to lower the cutoff.
+	scale := resampleRolloff * math.Min(1, float64(to)/float64(from))
+	reach := float64(resampleZeros) / scale
+	step := scale * resampleOversample
+	for i := range out {
+		// Output sample i sits at input time t, computed exactly from integers.
+		t := float64(int64(i)*int64(from)) / float64(to)
+		lo := max(int(math.Ceil(t-reach)), 0)
+		hi := min(int(math.Floor(t+reach)), len(in)-1)
+		var sum float64
+		for k := lo; k <= hi; k++ {
+			pos := math.Abs(t-float64(k)) * step
+			idx := int(pos)
+			if idx+1 >= len(kernel) {
+				continue
+			}
+			frac := pos - float64(idx)
+			sum += in[k] * (kernel[idx] + frac*(kernel[idx+1]-kernel[idx]))
+		}
+		out[i] = sum * scale
+	}
+	return out
+}
+
+// sincKernel returns one side of the windowed-sinc filter, sampled
+// resampleOversample times per zero crossing.
+func sincKernel() []float64 {
+	resampleKernelOnce.Do(func() {
+		size := resampleZeros*resampleOversample + 1
+		resampleKernel = make([]float64, size+1)
+		norm := besselI0(resampleBeta)
+		for i := 0; i < size; i++ {
+			x := float64(i) / resampleOversample
+			r := x / resampleZeros
+			w := besselI0(resampleBeta*math.Sqrt(1-r*r)) / norm
+			resampleKernel[i] = sinc(x) * w
+		}
+	})
+	return resampleKernel
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// besselI0 is the zeroth-order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-16 {
+			break
+		}
+	}
+	return sum
+}
@@ -0,0 +1,80 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func sineAt(freq float64, sampleRate, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = 0.5 * math.Sin(2*math.Pi*freq*float64(i)/float64(sampleRate))
+	}
+	return out
+}
+
+func TestResampleSine(t *testing.T) {
+	in := NewAudio(48000, [][]float64{sineAt(1000, 48000, 48000), sineAt(3000, 48000, 48000)})
+	in.Metadata.Codec = "wav"
+	out, err := Resample(in, 44100)
+	if err != nil {
+		t.Fatalf("Resample: %v", err)
+	}
+	if out.SampleRate != 44100 || len(out.Samples) != 44100 || out.NumChannels() != 2 || out.Metadata.Codec != "wav" {
+		t.Fatalf("unexpected output: rate %d, %d samples, %d channels", out.SampleRate, len(out.Samples), out.NumChannels())
+	}
+	want := sineAt(1000, 44100, 44100)
+	var worst float64
+	for i := 1000; i < 43000; i++ {
+		worst = math.Max(worst, math.Abs(out.Channels[0][i]-want[i]))
+	}
+	if worst > 1e-3 {
+		t.Fatalf("max error = %g", worst)
+	}
+}
+
+func TestResampleUpsample(t *testing.T) {
+	in := Audio{SampleRate: 8000, Samples: sineAt(440, 8000, 8000)}
+	out, err := Resample(in, 22050)
+	if err != nil {
+		t.Fatalf("Resample: %v", err)
+	}
+	if len(out.Samples) != 22050 {
+		t.Fatalf("samples = %d", len(out.Samples))
+	}
+	want := sineAt(440, 22050, 22050)
+	for i := 2000; i < 20000; i++ {
+		if math.Abs(out.Samples[i]-want[i]) > 1e-3 {
+			t.Fatalf("sample %d = %f, want %f", i, out.Samples[i], want[i])
+		}
+	}
+}
+
+func TestResampleRejectsAliases(t *testing.T) {
+	// 6 kHz is above the 4 kHz Nyquist of the target rate.
+	in := Audio{SampleRate: 44100, Samples: sineAt(6000, 44100, 44100)}
+	out, err := Resample(in, 8000)
+	if err != nil {
+		t.Fatalf("Resample: %v", err)
+	}
+	var sum float64
+	for _, v := range out.Samples[500:7500] {
+		sum += v * v
+	}
+	if rms := math.Sqrt(sum / 7000); rms > 1e-3 {
+		t.Fatalf("alias rms = %g", rms)
+	}
+}
+
+func TestResampleErrors(t *testing.T) {
+	a := Audio{SampleRate: 44100, Samples: []float64{1, 2}}
+	if out, err := Resample(a, 44100); err != nil || len(out.Samples) != 2 {
+		t.Fatalf("same-rate resample = %v, %v", out.Samples, err)
+	}
+	if _, err := Resample(a, 0); err == nil {
+		t.Fatalf("expected error for target rate")
+	}
+	if _, err := Resample(Audio{Samples: []float64{1}}, 8000); err == nil {
+		t.Fatalf("expected error for source rate")
+	}
+}
@@ -14,20 +14,57 @@ func Slice(a Audio, startSec, durationSec float64) (Audio, error) {
 		return Audio{}, fmt.Errorf("slice: empty samples")
 	}
 
-	start := int(startSec * float64(a.SampleRate))
-	if start >= len(a.Samples) {
-		return Audio{}, fmt.Errorf("slice: start beyond end")
+	start, end, err := sliceRange(len(a.Samples), a.SampleRate, startSec, durationSec)
+	if err != nil {
+		return Audio{}, err
 	}
-	end := len(a.Samples)
+
+	out := Audio{SampleRate: a.SampleRate, Samples: a.Samples[start:end], Metadata: a.Metadata}
+	if len(a.Channels) > 0 {
+		out.Channels = make([][]float64, len(a.Channels))
+		for i, ch := range a.Channels {
+			out.Channels[i] = ch[start:end]
+		}
+	}
+	return out, nil
+}
+
+// sliceRange maps a start and duration in seconds to the sample range
+// [start, end) of a signal with total samples.
+func sliceRange(total, sampleRate int, startSec, durationSec float64) (int, int, error) {
+	start := int(startSec * float64(sampleRate))
+	if start >= total {
+		return 0, 0, fmt.Errorf("slice: start beyond end")
+	}
+	end := total
 	if durationSec > 0 {
-		end = start + int(durationSec*float64(a.SampleRate))
-		if end > len(a.Samples) {
-			end = len(a.Samples)
+		end = start + int(durationSec*float64(sampleRate))
+		if end > total {
+			end = total
 		}
 		if end <= start {
-			return Audio{}, fmt.Errorf("slice: duration too short")
+			return 0, 0, fmt.Errorf("slice: duration too short")
 		}
 	}
+	return start, end, nil
+}
 
-	return Audio{SampleRate: a.SampleRate, Samples: a.Samples[start:end]}, nil
+// hasRange reports whether opts limits decoding to part of the input.
+func (o Options) hasRange() bool {
+	return o.Start > 0 || o.Duration > 0
+}
+
+func (o Options) checkRange() error {
+	if o.Start < 0 || o.Duration < 0 {
+		return fmt.Errorf("slice: start and duration must be >= 0")
+	}
+	return nil
+}
+
+// slice applies the decode range in opts to fully decoded audio.
+func (o Options) slice(a Audio) (Audio, error) {
+	if !o.hasRange() {
+		return a, nil
+	}
+	return Slice(a, o.Start, o.Duration)
 }
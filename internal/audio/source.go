@@ -0,0 +1,136 @@
+package audio
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// DefaultBlockSize is the number of frames ReadAll requests per Read.
+const DefaultBlockSize = 4096
+
+// Source is a pull-based stream of decoded audio. Memory use is bounded by
+// the block size the caller reads with, not by the length of the input.
+type Source interface {
+	// SampleRate returns the sample rate in Hz.
+	SampleRate() int
+	// Channels returns the number of channels per frame.
+	Channels() int
+	// Read decodes up to len(buf[0]) frames into buf, which must hold one
+	// slice per channel, and returns the number of frames written. At the
+	// end of the stream it returns 0, io.EOF.
+	Read(buf [][]float64) (int, error)
+	// Close releases the underlying decoder or process.
+	Close() error
+}
+
+// NewBlock allocates a read buffer of frames for src.
+func NewBlock(src Source, frames int) [][]float64 {
+	return makeChannels(src.Channels(), frames)
+}
+
+// ReadAll drains src into Audio.
+func ReadAll(src Source) (Audio, error) {
+	return readSource(context.Background(), src, Options{})
+}
+
+// readSource drains src into Audio, keeping only the range selected by opts
+// and failing with ErrTooLong past opts.MaxDuration. It stops reading once
+// the range is complete, so it terminates on unbounded streams when
+// opts.Duration is set.
+func readSource(ctx context.Context, src Source, opts Options) (Audio, error) {
+	rate := src.SampleRate()
+	skip := int(opts.Start * float64(rate))
+	want := -1
+	if opts.Duration > 0 {
+		want = int(opts.Duration * float64(rate))
+		if want <= 0 {
+			return Audio{}, errors.New("slice: duration too short")
+		}
+	}
+	limit := opts.maxFrames(rate)
+	if l, ok := src.(interface{ length() int64 }); ok {
+		if err := opts.checkLength(l.length(), rate); err != nil {
+			return Audio{}, err
+		}
+	}
+
+	block := NewBlock(src, DefaultBlockSize)
+	channels := make([][]float64, src.Channels())
+	frames := 0
+	for want < 0 || frames < want {
+		if err := contextError(ctx); err != nil {
+			return Audio{}, err
+		}
+		n, err := src.Read(block)
+		from := min(skip, n)
+		skip -= from
+		to := n
+		if want >= 0 {
+			to = min(n, from+want-frames)
+		}
+		for ch := range channels {
+			channels[ch] = append(channels[ch], block[ch][from:to]...)
+		}
+		frames += to - from
+		if limit >= 0 && frames > limit {
+			return Audio{}, tooLongError(opts)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Audio{}, err
+		}
+	}
+	if frames == 0 && opts.hasRange() {
+		return Audio{}, errors.New("slice: start beyond end")
+	}
+
+	pcm := NewAudio(rate, channels)
+	if m, ok := src.(interface{ metadata() Metadata }); ok {
+		pcm.Metadata = m.metadata()
+	}
+	return pcm, nil
+}
+
+// blockSource adapts a decoder that produces whole blocks, such as FLAC
+// frames or Vorbis packets, to Source. next returns the next block, one
+// slice per channel, or io.EOF at the end of the stream.
+type blockSource struct {
+	rate     int
+	channels int
+	next     func() ([][]float64, error)
+	meta     Metadata
+	// frames is the stream length declared by the header, or 0 when unknown.
+	frames int64
+
+	block [][]float64
+	off   int
+}
+
+func (s *blockSource) SampleRate() int { return s.rate }
+
+func (s *blockSource) Channels() int { return s.channels }
+
+func (s *blockSource) Read(buf [][]float64) (int, error) {
+	for s.block == nil || s.off >= len(s.block[0]) {
+		block, err := s.next()
+		if err != nil {
+			return 0, err
+		}
+		s.block, s.off = block, 0
+	}
+	n := 0
+	for ch := range buf {
+		n = copy(buf[ch], s.block[ch][s.off:])
+	}
+	s.off += n
+	return n, nil
+}
+
+func (s *blockSource) Close() error { return nil }
+
+func (s *blockSource) metadata() Metadata { return s.meta }
+
+func (s *blockSource) length() int64 { return s.frames }
@@ -0,0 +1,146 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestWAVSourceBlocks(t *testing.T) {
+	samples := make([]int16, 2*1000)
+	for i := range samples {
+		samples[i] = int16(i * 13)
+	}
+	data := makeWAV(samples, 22050, 2)
+	want, err := DecodeBytes(t.Context(), data, Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+
+	src, err := NewWAVSource(iotest.OneByteReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("NewWAVSource: %v", err)
+	}
+	if src.SampleRate() != 22050 || src.Channels() != 2 {
+		t.Fatalf("format = %d Hz, %d channels", src.SampleRate(), src.Channels())
+	}
+	block := NewBlock(src, 7)
+	frames := 0
+	for {
+		n, err := src.Read(block)
+		for i := 0; i < n; i++ {
+			if block[0][i] != want.Channels[0][frames+i] || block[1][i] != want.Channels[1][frames+i] {
+				t.Fatalf("frame %d mismatch", frames+i)
+			}
+		}
+		frames += n
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+	if frames != 1000 {
+		t.Fatalf("frames = %d", frames)
+	}
+}
+
+func TestWAVSourceStreamedSize(t *testing.T) {
+	data := makeWAV([]int16{100, 200, 300, 400, 500}, 8000, 1)
+	// Unset data size, plus a truncated trailing sample.
+	binary.LittleEndian.PutUint32(data[40:44], wavStreamSize)
+	data = append(data, 0x01)
+	src, err := NewWAVSource(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewWAVSource: %v", err)
+	}
+	pcm, err := ReadAll(src)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if len(pcm.Samples) != 5 || pcm.Samples[4] != 500.0/32768 {
+		t.Fatalf("samples = %v", pcm.Samples)
+	}
+}
+
+func TestWAVSourceErrors(t *testing.T) {
+	if _, err := NewWAVSource(strings.NewReader("RIFF\x00\x00\x00\x00AVI LIST")); err != ErrUnsupported {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+	dataFirst := []byte("RIFF\x00\x00\x00\x00WAVEdata\x02\x00\x00\x00\x00\x00")
+	if _, err := NewWAVSource(bytes.NewReader(dataFirst)); err == nil {
+		t.Fatalf("expected error for data before fmt")
+	}
+	if _, err := NewWAVSource(strings.NewReader("RIFF\x00\x00\x00\x00WAVE")); err == nil {
+		t.Fatalf("expected error for missing chunks")
+	}
+}
+
+func TestMP3SourceMatchesDecode(t *testing.T) {
+	data, err := os.ReadFile(testdataPath(t, "sine.mp3"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	want, err := decodeMP3(bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatalf("decodeMP3: %v", err)
+	}
+	src, err := NewMP3Source(iotest.HalfReader(bytes.NewReader(data)))
+	if err != nil {
+		t.Fatalf("NewMP3Source: %v", err)
+	}
+	defer func() { _ = src.Close() }()
+	got, err := ReadAll(src)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if got.NumChannels() != 1 || len(got.Samples) != len(want.Samples) {
+		t.Fatalf("got %d samples in %d channels, want %d", len(got.Samples), got.NumChannels(), len(want.Samples))
+	}
+	for i := range want.Samples {
+		if got.Samples[i] != want.Samples[i] {
+			t.Fatalf("sample %d = %f, want %f", i, got.Samples[i], want.Samples[i])
+		}
+	}
+}
+
+func TestMP3SourceNotMP3(t *testing.T) {
+	if _, err := NewMP3Source(strings.NewReader("definitely not audio")); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestFFmpegSource(t *testing.T) {
+	src, err := NewFFmpegSource(t.Context(), "", strings.NewReader("audio"), Options{SampleRate: 22050, FFmpegPath: installFakeFFmpeg(t)})
+	if err != nil {
+		t.Fatalf("NewFFmpegSource: %v", err)
+	}
+	pcm, err := ReadAll(src)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if pcm.NumChannels() != 2 || len(pcm.Samples) != 2 {
+		t.Fatalf("unexpected audio: %+v", pcm)
+	}
+}
+
+func TestFFmpegSourceFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	script := "#!/bin/sh\necho 'invalid data found' >&2\nexit 1\n"
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	_, err := NewFFmpegSource(t.Context(), "input.bin", nil, Options{FFmpegPath: path})
+	if err == nil || !strings.Contains(err.Error(), "invalid data found") {
+		t.Fatalf("expected ffmpeg stderr in error, got %v", err)
+	}
+}
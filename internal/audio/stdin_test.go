@@ -0,0 +1,200 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// endlessReader yields an unbounded stream of the byte b.
+type endlessReader byte
+
+func (r endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+func TestDecodeReaderEndlessWAV(t *testing.T) {
+	header := makeWAV(nil, 8000, 1)
+	binary.LittleEndian.PutUint32(header[40:44], wavStreamSize)
+	stream := io.MultiReader(bytes.NewReader(header), endlessReader(0x10))
+	pcm, err := DecodeReader(t.Context(), stream, Options{Start: 1, Duration: 0.5})
+	if err != nil {
+		t.Fatalf("DecodeReader: %v", err)
+	}
+	if len(pcm.Samples) != 4000 || pcm.Samples[0] != float64(0x1010)/32768 || pcm.Metadata.Codec != "wav" {
+		t.Fatalf("unexpected audio: %d samples, %+v", len(pcm.Samples), pcm.Metadata)
+	}
+
+	stream = io.MultiReader(bytes.NewReader(header), endlessReader(0))
+	if _, err := DecodeReader(t.Context(), stream, Options{MaxDuration: 1}); err == nil {
+		t.Fatalf("expected ErrTooLong")
+	}
+}
+
+func TestDecodeReaderMP3(t *testing.T) {
+	data, err := os.ReadFile(testdataPath(t, "sine.mp3"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	pcm, err := DecodeReader(t.Context(), bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatalf("DecodeReader: %v", err)
+	}
+	if len(pcm.Samples) != 44100 || pcm.Metadata.MP3 == nil || pcm.Metadata.MP3.EncoderDelay != 576 {
+		t.Fatalf("unexpected audio: %d samples, %+v", len(pcm.Samples), pcm.Metadata)
+	}
+}
+
+func TestDecodeReaderID3FLAC(t *testing.T) {
+	flac := makeFLAC([][]int64{flacSine(1000, 16, 0.5)}, 16, 44100, 1024, 0, 1)
+	data := append([]byte("ID3\x04\x00\x00\x00\x00\x00\x0a"), make([]byte, 10)...)
+	data = append(data, flac...)
+	pcm, err := DecodeReader(t.Context(), bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatalf("DecodeReader: %v", err)
+	}
+	if pcm.Metadata.Codec != "flac" || len(pcm.Samples) != 1000 {
+		t.Fatalf("unexpected decode: codec=%q samples=%d", pcm.Metadata.Codec, len(pcm.Samples))
+	}
+}
+
+// truncatedStream yields the first half of data and then fails, so a
+// decoder that buffers the whole input errors while a streaming one can
+// finish a short range first.
+func truncatedStream(data []byte) io.Reader {
+	return io.MultiReader(bytes.NewReader(data[:len(data)/2]), errReader{})
+}
+
+func TestDecodeReaderStreamsNative(t *testing.T) {
+	pcm16 := make([]byte, 2*80000)
+	for i := range pcm16 {
+		pcm16[i] = byte(i)
+	}
+	cases := map[string]struct {
+		data  []byte
+		rate  int
+		codec string
+	}{
+		"aiff":   {makeAIFF("", 1, 16, 8000, 80000, pcm16), 8000, "aiff"},
+		"flac":   {makeFLAC([][]int64{flacSine(80000, 16, 0.5)}, 16, 8000, 1024, 0, flacSubVerbatim), 8000, "flac"},
+		"vorbis": {makeOggVorbis([][]float64{vorbisTestSignal(4*8000, 440, 880)}, 8000, 1), 8000, "vorbis"},
+	}
+	for name, tc := range cases {
+		pcm, err := DecodeReader(t.Context(), truncatedStream(tc.data), Options{Start: 0.25, Duration: 0.5})
+		if err != nil {
+			t.Fatalf("%s: DecodeReader: %v", name, err)
+		}
+		if len(pcm.Samples) != tc.rate/2 || pcm.Metadata.Codec != tc.codec {
+			t.Fatalf("%s: unexpected audio: %d samples, %+v", name, len(pcm.Samples), pcm.Metadata)
+		}
+		if _, err := DecodeReader(t.Context(), truncatedStream(tc.data), Options{MaxDuration: 1}); !errors.Is(err, ErrTooLong) {
+			t.Fatalf("%s: expected ErrTooLong, got %v", name, err)
+		}
+
+		// The streamed decode matches the seekable one.
+		want, err := DecodeBytes(t.Context(), tc.data, Options{})
+		if err != nil {
+			t.Fatalf("%s: DecodeBytes: %v", name, err)
+		}
+		got, err := DecodeReader(t.Context(), bytes.NewReader(tc.data), Options{})
+		if err != nil {
+			t.Fatalf("%s: DecodeReader: %v", name, err)
+		}
+		if len(got.Samples) != len(want.Samples) || got.Samples[1000] != want.Samples[1000] {
+			t.Fatalf("%s: streamed %d samples, want %d", name, len(got.Samples), len(want.Samples))
+		}
+	}
+}
+
+func TestDecodeReaderAIFFCommAfterSound(t *testing.T) {
+	comm := makeAIFF("", 1, 16, 8000, 4, make([]byte, 8))[12:]
+	commChunk := comm[:8+18]
+	body := append([]byte("AIFF"), comm[8+18:]...)
+	body = append(body, commChunk...)
+	data := appendIFFChunk(nil, "FORM", body)
+	if _, err := DecodeReader(t.Context(), bytes.NewReader(data), Options{}); err == nil || !strings.Contains(err.Error(), "before COMM") {
+		t.Fatalf("expected SSND-before-COMM error, got %v", err)
+	}
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
+	if err != nil || len(pcm.Samples) != 4 {
+		t.Fatalf("DecodeBytes: %v, %d samples", err, len(pcm.Samples))
+	}
+}
+
+func TestDecodeReaderLargeID3FLAC(t *testing.T) {
+	flac := makeFLAC([][]int64{flacSine(1000, 16, 0.5)}, 16, 44100, 1024, 0, 1)
+	// A 128 KiB tag does not fit in the peek buffer.
+	size := 128 << 10
+	tag := []byte{'I', 'D', '3', 4, 0, 0, byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	data := append(append(tag, make([]byte, size)...), flac...)
+	pcm, err := DecodeReader(t.Context(), bytes.NewReader(data), Options{})
+	if err != nil {
+		t.Fatalf("DecodeReader: %v", err)
+	}
+	if pcm.Metadata.Codec != "flac" || len(pcm.Samples) != 1000 {
+		t.Fatalf("unexpected decode: codec=%q samples=%d", pcm.Metadata.Codec, len(pcm.Samples))
+	}
+}
+
+func TestPeekOggCodec(t *testing.T) {
+	opus := makeOggPages([][]byte{[]byte("OpusHead\x01\x02\x00\x00\x80\xbb\x00\x00\x00\x00\x00")}, []int64{0}, 255)
+	vorbis := makeOggVorbis([][]float64{make([]float64, 100)}, 8000, 1)
+	for data, want := range map[string]string{string(opus): "opus", string(vorbis): "vorbis", "OggS": ""} {
+		if got := peekOggCodec(bufio.NewReader(strings.NewReader(data))); got != want {
+			t.Fatalf("peekOggCodec = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestDecodeReaderStreamsToFFmpeg(t *testing.T) {
+	fake := installFakeFFmpeg(t)
+	dir := t.TempDir()
+	captured := filepath.Join(dir, "stdin.bin")
+	script := "#!/bin/sh\ncat > '" + captured + "'\nexec '" + fake + "'\n"
+	path := filepath.Join(dir, "ffmpeg")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	input := bytes.Repeat([]byte("ADTS?"), 50000)
+	pcm, err := DecodeReader(t.Context(), bytes.NewReader(input), Options{FFmpegPath: path})
+	if err != nil {
+		t.Fatalf("DecodeReader: %v", err)
+	}
+	if pcm.NumChannels() != 2 {
+		t.Fatalf("channels = %d", pcm.NumChannels())
+	}
+	got, err := os.ReadFile(captured)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(got, input) {
+		t.Fatalf("ffmpeg stdin got %d bytes, want %d", len(got), len(input))
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	cases := map[string]string{
+		"RIFF\x00\x00\x00\x00WAVEfmt ": "wav",
+		"FORM\x00\x00\x00\x00AIFC":     "aiff",
+		"fLaC\x00\x00\x00\x22":         "flac",
+		"OggS\x00\x02":                 "ogg",
+		"ID3\x04\x00":                  "mp3",
+		"\xff\xfb\x90\x00":             "mp3",
+		"\xff\xf1\x50\x80":             "",
+		"RIFF\x00\x00\x00\x00AVI LIST": "",
+	}
+	for head, want := range cases {
+		if got := sniffFormat([]byte(head)); got != want {
+			t.Fatalf("sniffFormat(%q) = %q, want %q", head, got, want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Tag keys are normalized to lowercase names shared across formats.
+var (
+	id3TagNames = map[string]string{
+		"TIT2": "title", "TT2": "title",
+		"TPE1": "artist", "TP1": "artist",
+		"TPE2": "albumartist", "TP2": "albumartist",
+		"TALB": "album", "TAL": "album",
+		"TDRC": "date", "TYER": "date", "TYE": "date",
+		"TRCK": "track", "TRK": "track",
+		"TPOS": "disc", "TPA": "disc",
+		"TCON": "genre", "TCO": "genre",
+		"TCOM": "composer", "TCM": "composer",
+		"TBPM": "bpm", "TBP": "bpm",
+		"TKEY": "key", "TKE": "key",
+		"TSSE": "encoder", "TSS": "encoder",
+		"TCOP": "copyright", "TCR": "copyright",
+	}
+	riffTagNames = map[string]string{
+		"INAM": "title",
+		"IART": "artist",
+		"IPRD": "album",
+		"ICRD": "date",
+		"ITRK": "track",
+		"IPRT": "track",
+		"IGNR": "genre",
+		"ICMT": "comment",
+		"ISFT": "encoder",
+		"ICOP": "copyright",
+		"IENG": "engineer",
+	}
+	aiffTagNames = map[string]string{
+		"NAME": "title",
+		"AUTH": "artist",
+		"ANNO": "comment",
+		"(c) ": "copyright",
+	}
+)
+
+// addTag stores value under key unless it is empty or already set.
+func addTag(tags map[string]string, key, value string) {
+	value = strings.TrimSpace(strings.TrimRight(value, "\x00"))
+	if key == "" || value == "" {
+		return
+	}
+	if _, ok := tags[key]; !ok {
+		tags[key] = value
+	}
+}
+
+// parseID3v2 reads text and comment frames from an ID3v2.2, 2.3 or 2.4 tag.
+func parseID3v2(tag []byte, tags map[string]string) {
+	if len(tag) < 10 || string(tag[0:3]) != "ID3" {
+		return
+	}
+	version := tag[3]
+	end := min(id3Size(tag), len(tag))
+	pos := 10
+	if version >= 3 && tag[5]&0x40 != 0 && pos+4 <= end {
+		// Skip the extended header.
+		size := int(binary.BigEndian.Uint32(tag[pos : pos+4]))
+		if version == 4 {
+			size = syncsafe(tag[pos : pos+4])
+		} else {
+			size += 4
+		}
+		pos += size
+	}
+
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+	for pos+headerLen <= end {
+		id := string(tag[pos : pos+idLen])
+		if tag[pos] == 0 {
+			// Padding.
+			break
+		}
+		var size int
+		switch version {
+		case 2:
+			size = int(tag[pos+3])<<16 | int(tag[pos+4])<<8 | int(tag[pos+5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(tag[pos+4 : pos+8]))
+		default:
+			size = syncsafe(tag[pos+4 : pos+8])
+		}
+		pos += headerLen
+		if size <= 0 || pos+size > end {
+			break
+		}
+		frame := tag[pos : pos+size]
+		pos += size
+
+		switch {
+		case id == "COMM" || id == "COM":
+			// Encoding, 3-byte language, description, text.
+			if len(frame) > 4 {
+				_, text := splitID3Text(frame[0], frame[4:])
+				addTag(tags, "comment", text)
+			}
+		case id == "TXXX" || id == "TXX":
+			if len(frame) > 1 {
+				desc, value := splitID3Text(frame[0], frame[1:])
+				addTag(tags, strings.ToLower(desc), value)
+			}
+		case id[0] == 'T':
+			if len(frame) > 1 {
+				name, ok := id3TagNames[id]
+				if !ok {
+					name = strings.ToLower(id)
+				}
+				// Multiple values are NUL separated; keep them readable.
+				text := strings.ReplaceAll(strings.TrimRight(decodeID3Text(frame[0], frame[1:]), "\x00"), "\x00", "; ")
+				addTag(tags, name, text)
+			}
+		}
+	}
+}
+
+// parseID3v1 reads the fixed-layout tag stored in the last 128 bytes.
+func parseID3v1(tag []byte, tags map[string]string) {
+	if len(tag) != 128 || string(tag[0:3]) != "TAG" {
+		return
+	}
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return latin1(b)
+	}
+	addTag(tags, "title", field(tag[3:33]))
+	addTag(tags, "artist", field(tag[33:63]))
+	addTag(tags, "album", field(tag[63:93]))
+	addTag(tags, "date", field(tag[93:97]))
+	if tag[125] == 0 && tag[126] != 0 {
+		// ID3v1.1 stores the track number after a shortened comment.
+		addTag(tags, "comment", field(tag[97:125]))
+		addTag(tags, "track", strconv.Itoa(int(tag[126])))
+	} else {
+		addTag(tags, "comment", field(tag[97:127]))
+	}
+}
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+// splitID3Text splits an encoded "description NUL value" pair.
+func splitID3Text(encoding byte, b []byte) (string, string) {
+	wide := encoding == 1 || encoding == 2
+	for i := 0; i < len(b); i++ {
+		if !wide && b[i] == 0 {
+			return decodeID3Text(encoding, b[:i]), decodeID3Text(encoding, b[i+1:])
+		}
+		if wide && i%2 == 0 && i+1 < len(b) && b[i] == 0 && b[i+1] == 0 {
+			return decodeID3Text(encoding, b[:i]), decodeID3Text(encoding, b[i+2:])
+		}
+	}
+	return "", decodeID3Text(encoding, b)
+}
+
+// decodeID3Text converts ID3 text in one of its four encodings to UTF-8.
+func decodeID3Text(encoding byte, b []byte) string {
+	switch encoding {
+	case 1, 2:
+		bigEndian := encoding == 2
+		if len(b) >= 2 && b[0] == 0xFF && b[1] == 0xFE {
+			bigEndian, b = false, b[2:]
+		} else if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+			bigEndian, b = true, b[2:]
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			if bigEndian {
+				units[i] = binary.BigEndian.Uint16(b[2*i:])
+			} else {
+				units[i] = binary.LittleEndian.Uint16(b[2*i:])
+			}
+		}
+		return string(utf16.Decode(units))
+	case 3:
+		return string(b)
+	default:
+		return latin1(b)
+	}
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+// parseVorbisComments reads a Vorbis comment block (vendor string, then
+// KEY=value pairs) as used by Vorbis, Opus and FLAC.
+func parseVorbisComments(b []byte, tags map[string]string) {
+	if len(b) < 4 {
+		return
+	}
+	vendor := int(binary.LittleEndian.Uint32(b[0:4]))
+	pos := 4 + vendor
+	if pos+4 > len(b) || vendor < 0 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(b[pos : pos+4]))
+	pos += 4
+	for i := 0; i < count && pos+4 <= len(b); i++ {
+		n := int(binary.LittleEndian.Uint32(b[pos : pos+4]))
+		pos += 4
+		if n < 0 || pos+n > len(b) {
+			return
+		}
+		key, value, ok := strings.Cut(string(b[pos:pos+n]), "=")
+		pos += n
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(key)
+		switch key {
+		case "tracknumber":
+			key = "track"
+		case "discnumber":
+			key = "disc"
+		case "description":
+			key = "comment"
+		}
+		addTag(tags, key, value)
+	}
+}
+
+// parseRIFFInfo reads the subchunks of a LIST/INFO chunk body (after the
+// "INFO" type).
+func parseRIFFInfo(b []byte, tags map[string]string) {
+	for pos := 0; pos+8 <= len(b); {
+		id := string(b[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(b[pos+4 : pos+8]))
+		pos += 8
+		if size < 0 || pos+size > len(b) {
+			return
+		}
+		name, ok := riffTagNames[id]
+		if !ok {
+			name = strings.ToLower(id)
+		}
+		addTag(tags, name, string(b[pos:pos+size]))
+		pos += size + size%2
+	}
+}
@@ -0,0 +1,1437 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// errVorbisEOP marks a read past the end of a Vorbis packet. The spec treats
+// it as a soft condition during audio decode rather than a stream error.
+var errVorbisEOP = errors.New("vorbis: end of packet")
+
+// newVorbisSource decodes a Vorbis I stream given its identification packet
+// and the packet source positioned right after it.
+func newVorbisSource(ident []byte, packets *oggReader) (Source, error) {
+	dec, err := newVorbisDecoder(ident)
+	if err != nil {
+		return nil, err
+	}
+	comment, err := packets.next()
+	if err != nil {
+		return nil, fmt.Errorf("vorbis: missing comment header: %w", err)
+	}
+	if len(comment) < 7 || comment[0] != 3 || string(comment[1:7]) != "vorbis" {
+		return nil, errors.New("vorbis: invalid comment header")
+	}
+	setup, err := packets.next()
+	if err != nil {
+		return nil, fmt.Errorf("vorbis: missing setup header: %w", err)
+	}
+	if err := dec.readSetup(setup); err != nil {
+		return nil, err
+	}
+
+	// One decoded block is held back: the granule position that trims the
+	// last block is only known once the stream has ended.
+	var (
+		held    [][]float64
+		emitted int64
+		done    bool
+	)
+	return &blockSource{
+		rate:     dec.sampleRate,
+		channels: dec.channels,
+		meta:     Metadata{Codec: "vorbis"},
+		next: func() ([][]float64, error) {
+			for !done {
+				packet, err := packets.next()
+				if err == io.EOF {
+					done = true
+					break
+				}
+				if err != nil {
+					return nil, err
+				}
+				out, err := dec.decodePacket(packet)
+				if err != nil {
+					return nil, err
+				}
+				if len(out[0]) == 0 {
+					continue
+				}
+				prev := held
+				held = out
+				if prev != nil {
+					emitted += int64(len(prev[0]))
+					return prev, nil
+				}
+			}
+			if held == nil {
+				return nil, io.EOF
+			}
+			// The final granule position marks the true stream length;
+			// anything past it is padding from the last block.
+			last := held
+			held = nil
+			if end := packets.granule - emitted; packets.granule >= 0 && end < int64(len(last[0])) {
+				end = max(end, 0)
+				for ch := range last {
+					last[ch] = last[ch][:end]
+				}
+			}
+			return last, nil
+		},
+	}, nil
+}
+
+type vorbisDecoder struct {
+	channels   int
+	sampleRate int
+	blocksize  [2]int
+
+	codebooks []vorbisCodebook
+	floors    []vorbisFloor
+	residues  []vorbisResidue
+	mappings  []vorbisMapping
+	modes     []vorbisMode
+
+	imdct   map[int]*vorbisIMDCT
+	windows map[int][]float64
+
+	prev     [][]float64
+	prevSize int
+}
+
+type vorbisMode struct {
+	blockflag bool
+	mapping   int
+}
+
+type vorbisMapping struct {
+	mux       []int
+	submaps   []vorbisSubmap
+	magnitude []int
+	angle     []int
+}
+
+type vorbisSubmap struct {
+	floor   int
+	residue int
+}
+
+func newVorbisDecoder(ident []byte) (*vorbisDecoder, error) {
+	if len(ident) < 30 || ident[0] != 1 || string(ident[1:7]) != "vorbis" {
+		return nil, errors.New("vorbis: invalid identification header")
+	}
+	if binary.LittleEndian.Uint32(ident[7:11]) != 0 {
+		return nil, errors.New("vorbis: unsupported version")
+	}
+	d := &vorbisDecoder{
+		channels:   int(ident[11]),
+		sampleRate: int(binary.LittleEndian.Uint32(ident[12:16])),
+		imdct:      map[int]*vorbisIMDCT{},
+		windows:    map[int][]float64{},
+	}
+	d.blocksize[0] = 1 << (ident[28] & 0x0f)
+	d.blocksize[1] = 1 << (ident[28] >> 4)
+	if d.channels == 0 || d.sampleRate == 0 {
+		return nil, errors.New("vorbis: invalid identification header")
+	}
+	if d.blocksize[0] < 64 || d.blocksize[1] > 8192 || d.blocksize[0] > d.blocksize[1] {
+		return nil, errors.New("vorbis: invalid block sizes")
+	}
+	if ident[29]&1 == 0 {
+		return nil, errors.New("vorbis: missing framing bit")
+	}
+	return d, nil
+}
+
+func (d *vorbisDecoder) readSetup(packet []byte) error {
+	if len(packet) < 7 || packet[0] != 5 || string(packet[1:7]) != "vorbis" {
+		return errors.New("vorbis: invalid setup header")
+	}
+	br := &vorbisBits{data: packet[7:]}
+	if err := d.parseSetup(br); err != nil {
+		if errors.Is(err, errVorbisEOP) {
+			return errors.New("vorbis: truncated setup header")
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *vorbisDecoder) parseSetup(br *vorbisBits) error {
+	count := br.read(8) + 1
+	d.codebooks = make([]vorbisCodebook, count)
+	for i := range d.codebooks {
+		if err := d.codebooks[i].parse(br); err != nil {
+			return err
+		}
+	}
+
+	// Time domain transforms are placeholders in Vorbis I.
+	for i := br.read(6) + 1; i > 0; i-- {
+		if br.read(16) != 0 {
+			return errors.New("vorbis: invalid time domain transform")
+		}
+	}
+
+	d.floors = make([]vorbisFloor, br.read(6)+1)
+	for i := range d.floors {
+		floor, err := parseVorbisFloor(br, len(d.codebooks))
+		if err != nil {
+			return err
+		}
+		d.floors[i] = floor
+	}
+
+	d.residues = make([]vorbisResidue, br.read(6)+1)
+	for i := range d.residues {
+		if err := d.residues[i].parse(br, d.codebooks); err != nil {
+			return err
+		}
+	}
+
+	d.mappings = make([]vorbisMapping, br.read(6)+1)
+	for i := range d.mappings {
+		if err := d.parseMapping(br, &d.mappings[i]); err != nil {
+			return err
+		}
+	}
+
+	d.modes = make([]vorbisMode, br.read(6)+1)
+	for i := range d.modes {
+		flag := br.read(1)
+		windowType := br.read(16)
+		transformType := br.read(16)
+		mapping := int(br.read(8))
+		if windowType != 0 || transformType != 0 || mapping >= len(d.mappings) {
+			return errors.New("vorbis: invalid mode")
+		}
+		d.modes[i] = vorbisMode{blockflag: flag == 1, mapping: mapping}
+	}
+	if br.read(1) != 1 {
+		return errors.New("vorbis: missing setup framing bit")
+	}
+	return br.err
+}
+
+func (d *vorbisDecoder) parseMapping(br *vorbisBits, m *vorbisMapping) error {
+	if br.read(16) != 0 {
+		return errors.New("vorbis: invalid mapping type")
+	}
+	submaps := 1
+	if br.read(1) == 1 {
+		submaps = int(br.read(4)) + 1
+	}
+	if br.read(1) == 1 {
+		steps := int(br.read(8)) + 1
+		width := uint(ilog(uint32(d.channels - 1)))
+		m.magnitude = make([]int, steps)
+		m.angle = make([]int, steps)
+		for i := 0; i < steps; i++ {
+			m.magnitude[i] = int(br.read(width))
+			m.angle[i] = int(br.read(width))
+			if m.magnitude[i] == m.angle[i] || m.magnitude[i] >= d.channels || m.angle[i] >= d.channels {
+				return errors.New("vorbis: invalid channel coupling")
+			}
+		}
+	}
+	if br.read(2) != 0 {
+		return errors.New("vorbis: invalid mapping reserved bits")
+	}
+	m.mux = make([]int, d.channels)
+	if submaps > 1 {
+		for ch := range m.mux {
+			m.mux[ch] = int(br.read(4))
+			if m.mux[ch] >= submaps {
+				return errors.New("vorbis: invalid mapping mux")
+			}
+		}
+	}
+	m.submaps = make([]vorbisSubmap, submaps)
+	for i := range m.submaps {
+		br.read(8) // unused time configuration
+		m.submaps[i].floor = int(br.read(8))
+		m.submaps[i].residue = int(br.read(8))
+		if m.submaps[i].floor >= len(d.floors) || m.submaps[i].residue >= len(d.residues) {
+			return errors.New("vorbis: invalid submap")
+		}
+	}
+	return nil
+}
+
+// decodePacket decodes one audio packet and returns the finished samples per
+// channel. The first packet only primes the overlap buffer.
+func (d *vorbisDecoder) decodePacket(packet []byte) ([][]float64, error) {
+	br := &vorbisBits{data: packet}
+	if br.read(1) != 0 {
+		// Not an audio packet; ignore per spec.
+		return make([][]float64, d.channels), nil
+	}
+	modeBits := uint(ilog(uint32(len(d.modes) - 1)))
+	modeNum := int(br.read(modeBits))
+	if br.err != nil || modeNum >= len(d.modes) {
+		return make([][]float64, d.channels), nil
+	}
+	mode := d.modes[modeNum]
+	n := d.blocksize[0]
+	prevLong, nextLong := false, false
+	if mode.blockflag {
+		n = d.blocksize[1]
+		prevLong = br.read(1) == 1
+		nextLong = br.read(1) == 1
+	}
+	half := n / 2
+	mapping := &d.mappings[mode.mapping]
+
+	spectra := make([][]float64, d.channels)
+	floors := make([][]float64, d.channels)
+	noResidue := make([]bool, d.channels)
+	for ch := 0; ch < d.channels; ch++ {
+		spectra[ch] = make([]float64, half)
+		floor := &d.floors[mapping.submaps[mapping.mux[ch]].floor]
+		curve, err := floor.decode(br, d.codebooks, half)
+		if err != nil {
+			return nil, err
+		}
+		floors[ch] = curve
+		noResidue[ch] = curve == nil
+	}
+	for i := range mapping.magnitude {
+		mag, ang := mapping.magnitude[i], mapping.angle[i]
+		if !noResidue[mag] || !noResidue[ang] {
+			noResidue[mag] = false
+			noResidue[ang] = false
+		}
+	}
+
+	for s, submap := range mapping.submaps {
+		var (
+			vectors [][]float64
+			skip    []bool
+		)
+		for ch := 0; ch < d.channels; ch++ {
+			if mapping.mux[ch] == s {
+				vectors = append(vectors, spectra[ch])
+				skip = append(skip, noResidue[ch])
+			}
+		}
+		if err := d.residues[submap.residue].decode(br, d.codebooks, vectors, skip, half); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := len(mapping.magnitude) - 1; i >= 0; i-- {
+		mag := spectra[mapping.magnitude[i]]
+		ang := spectra[mapping.angle[i]]
+		for j := range mag {
+			mag[j], ang[j] = vorbisUncouple(mag[j], ang[j])
+		}
+	}
+
+	imdct := d.imdctFor(n)
+	window := d.window(n, mode.blockflag, prevLong, nextLong)
+	frames := make([][]float64, d.channels)
+	for ch := 0; ch < d.channels; ch++ {
+		spec := spectra[ch]
+		if floors[ch] == nil {
+			for i := range spec {
+				spec[i] = 0
+			}
+		} else {
+			for i := range spec {
+				spec[i] *= floors[ch][i]
+			}
+		}
+		frame := imdct.inverse(spec)
+		for i := range frame {
+			frame[i] *= window[i]
+		}
+		frames[ch] = frame
+	}
+
+	out := make([][]float64, d.channels)
+	if d.prev != nil {
+		pn := d.prevSize
+		count := pn/4 + n/4
+		for ch := 0; ch < d.channels; ch++ {
+			samples := make([]float64, count)
+			prev := d.prev[ch]
+			cur := frames[ch]
+			for k := 0; k < count; k++ {
+				if p := pn/2 + k; p < pn {
+					samples[k] += prev[p]
+				}
+				if c := k - pn/4 + n/4; c >= 0 && c < n {
+					samples[k] += cur[c]
+				}
+			}
+			out[ch] = samples
+		}
+	}
+	d.prev = frames
+	d.prevSize = n
+	return out, nil
+}
+
+// vorbisUncouple inverts square polar channel coupling.
+func vorbisUncouple(m, a float64) (float64, float64) {
+	if m > 0 {
+		if a > 0 {
+			return m, m - a
+		}
+		return m + a, m
+	}
+	if a > 0 {
+		return m, m + a
+	}
+	return m - a, m
+}
+
+// window returns the slope-shaped window for a block, given its neighbours.
+func (d *vorbisDecoder) window(n int, long, prevLong, nextLong bool) []float64 {
+	key := n
+	if long {
+		if prevLong {
+			key |= 1 << 20
+		}
+		if nextLong {
+			key |= 1 << 21
+		}
+	}
+	if w, ok := d.windows[key]; ok {
+		return w
+	}
+	short := d.blocksize[0]
+	leftStart, leftEnd, leftN := 0, n/2, n/2
+	if long && !prevLong {
+		leftStart = n/4 - short/4
+		leftEnd = n/4 + short/4
+		leftN = short / 2
+	}
+	rightStart, rightEnd, rightN := n/2, n, n/2
+	if long && !nextLong {
+		rightStart = n*3/4 - short/4
+		rightEnd = n*3/4 + short/4
+		rightN = short / 2
+	}
+	w := make([]float64, n)
+	for i := leftStart; i < leftEnd; i++ {
+		s := math.Sin((float64(i-leftStart) + 0.5) / float64(leftN) * math.Pi / 2)
+		w[i] = math.Sin(math.Pi / 2 * s * s)
+	}
+	for i := leftEnd; i < rightStart; i++ {
+		w[i] = 1
+	}
+	for i := rightStart; i < rightEnd; i++ {
+		s := math.Sin((float64(i-rightStart)+0.5)/float64(rightN)*math.Pi/2 + math.Pi/2)
+		w[i] = math.Sin(math.Pi / 2 * s * s)
+	}
+	d.windows[key] = w
+	return w
+}
+
+func (d *vorbisDecoder) imdctFor(n int) *vorbisIMDCT {
+	if m, ok := d.imdct[n]; ok {
+		return m
+	}
+	m := newVorbisIMDCT(n)
+	d.imdct[n] = m
+	return m
+}
+
+// vorbisCodebook holds a decoded codebook: a canonical Huffman tree plus the
+// optional vector quantization lookup table.
+type vorbisCodebook struct {
+	dimensions int
+	entries    int
+	lengths    []uint8
+	codewords  []uint32
+	fast       []int32
+	tree       []int32
+	vectors    []float64
+}
+
+const vorbisFastBits = 10
+
+func (c *vorbisCodebook) parse(br *vorbisBits) error {
+	if br.read(24) != 0x564342 {
+		return errors.New("vorbis: invalid codebook sync")
+	}
+	c.dimensions = int(br.read(16))
+	c.entries = int(br.read(24))
+	if br.err != nil {
+		return br.err
+	}
+	if c.entries == 0 {
+		return errors.New("vorbis: empty codebook")
+	}
+	c.lengths = make([]uint8, c.entries)
+	if br.read(1) == 1 {
+		// Ordered: runs of increasing lengths.
+		length := int(br.read(5)) + 1
+		for i := 0; i < c.entries; {
+			num := int(br.read(uint(ilog(uint32(c.entries - i)))))
+			if br.err != nil {
+				return br.err
+			}
+			if length > 32 || i+num > c.entries {
+				return errors.New("vorbis: invalid ordered codebook")
+			}
+			for j := 0; j < num; j++ {
+				c.lengths[i+j] = uint8(length)
+			}
+			i += num
+			length++
+		}
+	} else {
+		sparse := br.read(1) == 1
+		for i := range c.lengths {
+			if sparse && br.read(1) == 0 {
+				continue
+			}
+			c.lengths[i] = uint8(br.read(5) + 1)
+		}
+	}
+	if br.err != nil {
+		return br.err
+	}
+	if err := c.buildHuffman(); err != nil {
+		return err
+	}
+
+	lookup := br.read(4)
+	switch lookup {
+	case 0:
+	case 1, 2:
+		minValue := vorbisFloat32(br.read(32))
+		delta := vorbisFloat32(br.read(32))
+		valueBits := uint(br.read(4)) + 1
+		sequence := br.read(1) == 1
+		var values int
+		if lookup == 1 {
+			values = lookup1Values(c.entries, c.dimensions)
+		} else {
+			values = c.entries * c.dimensions
+		}
+		mult := make([]float64, values)
+		for i := range mult {
+			mult[i] = float64(br.read(valueBits))
+		}
+		if br.err != nil {
+			return br.err
+		}
+		c.vectors = make([]float64, c.entries*c.dimensions)
+		for e := 0; e < c.entries; e++ {
+			last := 0.0
+			divisor := 1
+			for j := 0; j < c.dimensions; j++ {
+				var off int
+				if lookup == 1 {
+					off = (e / divisor) % values
+					divisor *= values
+				} else {
+					off = e*c.dimensions + j
+				}
+				v := mult[off]*delta + minValue + last
+				if sequence {
+					last = v
+				}
+				c.vectors[e*c.dimensions+j] = v
+			}
+		}
+	default:
+		return errors.New("vorbis: invalid codebook lookup type")
+	}
+	return br.err
+}
+
+// buildHuffman assigns codewords in entry order, always taking the lowest
+// free codeword of the requested length, as specified by Vorbis I.
+func (c *vorbisCodebook) buildHuffman() error {
+	c.codewords = make([]uint32, c.entries)
+	var available [33]uint32
+	used := 0
+	for i, l := range c.lengths {
+		if l == 0 {
+			continue
+		}
+		used++
+		if used == 1 {
+			c.codewords[i] = 0
+			for j := 1; j <= int(l); j++ {
+				available[j] = 1 << (32 - j)
+			}
+			continue
+		}
+		z := int(l)
+		for z > 0 && available[z] == 0 {
+			z--
+		}
+		if z == 0 {
+			return errors.New("vorbis: overspecified codebook")
+		}
+		res := available[z]
+		available[z] = 0
+		c.codewords[i] = res >> (32 - uint(l))
+		if z != int(l) {
+			for y := int(l); y > z; y-- {
+				available[y] = res + 1<<(32-y)
+			}
+		}
+	}
+
+	c.fast = make([]int32, 1<<vorbisFastBits)
+	for i := range c.fast {
+		c.fast[i] = -1
+	}
+	c.tree = []int32{-1, -1}
+	for i, l := range c.lengths {
+		if l == 0 {
+			continue
+		}
+		code := c.codewords[i]
+		rev := bits.Reverse32(code) >> (32 - uint(l))
+		if l <= vorbisFastBits {
+			for j := rev; j < 1<<vorbisFastBits; j += 1 << l {
+				c.fast[j] = int32(i)
+			}
+		}
+		node := 0
+		for b := int(l) - 1; b >= 0; b-- {
+			bit := int(code>>uint(b)) & 1
+			slot := node*2 + bit
+			if b == 0 {
+				c.tree[slot] = int32(-2 - i)
+				break
+			}
+			if c.tree[slot] <= -2 {
+				return errors.New("vorbis: overspecified codebook")
+			}
+			if c.tree[slot] == -1 {
+				c.tree[slot] = int32(len(c.tree) / 2)
+				c.tree = append(c.tree, -1, -1)
+			}
+			node = int(c.tree[slot])
+		}
+	}
+	return nil
+}
+
+// decode reads one Huffman-coded entry number.
+func (c *vorbisCodebook) decode(br *vorbisBits) (int, error) {
+	if avail := br.remaining(); avail > 0 {
+		peek := br.peek(vorbisFastBits)
+		if e := c.fast[peek]; e >= 0 && int(c.lengths[e]) <= avail {
+			br.skip(uint(c.lengths[e]))
+			return int(e), nil
+		}
+	}
+	node := 0
+	for {
+		bit := br.read(1)
+		if br.err != nil {
+			return 0, br.err
+		}
+		next := c.tree[node*2+int(bit)]
+		if next <= -2 {
+			return int(-2 - next), nil
+		}
+		if next < 0 {
+			return 0, errors.New("vorbis: invalid huffman code")
+		}
+		node = int(next)
+	}
+}
+
+// decodeVector reads one entry and returns its VQ vector.
+func (c *vorbisCodebook) decodeVector(br *vorbisBits) ([]float64, error) {
+	if c.vectors == nil {
+		return nil, errors.New("vorbis: codebook has no value mapping")
+	}
+	e, err := c.decode(br)
+	if err != nil {
+		return nil, err
+	}
+	return c.vectors[e*c.dimensions : (e+1)*c.dimensions], nil
+}
+
+func vorbisFloat32(x uint32) float64 {
+	mantissa := float64(x & 0x1fffff)
+	if x&0x80000000 != 0 {
+		mantissa = -mantissa
+	}
+	exponent := int((x & 0x7fe00000) >> 21)
+	return math.Ldexp(mantissa, exponent-788)
+}
+
+func lookup1Values(entries, dimensions int) int {
+	if dimensions <= 0 {
+		return 0
+	}
+	r := int(math.Floor(math.Pow(float64(entries), 1/float64(dimensions))))
+	for pow(r+1, dimensions) <= entries {
+		r++
+	}
+	for r > 0 && pow(r, dimensions) > entries {
+		r--
+	}
+	return r
+}
+
+func pow(base, exp int) int {
+	out := 1
+	for i := 0; i < exp; i++ {
+		out *= base
+		if out > 1<<40 {
+			return out
+		}
+	}
+	return out
+}
+
+func ilog(v uint32) int {
+	return bits.Len32(v)
+}
+
+// vorbisFloor decodes either floor type; exactly one of the configs is set.
+type vorbisFloor struct {
+	zero *vorbisFloor0
+	one  *vorbisFloor1
+}
+
+func parseVorbisFloor(br *vorbisBits, books int) (vorbisFloor, error) {
+	switch br.read(16) {
+	case 0:
+		f := &vorbisFloor0{
+			order:           int(br.read(8)),
+			rate:            int(br.read(16)),
+			barkMapSize:     int(br.read(16)),
+			amplitudeBits:   uint(br.read(6)),
+			amplitudeOffset: int(br.read(8)),
+		}
+		f.books = make([]int, br.read(4)+1)
+		for i := range f.books {
+			f.books[i] = int(br.read(8))
+			if f.books[i] >= books {
+				return vorbisFloor{}, errors.New("vorbis: invalid floor0 book")
+			}
+		}
+		if f.order < 1 || f.rate < 1 || f.barkMapSize < 1 {
+			return vorbisFloor{}, errors.New("vorbis: invalid floor0")
+		}
+		f.maps = map[int][]int{}
+		return vorbisFloor{zero: f}, br.err
+	case 1:
+		f := &vorbisFloor1{}
+		partitions := int(br.read(5))
+		f.partitionClass = make([]int, partitions)
+		maxClass := -1
+		for i := range f.partitionClass {
+			f.partitionClass[i] = int(br.read(4))
+			if f.partitionClass[i] > maxClass {
+				maxClass = f.partitionClass[i]
+			}
+		}
+		f.classes = make([]vorbisFloor1Class, maxClass+1)
+		for i := range f.classes {
+			cls := &f.classes[i]
+			cls.dimensions = int(br.read(3)) + 1
+			cls.subclassBits = uint(br.read(2))
+			if cls.subclassBits > 0 {
+				cls.masterbook = int(br.read(8))
+				if cls.masterbook >= books {
+					return vorbisFloor{}, errors.New("vorbis: invalid floor1 masterbook")
+				}
+			}
+			cls.subclassBooks = make([]int, 1<<cls.subclassBits)
+			for j := range cls.subclassBooks {
+				cls.subclassBooks[j] = int(br.read(8)) - 1
+				if cls.subclassBooks[j] >= books {
+					return vorbisFloor{}, errors.New("vorbis: invalid floor1 subclass book")
+				}
+			}
+		}
+		f.multiplier = int(br.read(2)) + 1
+		rangeBits := uint(br.read(4))
+		f.xs = []int{0, 1 << rangeBits}
+		for _, class := range f.partitionClass {
+			for j := 0; j < f.classes[class].dimensions; j++ {
+				f.xs = append(f.xs, int(br.read(rangeBits)))
+			}
+		}
+		if len(f.xs) > 65 {
+			return vorbisFloor{}, errors.New("vorbis: too many floor1 points")
+		}
+		f.prepare()
+		return vorbisFloor{one: f}, br.err
+	default:
+		return vorbisFloor{}, errors.New("vorbis: invalid floor type")
+	}
+}
+
+// decode returns the floor curve for the packet, or nil when the channel is
+// unused in this frame.
+func (f *vorbisFloor) decode(br *vorbisBits, books []vorbisCodebook, half int) ([]float64, error) {
+	var (
+		curve []float64
+		err   error
+	)
+	if f.zero != nil {
+		curve, err = f.zero.decode(br, books, half)
+	} else {
+		curve, err = f.one.decode(br, books, half)
+	}
+	if errors.Is(err, errVorbisEOP) {
+		return nil, nil
+	}
+	return curve, err
+}
+
+type vorbisFloor0 struct {
+	order           int
+	rate            int
+	barkMapSize     int
+	amplitudeBits   uint
+	amplitudeOffset int
+	books           []int
+	maps            map[int][]int
+}
+
+func (f *vorbisFloor0) decode(br *vorbisBits, books []vorbisCodebook, half int) ([]float64, error) {
+	amplitude := int(br.read(f.amplitudeBits))
+	if br.err != nil {
+		return nil, br.err
+	}
+	if amplitude == 0 {
+		return nil, nil
+	}
+	bookNum := int(br.read(uint(ilog(uint32(len(f.books))))))
+	if br.err != nil {
+		return nil, br.err
+	}
+	if bookNum >= len(f.books) {
+		return nil, errors.New("vorbis: invalid floor0 book number")
+	}
+	book := &books[f.books[bookNum]]
+	coeffs := make([]float64, 0, f.order+book.dimensions)
+	last := 0.0
+	for len(coeffs) < f.order {
+		vec, err := book.decodeVector(br)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range vec {
+			coeffs = append(coeffs, v+last)
+		}
+		last = coeffs[len(coeffs)-1]
+	}
+	coeffs = coeffs[:f.order]
+	cosCoeffs := make([]float64, f.order)
+	for i, c := range coeffs {
+		cosCoeffs[i] = math.Cos(c)
+	}
+
+	barkMap := f.barkMap(half)
+	curve := make([]float64, half)
+	maxAmp := float64(int(1)<<f.amplitudeBits - 1)
+	for i := 0; i < half; {
+		omega := math.Pi * float64(barkMap[i]) / float64(f.barkMapSize)
+		cw := math.Cos(omega)
+		var p, q float64
+		if f.order%2 == 1 {
+			p = 1 - cw*cw
+			for j := 1; j < f.order; j += 2 {
+				p *= 4 * (cosCoeffs[j] - cw) * (cosCoeffs[j] - cw)
+			}
+			q = 0.25
+			for j := 0; j < f.order; j += 2 {
+				q *= 4 * (cosCoeffs[j] - cw) * (cosCoeffs[j] - cw)
+			}
+		} else {
+			p = (1 - cw) / 2
+			q = (1 + cw) / 2
+			for j := 0; j+1 < f.order; j += 2 {
+				q *= 4 * (cosCoeffs[j] - cw) * (cosCoeffs[j] - cw)
+				p *= 4 * (cosCoeffs[j+1] - cw) * (cosCoeffs[j+1] - cw)
+			}
+		}
+		value := math.Exp(0.11512925 * (float64(amplitude)*float64(f.amplitudeOffset)/(maxAmp*math.Sqrt(p+q)) - float64(f.amplitudeOffset)))
+		current := barkMap[i]
+		for i < half && barkMap[i] == current {
+			curve[i] = value
+			i++
+		}
+	}
+	return curve, nil
+}
+
+func (f *vorbisFloor0) barkMap(half int) []int {
+	if m, ok := f.maps[half]; ok {
+		return m
+	}
+	bark := func(x float64) float64 {
+		return 13.1*math.Atan(0.00074*x) + 2.24*math.Atan(0.0000000185*x*x) + 0.0001*x
+	}
+	m := make([]int, half+1)
+	scale := float64(f.barkMapSize) / bark(0.5*float64(f.rate))
+	for i := 0; i < half; i++ {
+		v := int(math.Floor(bark(float64(f.rate)*float64(i)/(2*float64(half))) * scale))
+		if v > f.barkMapSize-1 {
+			v = f.barkMapSize - 1
+		}
+		m[i] = v
+	}
+	m[half] = -1
+	f.maps[half] = m
+	return m
+}
+
+type vorbisFloor1Class struct {
+	dimensions    int
+	subclassBits  uint
+	masterbook    int
+	subclassBooks []int
+}
+
+type vorbisFloor1 struct {
+	partitionClass []int
+	classes        []vorbisFloor1Class
+	multiplier     int
+	xs             []int
+	sorted         []int
+	low            []int
+	high           []int
+}
+
+func (f *vorbisFloor1) prepare() {
+	n := len(f.xs)
+	f.sorted = make([]int, n)
+	for i := range f.sorted {
+		f.sorted[i] = i
+	}
+	sort.SliceStable(f.sorted, func(a, b int) bool { return f.xs[f.sorted[a]] < f.xs[f.sorted[b]] })
+	f.low = make([]int, n)
+	f.high = make([]int, n)
+	for i := 2; i < n; i++ {
+		lo, hi := 0, 1
+		loX, hiX := -1, 1<<30
+		for j := 0; j < i; j++ {
+			x := f.xs[j]
+			if x < f.xs[i] && x > loX {
+				lo, loX = j, x
+			}
+			if x > f.xs[i] && x < hiX {
+				hi, hiX = j, x
+			}
+		}
+		f.low[i] = lo
+		f.high[i] = hi
+	}
+}
+
+var floor1Ranges = [4]int{256, 128, 86, 64}
+
+func (f *vorbisFloor1) decode(br *vorbisBits, books []vorbisCodebook, half int) ([]float64, error) {
+	if br.read(1) == 0 {
+		if br.err != nil {
+			return nil, br.err
+		}
+		return nil, nil
+	}
+	rng := floor1Ranges[f.multiplier-1]
+	ys := make([]int, len(f.xs))
+	yBits := uint(ilog(uint32(rng - 1)))
+	ys[0] = int(br.read(yBits))
+	ys[1] = int(br.read(yBits))
+	offset := 2
+	for _, class := range f.partitionClass {
+		cls := &f.classes[class]
+		csub := 1<<cls.subclassBits - 1
+		cval := 0
+		if cls.subclassBits > 0 {
+			v, err := books[cls.masterbook].decode(br)
+			if err != nil {
+				return nil, err
+			}
+			cval = v
+		}
+		for j := 0; j < cls.dimensions; j++ {
+			book := cls.subclassBooks[cval&csub]
+			cval >>= cls.subclassBits
+			if book >= 0 {
+				v, err := books[book].decode(br)
+				if err != nil {
+					return nil, err
+				}
+				ys[offset+j] = v
+			}
+		}
+		offset += cls.dimensions
+	}
+	if br.err != nil {
+		return nil, br.err
+	}
+
+	// Amplitude value synthesis.
+	step2 := make([]bool, len(f.xs))
+	final := make([]int, len(f.xs))
+	step2[0], step2[1] = true, true
+	final[0], final[1] = ys[0], ys[1]
+	for i := 2; i < len(f.xs); i++ {
+		lo, hi := f.low[i], f.high[i]
+		predicted := renderPoint(f.xs[lo], final[lo], f.xs[hi], final[hi], f.xs[i])
+		val := ys[i]
+		highroom := rng - predicted
+		lowroom := predicted
+		room := highroom
+		if lowroom < room {
+			room = lowroom
+		}
+		room *= 2
+		if val == 0 {
+			final[i] = predicted
+			continue
+		}
+		step2[lo], step2[hi], step2[i] = true, true, true
+		switch {
+		case val >= room:
+			if highroom > lowroom {
+				final[i] = val - lowroom + predicted
+			} else {
+				final[i] = predicted - val + highroom - 1
+			}
+		case val%2 == 1:
+			final[i] = predicted - (val+1)/2
+		default:
+			final[i] = predicted + val/2
+		}
+	}
+
+	// Curve synthesis.
+	levels := make([]int, half)
+	lx, ly := 0, final[f.sorted[0]]*f.multiplier
+	hx, hy := 0, ly
+	for _, idx := range f.sorted[1:] {
+		if !step2[idx] {
+			continue
+		}
+		hy = final[idx] * f.multiplier
+		hx = f.xs[idx]
+		renderLine(lx, ly, hx, hy, levels)
+		lx, ly = hx, hy
+	}
+	if hx < half {
+		renderLine(hx, hy, half, hy, levels)
+	}
+	curve := make([]float64, half)
+	for i, y := range levels {
+		if y < 0 {
+			y = 0
+		}
+		if y > 255 {
+			y = 255
+		}
+		curve[i] = floor1InverseDB[y]
+	}
+	return curve, nil
+}
+
+func renderPoint(x0, y0, x1, y1, x int) int {
+	dy := y1 - y0
+	adx := x1 - x0
+	if adx == 0 {
+		return y0
+	}
+	ady := dy
+	if ady < 0 {
+		ady = -ady
+	}
+	off := ady * (x - x0) / adx
+	if dy < 0 {
+		return y0 - off
+	}
+	return y0 + off
+}
+
+func renderLine(x0, y0, x1, y1 int, v []int) {
+	dy := y1 - y0
+	adx := x1 - x0
+	if adx <= 0 {
+		return
+	}
+	ady := dy
+	if ady < 0 {
+		ady = -ady
+	}
+	base := dy / adx
+	sy := base + 1
+	if dy < 0 {
+		sy = base - 1
+	}
+	absBase := base
+	if absBase < 0 {
+		absBase = -absBase
+	}
+	ady -= absBase * adx
+	x, y, errAcc := x0, y0, 0
+	if x < len(v) {
+		v[x] = y
+	}
+	for x = x0 + 1; x < x1; x++ {
+		errAcc += ady
+		if errAcc >= adx {
+			errAcc -= adx
+			y += sy
+		} else {
+			y += base
+		}
+		if x < len(v) {
+			v[x] = y
+		}
+	}
+}
+
+// floor1InverseDB maps floor1 amplitude levels onto linear gain. The table in
+// the spec is geometric from 1.0649863e-07 up to 1.0.
+var floor1InverseDB = func() [256]float64 {
+	var t [256]float64
+	ratio := math.Log(1 / 1.0649863e-07)
+	for i := range t {
+		t[i] = math.Exp(float64(i-255) / 255 * ratio)
+	}
+	return t
+}()
+
+type vorbisResidue struct {
+	kind            int
+	begin           int
+	end             int
+	partitionSize   int
+	classifications int
+	classbook       int
+	books           [][8]int
+}
+
+func (r *vorbisResidue) parse(br *vorbisBits, codebooks []vorbisCodebook) error {
+	r.kind = int(br.read(16))
+	if r.kind > 2 {
+		return errors.New("vorbis: invalid residue type")
+	}
+	r.begin = int(br.read(24))
+	r.end = int(br.read(24))
+	r.partitionSize = int(br.read(24)) + 1
+	r.classifications = int(br.read(6)) + 1
+	r.classbook = int(br.read(8))
+	if r.classbook >= len(codebooks) {
+		return errors.New("vorbis: invalid residue classbook")
+	}
+	cascade := make([]uint32, r.classifications)
+	for i := range cascade {
+		low := br.read(3)
+		high := uint32(0)
+		if br.read(1) == 1 {
+			high = br.read(5)
+		}
+		cascade[i] = high<<3 | low
+	}
+	r.books = make([][8]int, r.classifications)
+	for i := range r.books {
+		for j := 0; j < 8; j++ {
+			r.books[i][j] = -1
+			if cascade[i]&(1<<uint(j)) != 0 {
+				r.books[i][j] = int(br.read(8))
+				if r.books[i][j] >= len(codebooks) {
+					return errors.New("vorbis: invalid residue book")
+				}
+			}
+		}
+	}
+	return br.err
+}
+
+// decode adds the residue into the channel vectors; skip marks channels whose
+// floor was unused.
+func (r *vorbisResidue) decode(br *vorbisBits, books []vorbisCodebook, vectors [][]float64, skip []bool, half int) error {
+	if len(vectors) == 0 {
+		return nil
+	}
+	if r.kind == 2 {
+		decodeAny := false
+		for _, s := range skip {
+			if !s {
+				decodeAny = true
+			}
+		}
+		if !decodeAny {
+			return nil
+		}
+		ch := len(vectors)
+		interleaved := make([]float64, half*ch)
+		if err := r.decodeFormat(br, books, [][]float64{interleaved}, []bool{false}, half*ch, 1); err != nil {
+			return err
+		}
+		for i := 0; i < half; i++ {
+			for j := 0; j < ch; j++ {
+				vectors[j][i] += interleaved[i*ch+j]
+			}
+		}
+		return nil
+	}
+	return r.decodeFormat(br, books, vectors, skip, half, r.kind)
+}
+
+func (r *vorbisResidue) decodeFormat(br *vorbisBits, books []vorbisCodebook, vectors [][]float64, skip []bool, size, format int) error {
+	begin, end := r.begin, r.end
+	if begin > size {
+		begin = size
+	}
+	if end > size {
+		end = size
+	}
+	if end <= begin {
+		return nil
+	}
+	partitions := (end - begin) / r.partitionSize
+	if partitions == 0 {
+		return nil
+	}
+	classbook := &books[r.classbook]
+	perWord := classbook.dimensions
+	if perWord < 1 {
+		return errors.New("vorbis: invalid residue classbook dimensions")
+	}
+	classes := make([][]int, len(vectors))
+	for i := range classes {
+		classes[i] = make([]int, partitions+perWord)
+	}
+
+	for pass := 0; pass < 8; pass++ {
+		for p := 0; p < partitions; {
+			if pass == 0 {
+				for j := range vectors {
+					if skip[j] {
+						continue
+					}
+					temp, err := classbook.decode(br)
+					if err != nil {
+						return eopOK(err)
+					}
+					for i := perWord - 1; i >= 0; i-- {
+						classes[j][p+i] = temp % r.classifications
+						temp /= r.classifications
+					}
+				}
+			}
+			for i := 0; i < perWord && p < partitions; i++ {
+				for j, vec := range vectors {
+					if skip[j] {
+						continue
+					}
+					book := r.books[classes[j][p]][pass]
+					if book < 0 {
+						continue
+					}
+					offset := begin + p*r.partitionSize
+					if err := r.decodePartition(br, &books[book], vec[offset:offset+r.partitionSize], format); err != nil {
+						return eopOK(err)
+					}
+				}
+				p++
+			}
+		}
+	}
+	return nil
+}
+
+func (r *vorbisResidue) decodePartition(br *vorbisBits, book *vorbisCodebook, out []float64, format int) error {
+	dims := book.dimensions
+	if dims < 1 {
+		return errors.New("vorbis: invalid residue book dimensions")
+	}
+	if format == 0 {
+		step := len(out) / dims
+		for i := 0; i < step; i++ {
+			vec, err := book.decodeVector(br)
+			if err != nil {
+				return err
+			}
+			for j, v := range vec {
+				out[i+j*step] += v
+			}
+		}
+		return nil
+	}
+	for i := 0; i < len(out); {
+		vec, err := book.decodeVector(br)
+		if err != nil {
+			return err
+		}
+		for _, v := range vec {
+			if i >= len(out) {
+				break
+			}
+			out[i] += v
+			i++
+		}
+	}
+	return nil
+}
+
+// eopOK swallows end-of-packet conditions, which simply end residue decode.
+func eopOK(err error) error {
+	if errors.Is(err, errVorbisEOP) {
+		return nil
+	}
+	return err
+}
+
+// vorbisBits is an LSB-first bit reader over a single packet.
+type vorbisBits struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (b *vorbisBits) remaining() int {
+	return len(b.data)*8 - b.pos
+}
+
+// read returns up to 32 bits; past the end it records errVorbisEOP and
+// returns zero.
+func (b *vorbisBits) read(n uint) uint32 {
+	if n == 0 {
+		return 0
+	}
+	if b.err != nil || int(n) > b.remaining() {
+		b.err = errVorbisEOP
+		b.pos = len(b.data) * 8
+		return 0
+	}
+	v := b.peek(n)
+	b.pos += int(n)
+	return v
+}
+
+// peek returns the next n bits without consuming them, zero-padded at the end.
+func (b *vorbisBits) peek(n uint) uint32 {
+	var v uint64
+	got := uint(0)
+	pos := b.pos
+	for got < n {
+		idx := pos >> 3
+		if idx >= len(b.data) {
+			break
+		}
+		shift := uint(pos & 7)
+		v |= uint64(b.data[idx]>>shift) << got
+		taken := 8 - shift
+		got += taken
+		pos += int(taken)
+	}
+	return uint32(v & (1<<n - 1))
+}
+
+func (b *vorbisBits) skip(n uint) {
+	b.pos += int(n)
+}
+
+// vorbisIMDCT computes the unnormalized inverse MDCT through an N-point FFT.
+type vorbisIMDCT struct {
+	n      int
+	pre    []complex128
+	post   []complex128
+	fft    *complexFFT
+	buffer []complex128
+}
+
+func newVorbisIMDCT(n int) *vorbisIMDCT {
+	half := n / 2
+	n0 := 0.5 + float64(half)/2
+	m := &vorbisIMDCT{
+		n:      n,
+		pre:    make([]complex128, half),
+		post:   make([]complex128, n),
+		fft:    newComplexFFT(n),
+		buffer: make([]complex128, n),
+	}
+	for k := 0; k < half; k++ {
+		angle := 2 * math.Pi * n0 * float64(k) / float64(n)
+		m.pre[k] = complex(math.Cos(angle), math.Sin(angle))
+	}
+	for i := 0; i < n; i++ {
+		angle := math.Pi * (float64(i) + n0) / float64(n)
+		m.post[i] = complex(math.Cos(angle), math.Sin(angle))
+	}
+	return m
+}
+
+// inverse maps n/2 coefficients onto n time samples:
+// y[i] = sum_k X[k] cos(2*pi/n * (i + 1/2 + n/4) * (k + 1/2)).
+func (m *vorbisIMDCT) inverse(coeffs []float64) []float64 {
+	buf := m.buffer
+	for k := range buf {
+		buf[k] = 0
+	}
+	for k, c := range coeffs {
+		buf[k] = complex(c, 0) * m.pre[k]
+	}
+	m.fft.inverse(buf)
+	out := make([]float64, m.n)
+	for i := range out {
+		out[i] = real(buf[i] * m.post[i])
+	}
+	return out
+}
+
+// complexFFT is a radix-2 FFT with precomputed twiddles and bit reversal.
+type complexFFT struct {
+	n       int
+	twiddle []complex128
+	rev     []int
+}
+
+func newComplexFFT(n int) *complexFFT {
+	f := &complexFFT{n: n, twiddle: make([]complex128, n/2), rev: make([]int, n)}
+	for i := range f.twiddle {
+		angle := 2 * math.Pi * float64(i) / float64(n)
+		f.twiddle[i] = complex(math.Cos(angle), math.Sin(angle))
+	}
+	shift := 32 - uint(bits.Len32(uint32(n))-1)
+	for i := range f.rev {
+		f.rev[i] = int(bits.Reverse32(uint32(i)) >> shift)
+	}
+	return f
+}
+
+// inverse computes x[i] = sum_k X[k] e^{+2*pi*i*k*i/n} without scaling.
+func (f *complexFFT) inverse(x []complex128) {
+	n := f.n
+	if n <= 1 {
+		return
+	}
+	for i, j := range f.rev {
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	for size := 2; size <= n; size <<= 1 {
+		halfSize := size / 2
+		step := n / size
+		for start := 0; start < n; start += size {
+			for k := 0; k < halfSize; k++ {
+				w := f.twiddle[k*step]
+				u := x[start+k]
+				v := w * x[start+k+halfSize]
+				x[start+k] = u + v
+				x[start+k+halfSize] = u - v
+			}
+		}
+	}
+}
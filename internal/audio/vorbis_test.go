@@ -0,0 +1,584 @@
+package audio
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVorbisIMDCTMatchesDefinition(t *testing.T) {
+	for _, n := range []int{64, 256} {
+		coeffs := make([]float64, n/2)
+		for k := range coeffs {
+			coeffs[k] = math.Sin(float64(k)*0.7) + 0.1*float64(k%5)
+		}
+		got := newVorbisIMDCT(n).inverse(coeffs)
+		for i := 0; i < n; i++ {
+			want := 0.0
+			for k, c := range coeffs {
+				want += c * math.Cos(2*math.Pi/float64(n)*(float64(i)+0.5+float64(n)/4)*(float64(k)+0.5))
+			}
+			if math.Abs(got[i]-want) > 1e-9 {
+				t.Fatalf("n=%d sample %d = %f, want %f", n, i, got[i], want)
+			}
+		}
+	}
+}
+
+func TestVorbisHuffmanSpecExample(t *testing.T) {
+	book := vorbisCodebook{entries: 8, lengths: []uint8{2, 4, 4, 4, 4, 2, 3, 3}}
+	if err := book.buildHuffman(); err != nil {
+		t.Fatalf("buildHuffman: %v", err)
+	}
+	want := []uint32{0b00, 0b0100, 0b0101, 0b0110, 0b0111, 0b10, 0b110, 0b111}
+	for i, code := range want {
+		if book.codewords[i] != code {
+			t.Fatalf("entry %d code = %b, want %b", i, book.codewords[i], code)
+		}
+	}
+
+	w := &vorbisBitWriter{}
+	for _, e := range []int{6, 0, 3, 7, 5} {
+		w.writeCode(book.codewords[e], int(book.lengths[e]))
+	}
+	br := &vorbisBits{data: w.out}
+	for _, e := range []int{6, 0, 3, 7, 5} {
+		got, err := book.decode(br)
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if got != e {
+			t.Fatalf("decoded %d, want %d", got, e)
+		}
+	}
+}
+
+func TestVorbisHuffmanOverspecified(t *testing.T) {
+	book := vorbisCodebook{entries: 3, lengths: []uint8{1, 1, 1}}
+	if err := book.buildHuffman(); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestVorbisHelpers(t *testing.T) {
+	if v := vorbisFloat32(vorbisPackFloat(-1)); v != -1 {
+		t.Fatalf("float32 unpack = %f", v)
+	}
+	if lookup1Values(256, 1) != 256 || lookup1Values(81, 4) != 3 || lookup1Values(80, 4) != 2 {
+		t.Fatalf("lookup1Values mismatch")
+	}
+	cases := [][4]float64{
+		{2, 1, 2, 1},
+		{2, -1, 1, 2},
+		{-2, 1, -2, -1},
+		{-2, -1, -1, -2},
+	}
+	for _, c := range cases {
+		l, r := vorbisUncouple(c[0], c[1])
+		if l != c[2] || r != c[3] {
+			t.Fatalf("uncouple(%v, %v) = %v, %v", c[0], c[1], l, r)
+		}
+	}
+	levels := make([]int, 8)
+	renderLine(0, 10, 8, 2, levels)
+	if levels[0] != 10 || levels[4] != 6 || levels[7] != 3 {
+		t.Fatalf("renderLine = %v", levels)
+	}
+	if floor1InverseDB[255] != 1 || math.Abs(floor1InverseDB[0]-1.0649863e-07) > 1e-12 {
+		t.Fatalf("inverse dB table endpoints")
+	}
+}
+
+func TestDecodeVorbisMono(t *testing.T) {
+	signal := vorbisTestSignal(6000, 440, 3000)
+	data := makeOggVorbis([][]float64{signal}, 44100, 1)
+	pcm, ok, err := DecodeOggIf(bytesReader(data))
+	if err != nil {
+		t.Fatalf("DecodeOggIf: %v", err)
+	}
+	if !ok {
+		t.Fatalf("expected ok=true")
+	}
+	if pcm.SampleRate != 44100 || pcm.Metadata.Codec != "vorbis" {
+		t.Fatalf("sample rate = %d, codec = %q", pcm.SampleRate, pcm.Metadata.Codec)
+	}
+	assertClose(t, pcm.Samples, signal, 0.03)
+}
+
+// TestDecodeVorbisLibvorbisReference decodes a file written by libvorbis
+// 1.3.5 and compares every sample with the reference decoder's float output.
+func TestDecodeVorbisLibvorbisReference(t *testing.T) {
+	raw, err := os.ReadFile(testdataPath(t, "libvorbis.raw"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	want := make([]float64, len(raw)/4)
+	for i := range want {
+		want[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:])))
+	}
+
+	data, err := os.ReadFile(testdataPath(t, "libvorbis.ogg"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	for _, decode := range []func() (Audio, error){
+		func() (Audio, error) { return DecodeBytes(t.Context(), data, Options{}) },
+		func() (Audio, error) { return DecodeReader(t.Context(), bytesReader(data), Options{}) },
+	} {
+		pcm, err := decode()
+		if err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if pcm.SampleRate != 44100 || pcm.NumChannels() != 1 || pcm.Metadata.Codec != "vorbis" {
+			t.Fatalf("sample rate = %d, channels = %d, codec = %q", pcm.SampleRate, pcm.NumChannels(), pcm.Metadata.Codec)
+		}
+		if len(pcm.Samples) != len(want) {
+			t.Fatalf("samples = %d, want %d", len(pcm.Samples), len(want))
+		}
+		for i, v := range pcm.Samples {
+			if math.Abs(v-want[i]) > 5e-5 {
+				t.Fatalf("sample %d = %f, want %f", i, v, want[i])
+			}
+		}
+	}
+}
+
+func TestDecodeVorbisStereoInterleavedResidue(t *testing.T) {
+	left := vorbisTestSignal(5000, 220, 1000)
+	right := vorbisTestSignal(5000, 660, 5000)
+	data := makeOggVorbis([][]float64{left, right}, 48000, 2)
+	path := filepath.Join(t.TempDir(), "stereo.ogg")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	pcm, err := DecodeFile(t.Context(), path, Options{})
+	if err != nil {
+		t.Fatalf("DecodeFile: %v", err)
+	}
+	mix := make([]float64, len(left))
+	for i := range mix {
+		mix[i] = (left[i] + right[i]) / 2
+	}
+	assertClose(t, pcm.Samples, mix, 0.03)
+}
+
+func TestDecodeOggIfOpusFallsThrough(t *testing.T) {
+	head := append([]byte("OpusHead"), 1, 2, 0, 0, 0x80, 0xBB, 0, 0, 0, 0, 0)
+	data := makeOggPages([][]byte{head}, []int64{0}, 255)
+	_, ok, err := DecodeOggIf(bytesReader(data))
+	if err != nil {
+		t.Fatalf("DecodeOggIf error: %v", err)
+	}
+	if ok {
+		t.Fatalf("expected ok=false for opus")
+	}
+}
+
+func TestDecodeOggFLAC(t *testing.T) {
+	samples := flacSine(600, 16, 0.5)
+	native := makeFLAC([][]int64{samples}, 16, 44100, 256, 0, flacSubFixed)
+	// Split the native stream: STREAMINFO block, then one packet per frame.
+	info := native[4 : 4+4+34]
+	first := append([]byte{0x7F, 'F', 'L', 'A', 'C', 1, 0, 0, 0}, []byte("fLaC")...)
+	first = append(first, info...)
+	packets := [][]byte{first}
+	pos := 4 + 4 + 34
+	dec := flacFrameDecoder{info: parseFLACStreamInfo(native[8:42])}
+	for pos < len(native) {
+		n, _, err := dec.decodeFrame(native[pos:])
+		if err != nil {
+			t.Fatalf("decodeFrame: %v", err)
+		}
+		packets = append(packets, native[pos:pos+n])
+		pos += n
+	}
+	granules := make([]int64, len(packets))
+	pcm, err := DecodeBytes(t.Context(), makeOggPages(packets, granules, 255), Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	assertFLACSamples(t, pcm.Samples, [][]int64{samples}, 16)
+}
+
+func TestOggCRCMismatch(t *testing.T) {
+	data := makeOggVorbis([][]float64{vorbisTestSignal(2000, 440, 880)}, 44100, 1)
+	data[len(data)-3] ^= 0x01
+	if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
+		t.Fatalf("expected crc error")
+	}
+}
+
+func assertClose(t *testing.T, got, want []float64, tolerance float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("samples = %d, want %d", len(got), len(want))
+	}
+	var errSum, sigSum float64
+	for i := range got {
+		d := got[i] - want[i]
+		errSum += d * d
+		sigSum += want[i] * want[i]
+	}
+	if ratio := math.Sqrt(errSum / sigSum); ratio > tolerance {
+		t.Fatalf("relative rms error = %f", ratio)
+	}
+}
+
+func vorbisTestSignal(n int, f1, f2 float64) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		t := float64(i) / 44100
+		out[i] = 0.5*math.Sin(2*math.Pi*f1*t) + 0.2*math.Sin(2*math.Pi*f2*t)
+	}
+	return out
+}
+
+const (
+	vorbisTestShort  = 256
+	vorbisTestLong   = 2048
+	vorbisTestFloorY = 247
+)
+
+// makeOggVorbis encodes channels with a tiny but real Vorbis I encoder: a flat
+// floor1 curve, an 8-bit scalar residue book and a fixed short/long block
+// pattern. Stereo input uses residue type 2.
+func makeOggVorbis(channels [][]float64, sampleRate, numChannels int) []byte {
+	total := len(channels[0])
+
+	ident := make([]byte, 30)
+	ident[0] = 1
+	copy(ident[1:7], "vorbis")
+	ident[11] = byte(numChannels)
+	binary.LittleEndian.PutUint32(ident[12:16], uint32(sampleRate))
+	ident[28] = 11<<4 | 8
+	ident[29] = 1
+
+	comment := append([]byte{3}, []byte("vorbis")...)
+	comment = append(comment, 0, 0, 0, 0, 0, 0, 0, 0, 1)
+
+	residueType := 1
+	residueEnd := vorbisTestLong / 2
+	if numChannels > 1 {
+		residueType = 2
+		residueEnd *= numChannels
+	}
+	setup := makeVorbisSetup(residueType, residueEnd)
+
+	// Block layout: each window overlaps the previous one at its 3/4 point.
+	pattern := []int{vorbisTestShort, vorbisTestShort, vorbisTestLong, vorbisTestLong, vorbisTestShort, vorbisTestLong, vorbisTestShort}
+	var sizes, starts []int
+	start := -pattern[0] / 2
+	for i := 0; ; i++ {
+		n := pattern[i%len(pattern)]
+		if i > 0 {
+			prev := sizes[i-1]
+			start = starts[i-1] + prev*3/4 - n/4
+		}
+		sizes = append(sizes, n)
+		starts = append(starts, start)
+		if start+n/2 >= total {
+			break
+		}
+	}
+
+	dec := &vorbisDecoder{blocksize: [2]int{vorbisTestShort, vorbisTestLong}, windows: map[int][]float64{}}
+	floor := floor1InverseDB[vorbisTestFloorY]
+	packets := [][]byte{ident, comment, setup}
+	for j, n := range sizes {
+		long := n == vorbisTestLong
+		prevLong := j > 0 && sizes[j-1] == vorbisTestLong
+		nextLong := j+1 < len(sizes) && sizes[j+1] == vorbisTestLong
+		window := dec.window(n, long, prevLong, nextLong)
+
+		w := &vorbisBitWriter{}
+		w.write(0, 1)
+		if long {
+			w.write(1, 1)
+			w.write(boolBit(prevLong), 1)
+			w.write(boolBit(nextLong), 1)
+		} else {
+			w.write(0, 1)
+		}
+
+		quantized := make([][]int, numChannels)
+		for ch := 0; ch < numChannels; ch++ {
+			w.write(1, 1) // floor nonzero
+			w.write(vorbisTestFloorY, 8)
+			w.write(vorbisTestFloorY, 8)
+			w.writeCode(0, 8) // partition values: unchanged prediction
+			w.writeCode(0, 8)
+
+			coeffs := vorbisMDCT(channels[ch], starts[j], n, window)
+			q := make([]int, len(coeffs))
+			for k, c := range coeffs {
+				v := int(math.Round(c/floor*128)) + 128
+				if v < 0 {
+					v = 0
+				}
+				if v > 255 {
+					v = 255
+				}
+				q[k] = v
+			}
+			quantized[ch] = q
+		}
+
+		var vectors [][]int
+		if residueType == 2 {
+			inter := make([]int, n/2*numChannels)
+			for i := 0; i < n/2; i++ {
+				for ch := 0; ch < numChannels; ch++ {
+					inter[i*numChannels+ch] = quantized[ch][i]
+				}
+			}
+			vectors = [][]int{inter}
+		} else {
+			vectors = quantized
+		}
+		const partSize = 32
+		for p := 0; p < len(vectors[0])/partSize; p++ {
+			classes := make([]int, len(vectors))
+			for v, vec := range vectors {
+				for _, q := range vec[p*partSize : (p+1)*partSize] {
+					if q != 128 {
+						classes[v] = 1
+					}
+				}
+				w.writeCode(uint32(classes[v]), 1)
+			}
+			for v, vec := range vectors {
+				if classes[v] == 0 {
+					continue
+				}
+				for _, q := range vec[p*partSize : (p+1)*partSize] {
+					w.writeCode(uint32(q), 8)
+				}
+			}
+		}
+		packets = append(packets, w.out)
+	}
+
+	granules := make([]int64, len(packets))
+	for i := range granules {
+		granules[i] = -1
+	}
+	granules[len(granules)-1] = int64(total)
+	return makeOggPages(packets, granules, 4)
+}
+
+func makeVorbisSetup(residueType, residueEnd int) []byte {
+	w := &vorbisBitWriter{}
+	w.bytes(append([]byte{5}, []byte("vorbis")...))
+
+	w.write(3-1, 8) // codebooks
+	// 0: residue classbook, two 1-bit entries.
+	w.write(0x564342, 24)
+	w.write(1, 16)
+	w.write(2, 24)
+	w.write(0, 1)
+	w.write(0, 1)
+	w.write(0, 5)
+	w.write(0, 5)
+	w.write(0, 4)
+	// 1: 8-bit scalar residue book, ordered lengths, lookup type 1.
+	w.write(0x564342, 24)
+	w.write(1, 16)
+	w.write(256, 24)
+	w.write(1, 1)
+	w.write(8-1, 5)
+	w.write(256, 9)
+	w.write(1, 4)
+	w.write(uint64(vorbisPackFloat(-1)), 32)
+	w.write(uint64(vorbisPackFloat(1.0/128)), 32)
+	w.write(8-1, 4)
+	w.write(0, 1)
+	for i := 0; i < 256; i++ {
+		w.write(uint64(i), 8)
+	}
+	// 2: floor1 partition book, sparse encoding with every entry present.
+	w.write(0x564342, 24)
+	w.write(1, 16)
+	w.write(256, 24)
+	w.write(0, 1)
+	w.write(1, 1)
+	for i := 0; i < 256; i++ {
+		w.write(1, 1)
+		w.write(8-1, 5)
+	}
+	w.write(0, 4)
+
+	w.write(0, 6) // time domain transforms
+	w.write(0, 16)
+
+	w.write(0, 6) // floors
+	w.write(1, 16)
+	w.write(1, 5)   // partitions
+	w.write(0, 4)   // partition class list
+	w.write(2-1, 3) // class dimensions
+	w.write(0, 2)   // subclasses
+	w.write(2+1, 8) // subclass book
+	w.write(1-1, 2) // multiplier
+	w.write(7, 4)   // range bits
+	w.write(32, 7)  // X list
+	w.write(64, 7)
+
+	w.write(0, 6) // residues
+	w.write(uint64(residueType), 16)
+	w.write(0, 24)
+	w.write(uint64(residueEnd), 24)
+	w.write(32-1, 24)
+	w.write(2-1, 6)
+	w.write(0, 8)
+	w.write(0, 3) // class 0 cascade: no books
+	w.write(0, 1)
+	w.write(1, 3) // class 1 cascade: pass 0
+	w.write(0, 1)
+	w.write(1, 8)
+
+	w.write(0, 6) // mappings
+	w.write(0, 16)
+	w.write(0, 1)
+	w.write(0, 1)
+	w.write(0, 2)
+	w.write(0, 8)
+	w.write(0, 8)
+	w.write(0, 8)
+
+	w.write(2-1, 6) // modes
+	for _, flag := range []uint64{0, 1} {
+		w.write(flag, 1)
+		w.write(0, 16)
+		w.write(0, 16)
+		w.write(0, 8)
+	}
+	w.write(1, 1)
+	return w.out
+}
+
+// vorbisMDCT is the forward transform matching the decoder's unnormalized
+// inverse, so windowed overlap-add reconstructs the input.
+func vorbisMDCT(signal []float64, start, n int, window []float64) []float64 {
+	half := n / 2
+	out := make([]float64, half)
+	for k := 0; k < half; k++ {
+		sum := 0.0
+		for i := 0; i < n; i++ {
+			idx := start + i
+			if idx < 0 || idx >= len(signal) {
+				continue
+			}
+			sum += signal[idx] * window[i] * math.Cos(2*math.Pi/float64(n)*(float64(i)+0.5+float64(n)/4)*(float64(k)+0.5))
+		}
+		out[k] = sum * 2 / float64(half)
+	}
+	return out
+}
+
+func makeOggPages(packets [][]byte, granules []int64, maxSegments int) []byte {
+	var (
+		out     []byte
+		lacing  []byte
+		body    []byte
+		seq     uint32
+		granule int64 = -1
+	)
+	flush := func(flags byte) {
+		header := make([]byte, 27)
+		copy(header, "OggS")
+		header[5] = flags
+		binary.LittleEndian.PutUint64(header[6:14], uint64(granule))
+		binary.LittleEndian.PutUint32(header[14:18], 0x5eed)
+		binary.LittleEndian.PutUint32(header[18:22], seq)
+		header[26] = byte(len(lacing))
+		page := append(append(header, lacing...), body...)
+		binary.LittleEndian.PutUint32(page[22:26], oggCRC(0, page))
+		out = append(out, page...)
+		seq++
+		lacing, body, granule = nil, nil, -1
+	}
+	continued := false
+	for i, packet := range packets {
+		remaining := packet
+		for {
+			n := len(remaining)
+			if n > 255 {
+				n = 255
+			}
+			lacing = append(lacing, byte(n))
+			body = append(body, remaining[:n]...)
+			remaining = remaining[n:]
+			done := n < 255
+			if done {
+				granule = granules[i]
+			}
+			last := done && i == len(packets)-1
+			if len(lacing) == maxSegments || i == 0 && done || last {
+				var flags byte
+				if continued {
+					flags |= 0x01
+				}
+				if seq == 0 {
+					flags |= 0x02
+				}
+				if last {
+					flags |= 0x04
+				}
+				flush(flags)
+				continued = !done
+			}
+			if done {
+				break
+			}
+		}
+	}
+	return out
+}
+
+type vorbisBitWriter struct {
+	out []byte
+	n   uint
+}
+
+// write appends the low n bits of v, least significant bit first.
+func (w *vorbisBitWriter) write(v uint64, n uint) {
+	for i := uint(0); i < n; i++ {
+		if w.n%8 == 0 {
+			w.out = append(w.out, 0)
+		}
+		w.out[len(w.out)-1] |= byte(v>>i&1) << (w.n % 8)
+		w.n++
+	}
+}
+
+// writeCode appends a Huffman codeword, most significant bit first.
+func (w *vorbisBitWriter) writeCode(code uint32, length int) {
+	for i := length - 1; i >= 0; i-- {
+		w.write(uint64(code>>uint(i)&1), 1)
+	}
+}
+
+func (w *vorbisBitWriter) bytes(b []byte) {
+	for _, c := range b {
+		w.write(uint64(c), 8)
+	}
+}
+
+func vorbisPackFloat(v float64) uint32 {
+	var sign uint32
+	if v < 0 {
+		sign = 0x80000000
+		v = -v
+	}
+	frac, exp := math.Frexp(v)
+	mantissa := uint32(frac * (1 << 21))
+	return sign | uint32(exp-21+788)<<21 | mantissa
+}
+
+func boolBit(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -10,35 +10,50 @@ import (
 
 // DecodeWAVIf tries to decode WAV data, returning ok=false when not WAV.
 func DecodeWAVIf(r io.ReadSeeker) (Audio, bool, error) {
+	return decodeWAVIf(r, Options{})
+}
+
+func decodeWAVIf(r io.ReadSeeker, opts Options) (Audio, bool, error) {
 	header := make([]byte, 12)
 	if _, err := io.ReadFull(r, header); err != nil {
 		return Audio{}, false, err
 	}
-	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+	if !isWAVHeader(header) {
 		_, _ = r.Seek(0, io.SeekStart)
 		return Audio{}, false, nil
 	}
 	_, _ = r.Seek(0, io.SeekStart)
-	pcm, err := decodeWAV(r)
+	pcm, err := decodeWAV(r, opts)
 	if err != nil {
 		return Audio{}, true, err
 	}
 	return pcm, true, nil
 }
 
-func decodeWAV(r io.ReadSeeker) (Audio, error) {
+func decodeWAV(r io.ReadSeeker, opts Options) (Audio, error) {
 	var (
 		fmtFound  bool
 		dataFound bool
 		fmtChunk  wavFormat
 		data      []byte
+		ranged    *Audio
+		sizes     *wavSizes
+		bwf       *BWFInfo
 	)
+	// done attaches the bext chunk, if any, to a successful decode.
+	done := func(pcm Audio, err error) (Audio, error) {
+		if err != nil {
+			return Audio{}, err
+		}
+		pcm.Metadata.BWF = bwf
+		return pcm, nil
+	}
 
 	header := make([]byte, 12)
 	if _, err := io.ReadFull(r, header); err != nil {
 		return Audio{}, err
 	}
-	if string(header[0:4]) != "RIFF" || string(header[8:12]) != "WAVE" {
+	if !isWAVHeader(header) {
 		return Audio{}, ErrUnsupported
 	}
 
@@ -52,27 +67,75 @@ func decodeWAV(r io.ReadSeeker) (Audio, error) {
 			return Audio{}, err
 		}
 		chunkID := string(chunkHeader[0:4])
-		chunkSize := int(binary.LittleEndian.Uint32(chunkHeader[4:8]))
+		chunkSize := sizes.chunkSize(chunkID, binary.LittleEndian.Uint32(chunkHeader[4:8]))
 
 		switch chunkID {
-		case "fmt ":
-			fmtFound = true
-			buf := make([]byte, chunkSize)
-			if _, err := io.ReadFull(r, buf); err != nil {
+		case "fmt ", "ds64", "bext":
+			buf, err := readIFFChunk(r, chunkSize)
+			if err != nil {
 				return Audio{}, err
 			}
-			if err := parseWavFormat(buf, &fmtChunk); err != nil {
+			switch chunkID {
+			case "fmt ":
+				fmtFound = true
+				err = parseWavFormat(buf, &fmtChunk)
+			case "ds64":
+				sizes, err = parseDS64(buf, seekRemaining(r))
+			default:
+				bwf, err = parseBext(buf)
+			}
+			if err != nil {
 				return Audio{}, err
 			}
+			// readIFFChunk consumed the pad byte.
+			continue
 		case "data":
 			dataFound = true
-			data = make([]byte, chunkSize)
-			if _, err := io.ReadFull(r, data); err != nil {
+			if fmtFound {
+				size := chunkSize
+				if rem := seekRemaining(r); rem >= 0 && size > rem {
+					size = rem
+				}
+				if err := checkWavLength(fmtChunk, size, opts); err != nil {
+					return Audio{}, err
+				}
+			}
+			if chunkSize == wavStreamSize && fmtFound {
+				// Streaming writers leave the size unset; data runs to EOF.
+				data, err = io.ReadAll(r)
+				if err != nil {
+					return Audio{}, err
+				}
+				return done(decodeWavSliced(fmtChunk, data, opts))
+			}
+			if fmtFound && opts.hasRange() {
+				// Decode the range, then keep scanning: a bext chunk may
+				// follow the data chunk.
+				start, err := r.Seek(0, io.SeekCurrent)
+				if err != nil {
+					return Audio{}, err
+				}
+				if rem := seekRemaining(r); rem >= 0 && chunkSize > rem {
+					// A truncated data chunk ends at EOF.
+					chunkSize = rem
+				}
+				pcm, err := decodeWavRange(r, fmtChunk, chunkSize, opts)
+				if err != nil {
+					return Audio{}, err
+				}
+				ranged = &pcm
+				if _, err := r.Seek(start+chunkSize, io.SeekStart); err != nil {
+					return Audio{}, err
+				}
+				break
+			}
+			if data, err = readIFFChunk(r, chunkSize); err != nil {
 				return Audio{}, err
 			}
+			continue
 		default:
 			// Skip unknown chunk.
-			if _, err := r.Seek(int64(chunkSize), io.SeekCurrent); err != nil {
+			if _, err := r.Seek(chunkSize, io.SeekCurrent); err != nil {
 				return Audio{}, err
 			}
 		}
@@ -81,12 +144,64 @@ func decodeWAV(r io.ReadSeeker) (Audio, error) {
 		}
 	}
 
+	if ranged != nil {
+		return done(*ranged, nil)
+	}
 	if !fmtFound || !dataFound {
 		return Audio{}, errors.New("wav: missing fmt or data chunk")
 	}
+	// A data chunk that preceded fmt could not be checked when it was read.
+	if err := checkWavLength(fmtChunk, int64(len(data)), opts); err != nil {
+		return Audio{}, err
+	}
+	return done(decodeWavSliced(fmtChunk, data, opts))
+}
+
+// checkWavLength fails with ErrTooLong when size bytes of sample data exceed
+// opts.MaxDuration. An invalid format is left for the decode to report.
+func checkWavLength(fmtChunk wavFormat, size int64, opts Options) error {
+	layout, err := newWavLayout(fmtChunk)
+	if err != nil || size == wavStreamSize {
+		return nil
+	}
+	return opts.checkLength(size/int64(layout.frameSize), layout.sampleRate)
+}
+
+func decodeWavSliced(fmtChunk wavFormat, data []byte, opts Options) (Audio, error) {
+	pcm, err := decodeWavData(fmtChunk, data)
+	if err != nil {
+		return Audio{}, err
+	}
+	return opts.slice(pcm)
+}
+
+// decodeWavRange seeks within a data chunk of size bytes and decodes only
+// the frames in the range selected by opts.
+func decodeWavRange(r io.ReadSeeker, fmtChunk wavFormat, size int64, opts Options) (Audio, error) {
+	layout, err := newWavLayout(fmtChunk)
+	if err != nil {
+		return Audio{}, err
+	}
+	if layout.sampleRate <= 0 {
+		return Audio{}, errors.New("slice: invalid sample rate")
+	}
+	start, end, err := sliceRange(int(size/int64(layout.frameSize)), layout.sampleRate, opts.Start, opts.Duration)
+	if err != nil {
+		return Audio{}, err
+	}
+	if _, err := r.Seek(int64(start*layout.frameSize), io.SeekCurrent); err != nil {
+		return Audio{}, err
+	}
+	data := make([]byte, (end-start)*layout.frameSize)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return Audio{}, err
+	}
 	return decodeWavData(fmtChunk, data)
 }
 
+// wavStreamSize is the chunk size written by encoders that cannot seek back.
+const wavStreamSize = 0xFFFFFFFF
+
 type wavFormat struct {
 	AudioFormat   uint16
 	NumChannels   uint16
@@ -112,110 +227,164 @@ func parseWavFormat(buf []byte, fmtChunk *wavFormat) error {
 }
 
 func decodeWavData(fmtChunk wavFormat, data []byte) (Audio, error) {
+	layout, err := newWavLayout(fmtChunk)
+	if err != nil {
+		return Audio{}, err
+	}
+	frames := len(data) / layout.frameSize
+	samples := makeChannels(layout.channels, frames)
+	layout.decode(data, samples, 0, frames)
+
+	pcm := NewAudio(layout.sampleRate, samples)
+	pcm.Metadata.Codec = "wav"
+	return pcm, nil
+}
+
+// wavLayout describes how interleaved WAV sample data converts to floats.
+type wavLayout struct {
+	channels       int
+	sampleRate     int
+	bytesPerSample int
+	frameSize      int
+	sample         func([]byte) float64
+}
+
+func newWavLayout(fmtChunk wavFormat) (wavLayout, error) {
 	format := fmtChunk.AudioFormat
 	if fmtChunk.Extensible {
-		// PCM subformat GUID 00000001-0000-0010-8000-00aa00389b71
-		if isGUID(fmtChunk.SubFormat, 0x00000001) {
-			format = 1
-		} else if isGUID(fmtChunk.SubFormat, 0x00000003) {
-			format = 3
+		// Subformat GUIDs are xxxxxxxx-0000-0010-8000-00aa00389b71, where
+		// xxxxxxxx is the plain format tag.
+		for _, sub := range []uint16{1, 3, 6, 7} {
+			if isGUID(fmtChunk.SubFormat, uint32(sub)) {
+				format = sub
+			}
 		}
 	}
 
 	switch format {
-	case 1, 3:
-		// PCM or IEEE float.
+	case 1, 3, 6, 7:
+		// PCM, IEEE float, G.711 A-law or μ-law.
 	default:
-		return Audio{}, fmt.Errorf("wav: unsupported format %d", format)
+		return wavLayout{}, fmt.Errorf("wav: unsupported format %d", format)
 	}
 
 	channels := int(fmtChunk.NumChannels)
 	if channels < 1 {
-		return Audio{}, errors.New("wav: invalid channel count")
+		return wavLayout{}, errors.New("wav: invalid channel count")
 	}
 
-	sampleRate := int(fmtChunk.SampleRate)
 	bits := int(fmtChunk.BitsPerSample)
 	if bits == 0 {
-		return Audio{}, errors.New("wav: invalid bits per sample")
-	}
-
-	var samples []float64
-	if format == 3 {
-		samples = decodeWavFloat(data, bits, channels)
-	} else {
-		samples = decodeWavPCM(data, bits, channels)
+		return wavLayout{}, errors.New("wav: invalid bits per sample")
 	}
-	if samples == nil {
-		return Audio{}, fmt.Errorf("wav: unsupported bit depth %d", bits)
+	sample := wavSampleFunc(format, bits)
+	if sample == nil {
+		return wavLayout{}, fmt.Errorf("wav: unsupported bit depth %d", bits)
 	}
-
-	return Audio{SampleRate: sampleRate, Samples: samples}, nil
+	return wavLayout{
+		channels:       channels,
+		sampleRate:     int(fmtChunk.SampleRate),
+		bytesPerSample: bits / 8,
+		frameSize:      bits / 8 * channels,
+		sample:         sample,
+	}, nil
 }
 
-func decodeWavPCM(data []byte, bits, channels int) []float64 {
-	bytesPerSample := bits / 8
-	frameSize := bytesPerSample * channels
-	if frameSize == 0 {
-		return nil
-	}
-	frames := len(data) / frameSize
-	out := make([]float64, frames)
+// decode converts frames of interleaved data into out starting at offset.
+func (l wavLayout) decode(data []byte, out [][]float64, offset, frames int) {
 	idx := 0
 	for i := 0; i < frames; i++ {
-		var sum float64
-		for ch := 0; ch < channels; ch++ {
-			off := idx + ch*bytesPerSample
-			var v int32
-			switch bits {
-			case 8:
-				v = int32(int(data[off]) - 128)
-			case 16:
-				v = int32(int16(binary.LittleEndian.Uint16(data[off : off+2])))
-			case 24:
-				b := data[off : off+3]
-				v = int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16
-				if v&0x800000 != 0 {
-					v |= ^0xffffff
-				}
-			case 32:
-				v = int32(binary.LittleEndian.Uint32(data[off : off+4]))
-			default:
-				return nil
-			}
-			scale := float64(int64(1) << (bits - 1))
-			sum += float64(v) / scale
+		for ch := 0; ch < l.channels; ch++ {
+			out[ch][offset+i] = l.sample(data[idx : idx+l.bytesPerSample])
+			idx += l.bytesPerSample
 		}
-		out[i] = sum / float64(channels)
-		idx += frameSize
 	}
-	return out
 }
 
-func decodeWavFloat(data []byte, bits, channels int) []float64 {
-	bytesPerSample := bits / 8
-	frameSize := bytesPerSample * channels
-	if frameSize == 0 {
+// wavSampleFunc returns the converter for one sample, or nil when the bit
+// depth is unsupported for the format.
+func wavSampleFunc(format uint16, bits int) func([]byte) float64 {
+	switch {
+	case format == 6 && bits == 8:
+		return func(b []byte) float64 { return alawTable[b[0]] }
+	case format == 7 && bits == 8:
+		return func(b []byte) float64 { return mulawTable[b[0]] }
+	case format == 6 || format == 7:
 		return nil
 	}
-	frames := len(data) / frameSize
-	out := make([]float64, frames)
-	idx := 0
-	for i := 0; i < frames; i++ {
-		var sum float64
-		for ch := 0; ch < channels; ch++ {
-			off := idx + ch*bytesPerSample
-			switch bits {
-			case 32:
-				sum += float64(math.Float32frombits(binary.LittleEndian.Uint32(data[off : off+4])))
-			case 64:
-				sum += math.Float64frombits(binary.LittleEndian.Uint64(data[off : off+8]))
-			default:
-				return nil
+	if format == 3 {
+		switch bits {
+		case 32:
+			return func(b []byte) float64 {
+				return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
 			}
+		case 64:
+			return func(b []byte) float64 {
+				return math.Float64frombits(binary.LittleEndian.Uint64(b))
+			}
+		}
+		return nil
+	}
+	switch bits {
+	case 8:
+		return func(b []byte) float64 { return float64(int(b[0])-128) / 128 }
+	case 16:
+		return func(b []byte) float64 { return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15) }
+	case 24:
+		return func(b []byte) float64 {
+			v := int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16
+			if v&0x800000 != 0 {
+				v |= ^0xffffff
+			}
+			return float64(v) / (1 << 23)
 		}
-		out[i] = sum / float64(channels)
-		idx += frameSize
+	case 32:
+		return func(b []byte) float64 { return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31) }
+	}
+	return nil
+}
+
+// G.711 lookup tables, scaled from 16-bit linear PCM.
+var (
+	alawTable  = g711Table(alawToLinear)
+	mulawTable = g711Table(mulawToLinear)
+)
+
+func g711Table(decode func(byte) int) [256]float64 {
+	var table [256]float64
+	for i := range table {
+		table[i] = float64(decode(byte(i))) / (1 << 15)
+	}
+	return table
+}
+
+// alawToLinear expands an ITU-T G.711 A-law byte to 16-bit PCM.
+func alawToLinear(b byte) int {
+	b ^= 0x55
+	v := int(b&0x0F)<<4 + 8
+	if seg := int(b&0x70) >> 4; seg > 0 {
+		v = (v + 0x100) << (seg - 1)
+	}
+	if b&0x80 == 0 {
+		return -v
+	}
+	return v
+}
+
+// mulawToLinear expands an ITU-T G.711 μ-law byte to 16-bit PCM.
+func mulawToLinear(b byte) int {
+	b = ^b
+	v := (int(b&0x0F)<<3 + 0x84) << (int(b&0x70) >> 4)
+	if b&0x80 != 0 {
+		return 0x84 - v
+	}
+	return v - 0x84
+}
+
+func makeChannels(channels, frames int) [][]float64 {
+	out := make([][]float64, channels)
+	for ch := range out {
+		out[ch] = make([]float64, frames)
 	}
 	return out
 }
@@ -227,3 +396,122 @@ func isGUID(b [16]byte, sub uint32) bool {
 		b[8] == 0x80 && b[9] == 0x00 &&
 		b[10] == 0x00 && b[11] == 0xAA && b[12] == 0x00 && b[13] == 0x38 && b[14] == 0x9B && b[15] == 0x71
 }
+
+// wavSource streams the data chunk of a WAV file.
+type wavSource struct {
+	r      io.Reader
+	layout wavLayout
+	bwf    *BWFInfo
+	// remaining counts data bytes left, or -1 when the size is unknown.
+	remaining int64
+	buf       []byte
+}
+
+// NewWAVSource parses the WAV header from r and streams its sample data.
+// The fmt chunk must precede the data chunk.
+func NewWAVSource(r io.Reader) (Source, error) {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if !isWAVHeader(header) {
+		return nil, ErrUnsupported
+	}
+
+	var (
+		fmtFound bool
+		fmtChunk wavFormat
+		sizes    *wavSizes
+		bwf      *BWFInfo
+	)
+	for {
+		chunkHeader := make([]byte, 8)
+		if _, err := io.ReadFull(r, chunkHeader); err != nil {
+			if err == io.EOF {
+				return nil, errors.New("wav: missing fmt or data chunk")
+			}
+			return nil, err
+		}
+		chunkID := string(chunkHeader[0:4])
+		chunkSize := sizes.chunkSize(chunkID, binary.LittleEndian.Uint32(chunkHeader[4:8]))
+
+		switch chunkID {
+		case "fmt ", "ds64", "bext":
+			buf, err := readIFFChunk(r, chunkSize)
+			if err != nil {
+				return nil, err
+			}
+			switch chunkID {
+			case "fmt ":
+				fmtFound = true
+				err = parseWavFormat(buf, &fmtChunk)
+			case "ds64":
+				sizes, err = parseDS64(buf, -1)
+			default:
+				bwf, err = parseBext(buf)
+			}
+			if err != nil {
+				return nil, err
+			}
+		case "data":
+			if !fmtFound {
+				return nil, errors.New("wav: data chunk before fmt chunk")
+			}
+			layout, err := newWavLayout(fmtChunk)
+			if err != nil {
+				return nil, err
+			}
+			if chunkSize == wavStreamSize {
+				chunkSize = -1
+			}
+			return &wavSource{r: r, layout: layout, bwf: bwf, remaining: chunkSize}, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, chunkSize+chunkSize%2); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+func (s *wavSource) SampleRate() int { return s.layout.sampleRate }
+
+func (s *wavSource) Channels() int { return s.layout.channels }
+
+func (s *wavSource) length() int64 {
+	if s.remaining < 0 {
+		return 0
+	}
+	return s.remaining / int64(s.layout.frameSize)
+}
+
+func (s *wavSource) Read(buf [][]float64) (int, error) {
+	frames := len(buf[0])
+	if s.remaining >= 0 {
+		if left := int(s.remaining / int64(s.layout.frameSize)); frames > left {
+			frames = left
+		}
+	}
+	if frames == 0 {
+		return 0, io.EOF
+	}
+	size := frames * s.layout.frameSize
+	if cap(s.buf) < size {
+		s.buf = make([]byte, size)
+	}
+	n, err := io.ReadFull(s.r, s.buf[:size])
+	frames = n / s.layout.frameSize
+	s.layout.decode(s.buf[:n], buf, 0, frames)
+	if s.remaining >= 0 {
+		s.remaining -= int64(n)
+	}
+	if err == io.ErrUnexpectedEOF || (err == io.EOF && frames > 0) {
+		// A truncated final frame ends the stream.
+		s.remaining = 0
+		err = nil
+	}
+	return frames, err
+}
+
+func (s *wavSource) Close() error { return nil }
+
+func (s *wavSource) metadata() Metadata { return Metadata{Codec: "wav", BWF: s.bwf} }
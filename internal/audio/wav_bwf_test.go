@@ -0,0 +1,268 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// buildRIFF wraps chunks in a RIFF/RF64 WAVE header.
+func buildRIFF(id string, chunks ...[]byte) []byte {
+	body := []byte("WAVE")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	size := uint32(len(body))
+	if id != "RIFF" {
+		size = wavStreamSize
+	}
+	out := binary.LittleEndian.AppendUint32([]byte(id), size)
+	return append(out, body...)
+}
+
+func wavChunk(id string, size uint32, body []byte) []byte {
+	out := binary.LittleEndian.AppendUint32([]byte(id), size)
+	out = append(out, body...)
+	if len(body)%2 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+func fmtChunkBody(format, channels uint16, rate uint32, bits uint16) []byte {
+	b := binary.LittleEndian.AppendUint16(nil, format)
+	b = binary.LittleEndian.AppendUint16(b, channels)
+	b = binary.LittleEndian.AppendUint32(b, rate)
+	b = binary.LittleEndian.AppendUint32(b, rate*uint32(channels*bits/8))
+	b = binary.LittleEndian.AppendUint16(b, channels*bits/8)
+	return binary.LittleEndian.AppendUint16(b, bits)
+}
+
+func bextBody(date, clock string, timeRef uint64) []byte {
+	b := make([]byte, bextCodingHistory)
+	copy(b, "Take 3")
+	copy(b[bextOriginator:], "Recorder")
+	copy(b[bextDate:], date)
+	copy(b[bextTime:], clock)
+	binary.LittleEndian.PutUint64(b[bextTimeReference:], timeRef)
+	binary.LittleEndian.PutUint16(b[bextVersion:], 1)
+	return append(b, "A=PCM,F=48000,W=24\r\n"...)
+}
+
+func TestG711Tables(t *testing.T) {
+	cases := []struct {
+		name string
+		got  int
+		want int
+	}{
+		{"mulaw 0xFF", mulawToLinear(0xFF), 0},
+		{"mulaw 0x80", mulawToLinear(0x80), 32124},
+		{"mulaw 0x00", mulawToLinear(0x00), -32124},
+		{"alaw 0xD5", alawToLinear(0xD5), 8},
+		{"alaw 0x55", alawToLinear(0x55), -8},
+		{"alaw 0xAA", alawToLinear(0xAA), 32256},
+		{"alaw 0x2A", alawToLinear(0x2A), -32256},
+	}
+	for _, tc := range cases {
+		if tc.got != tc.want {
+			t.Fatalf("%s: got %d want %d", tc.name, tc.got, tc.want)
+		}
+	}
+}
+
+func TestDecodeWAVG711(t *testing.T) {
+	for _, tc := range []struct {
+		format uint16
+		data   []byte
+		want   []float64
+	}{
+		{7, []byte{0xFF, 0x80, 0x00}, []float64{0, 32124.0 / 32768, -32124.0 / 32768}},
+		{6, []byte{0xD5, 0xAA, 0x2A}, []float64{8.0 / 32768, 32256.0 / 32768, -32256.0 / 32768}},
+	} {
+		data := buildRIFF("RIFF", wavChunk("fmt ", 16, fmtChunkBody(tc.format, 1, 8000, 8)), wavChunk("data", 3, tc.data))
+		pcm, err := DecodeBytes(t.Context(), data, Options{})
+		if err != nil {
+			t.Fatalf("format %d: %v", tc.format, err)
+		}
+		for i, want := range tc.want {
+			if pcm.Samples[i] != want {
+				t.Fatalf("format %d sample %d: got %v want %v", tc.format, i, pcm.Samples[i], want)
+			}
+		}
+	}
+
+	data := buildRIFF("RIFF", wavChunk("fmt ", 16, fmtChunkBody(7, 1, 8000, 16)), wavChunk("data", 2, []byte{0, 0}))
+	if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
+		t.Fatalf("expected error for 16-bit mu-law")
+	}
+}
+
+func TestDecodeRF64(t *testing.T) {
+	samples := []byte{0x00, 0x40, 0x00, 0xC0, 0xFF, 0x7F}
+	ds64 := binary.LittleEndian.AppendUint64(nil, 0)
+	ds64 = binary.LittleEndian.AppendUint64(ds64, uint64(len(samples)))
+	ds64 = binary.LittleEndian.AppendUint64(ds64, 3)
+	// One table entry for an oversized "junk" chunk.
+	ds64 = binary.LittleEndian.AppendUint32(ds64, 1)
+	ds64 = append(ds64, "junk"...)
+	ds64 = binary.LittleEndian.AppendUint64(ds64, 4)
+
+	data := buildRIFF("RF64",
+		wavChunk("ds64", uint32(len(ds64)), ds64),
+		wavChunk("fmt ", 16, fmtChunkBody(1, 1, 8000, 16)),
+		wavChunk("junk", wavStreamSize, []byte{1, 2, 3, 4}),
+		wavChunk("data", wavStreamSize, samples),
+		// Trailing bytes past the ds64 data size are not audio.
+		[]byte("LIST\x00\x00\x00\x00"),
+	)
+	want := []float64{0.5, -0.5, 32767.0 / 32768}
+	check := func(name string, pcm Audio, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(pcm.Samples) != len(want) {
+			t.Fatalf("%s: got %d samples", name, len(pcm.Samples))
+		}
+		for i := range want {
+			if pcm.Samples[i] != want[i] {
+				t.Fatalf("%s sample %d: got %v", name, i, pcm.Samples[i])
+			}
+		}
+	}
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
+	check("DecodeBytes", pcm, err)
+	pcm, err = DecodeReader(t.Context(), bytes.NewReader(data), Options{})
+	check("DecodeReader", pcm, err)
+
+	info, err := Probe(t.Context(), writeTemp(t, "a.wav", data), Options{})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if info.Container != "rf64" || info.Duration != 3.0/8000 {
+		t.Fatalf("unexpected info: %+v", info)
+	}
+}
+
+func TestDecodeRF64InvalidDS64(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		riff      uint64
+		data      uint64
+		tableSize uint64
+	}{
+		{"negative riff", 1 << 63, 2, 4},
+		{"negative data", 0, 1 << 63, 4},
+		{"data past end", 0, 1 << 40, 4},
+		{"table past end", 0, 2, 1 << 40},
+		{"negative table", 0, 2, 1<<64 - 8},
+	} {
+		ds64 := binary.LittleEndian.AppendUint64(nil, tc.riff)
+		ds64 = binary.LittleEndian.AppendUint64(ds64, tc.data)
+		ds64 = binary.LittleEndian.AppendUint64(ds64, 1)
+		ds64 = binary.LittleEndian.AppendUint32(ds64, 1)
+		ds64 = append(ds64, "junk"...)
+		ds64 = binary.LittleEndian.AppendUint64(ds64, tc.tableSize)
+		data := buildRIFF("RF64",
+			wavChunk("ds64", uint32(len(ds64)), ds64),
+			wavChunk("fmt ", 16, fmtChunkBody(1, 1, 8000, 16)),
+			wavChunk("junk", wavStreamSize, []byte{1, 2, 3, 4}),
+			wavChunk("data", wavStreamSize, []byte{0, 0}),
+		)
+		if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
+			t.Fatalf("%s: expected DecodeBytes error", tc.name)
+		}
+		if _, err := Probe(t.Context(), writeTemp(t, "a.wav", data), Options{}); err == nil {
+			t.Fatalf("%s: expected Probe error", tc.name)
+		}
+		if tc.riff>>63 == 1 || tc.data>>63 == 1 || tc.tableSize>>63 == 1 {
+			if _, err := NewWAVSource(bytes.NewReader(data)); err == nil {
+				t.Fatalf("%s: expected NewWAVSource error", tc.name)
+			}
+		}
+	}
+}
+
+func TestDecodeWAVBext(t *testing.T) {
+	const rate = 48000
+	data := buildRIFF("RIFF",
+		wavChunk("bext", bextCodingHistory+20, bextBody("2026-03-04", "10:20:30", 3600*rate+rate/2)),
+		wavChunk("fmt ", 16, fmtChunkBody(1, 1, rate, 16)),
+		wavChunk("data", 4, []byte{0, 0, 0, 0}),
+	)
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	bwf := pcm.Metadata.BWF
+	if bwf == nil {
+		t.Fatalf("missing bext metadata")
+	}
+	if bwf.Description != "Take 3" || bwf.Originator != "Recorder" || bwf.Version != 1 || bwf.CodingHistory != "A=PCM,F=48000,W=24" {
+		t.Fatalf("unexpected bext: %+v", bwf)
+	}
+	if want := time.Date(2026, 3, 4, 10, 20, 30, 0, time.UTC); !bwf.OriginationTime.Equal(want) {
+		t.Fatalf("origination time %v", bwf.OriginationTime)
+	}
+	if want := time.Date(2026, 3, 4, 1, 0, 0, 5e8, time.UTC); !bwf.Origin(rate).Equal(want) {
+		t.Fatalf("origin %v", bwf.Origin(rate))
+	}
+
+	src, err := NewWAVSource(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewWAVSource: %v", err)
+	}
+	pcm, err = ReadAll(src)
+	if err != nil || pcm.Metadata.BWF == nil || pcm.Metadata.BWF.TimeReference != 3600*rate+rate/2 {
+		t.Fatalf("streamed bext: %v %+v", err, pcm.Metadata.BWF)
+	}
+
+	info, err := Probe(t.Context(), writeTemp(t, "a.wav", data), Options{})
+	if err != nil {
+		t.Fatalf("Probe: %v", err)
+	}
+	if info.Tags["originator"] != "Recorder" || info.Tags["comment"] != "Take 3" {
+		t.Fatalf("unexpected tags: %v", info.Tags)
+	}
+	if want := time.Date(2026, 3, 4, 1, 0, 0, 5e8, time.UTC); info.Origin == nil || !info.Origin.Equal(want) {
+		t.Fatalf("probed origin %v", info.Origin)
+	}
+}
+
+func TestDecodeWAVRangeTrailingBext(t *testing.T) {
+	const rate = 8000
+	samples := make([]byte, 2*rate)
+	data := buildRIFF("RIFF",
+		wavChunk("fmt ", 16, fmtChunkBody(1, 1, rate, 16)),
+		wavChunk("data", uint32(len(samples)), samples),
+		wavChunk("bext", bextCodingHistory+20, bextBody("2026-03-04", "10:20:30", 0)),
+	)
+	pcm, err := DecodeBytes(t.Context(), data, Options{Start: 0.25, Duration: 0.5})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	if len(pcm.Samples) != rate/2 {
+		t.Fatalf("got %d samples", len(pcm.Samples))
+	}
+	if pcm.Metadata.BWF == nil || pcm.Metadata.BWF.Originator != "Recorder" {
+		t.Fatalf("missing trailing bext: %+v", pcm.Metadata.BWF)
+	}
+}
+
+func TestBWFOriginFallback(t *testing.T) {
+	bwf, err := parseBext(bextBody("2026:03:04", "10-20-30", 0))
+	if err != nil {
+		t.Fatalf("parseBext: %v", err)
+	}
+	if want := time.Date(2026, 3, 4, 10, 20, 30, 0, time.UTC); !bwf.Origin(48000).Equal(want) {
+		t.Fatalf("origin %v", bwf.Origin(48000))
+	}
+	bwf, err = parseBext(bextBody("", "", 48000))
+	if err != nil || !bwf.Origin(48000).IsZero() {
+		t.Fatalf("expected zero origin without a date: %v %v", err, bwf)
+	}
+	if _, err := parseBext(make([]byte, 100)); err == nil {
+		t.Fatalf("expected error for short bext")
+	}
+}
@@ -37,7 +37,7 @@ func TestDecodeWAVExtensiblePCM(t *testing.T) {
 	writeU32(buf, uint32(len(payload)))
 	buf.Write(payload)
 
-	pcm, err := DecodeBytes(buf.Bytes(), Options{})
+	pcm, err := DecodeBytes(t.Context(), buf.Bytes(), Options{})
 	if err != nil {
 		t.Fatalf("DecodeBytes: %v", err)
 	}
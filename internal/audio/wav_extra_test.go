@@ -30,7 +30,7 @@ func TestDecodeWAVWithExtraChunk(t *testing.T) {
 	writeU32(buf, uint32(len(payload)))
 	buf.Write(payload)
 
-	pcm, err := DecodeBytes(buf.Bytes(), Options{})
+	pcm, err := DecodeBytes(t.Context(), buf.Bytes(), Options{})
 	if err != nil {
 		t.Fatalf("DecodeBytes: %v", err)
 	}
@@ -54,7 +54,7 @@ func TestDecodeWAVMissingData(t *testing.T) {
 	writeU16(buf, 2)
 	writeU16(buf, 16)
 
-	if _, err := DecodeBytes(buf.Bytes(), Options{}); err == nil {
+	if _, err := DecodeBytes(t.Context(), buf.Bytes(), Options{}); err == nil {
 		t.Fatalf("expected error for missing data")
 	}
 }
@@ -64,7 +64,7 @@ func TestDecodeWAVHeaderOnly(t *testing.T) {
 	buf.WriteString("RIFF")
 	writeU32(buf, 4)
 	buf.WriteString("WAVE")
-	if _, err := decodeWAV(bytes.NewReader(buf.Bytes())); err == nil {
+	if _, err := decodeWAV(bytes.NewReader(buf.Bytes()), Options{}); err == nil {
 		t.Fatalf("expected error")
 	}
 }
@@ -88,7 +88,7 @@ func TestDecodeWAVInvalidChannels(t *testing.T) {
 	writeU32(buf, 2)
 	buf.Write([]byte{0, 0})
 
-	if _, err := DecodeBytes(buf.Bytes(), Options{}); err == nil {
+	if _, err := DecodeBytes(t.Context(), buf.Bytes(), Options{}); err == nil {
 		t.Fatalf("expected error for channels")
 	}
 }
@@ -112,7 +112,29 @@ func TestDecodeWAVFloatUnsupportedBits(t *testing.T) {
 	writeU32(buf, 3)
 	buf.Write([]byte{0, 0, 0})
 
-	if _, err := DecodeBytes(buf.Bytes(), Options{}); err == nil {
+	if _, err := DecodeBytes(t.Context(), buf.Bytes(), Options{}); err == nil {
 		t.Fatalf("expected error for float bit depth")
 	}
 }
+
+func TestDecodeWAVOversizedChunks(t *testing.T) {
+	// Sizes near 4 GB on a tiny file must fail without allocating them.
+	for _, data := range [][]byte{
+		buildRIFF("RIFF", wavChunk("fmt ", 0xFFFFFFF0, fmtChunkBody(1, 1, 8000, 16))),
+		buildRIFF("RIFF", wavChunk("fmt ", 16, fmtChunkBody(1, 1, 8000, 16)), wavChunk("data", 0xFFFFFFF0, []byte{0, 0})),
+	} {
+		if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
+			t.Fatalf("expected error for oversized chunk")
+		}
+	}
+
+	// With a range, a truncated data chunk ends at EOF.
+	data := buildRIFF("RIFF", wavChunk("fmt ", 16, fmtChunkBody(1, 1, 8000, 16)), wavChunk("data", 0xFFFFFFF0, make([]byte, 800)))
+	pcm, err := DecodeBytes(t.Context(), data, Options{Start: 0.01})
+	if err != nil {
+		t.Fatalf("DecodeBytes: %v", err)
+	}
+	if len(pcm.Samples) != 320 {
+		t.Fatalf("got %d samples, want 320", len(pcm.Samples))
+	}
+}
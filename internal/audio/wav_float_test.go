@@ -26,7 +26,7 @@ func TestDecodeWAVFloat32(t *testing.T) {
 	_ = binary.Write(buf, binary.LittleEndian, float32(0.5))
 	_ = binary.Write(buf, binary.LittleEndian, float32(-0.25))
 
-	pcm, err := DecodeBytes(buf.Bytes(), Options{})
+	pcm, err := DecodeBytes(t.Context(), buf.Bytes(), Options{})
 	if err != nil {
 		t.Fatalf("DecodeBytes: %v", err)
 	}
@@ -55,7 +55,7 @@ func TestDecodeWAVFloat64(t *testing.T) {
 	_ = binary.Write(buf, binary.LittleEndian, float64(0.5))
 	_ = binary.Write(buf, binary.LittleEndian, float64(-0.25))
 
-	pcm, err := DecodeBytes(buf.Bytes(), Options{})
+	pcm, err := DecodeBytes(t.Context(), buf.Bytes(), Options{})
 	if err != nil {
 		t.Fatalf("DecodeBytes: %v", err)
 	}
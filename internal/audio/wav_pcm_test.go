@@ -8,7 +8,7 @@ import (
 
 func TestDecodeWAVPCM8(t *testing.T) {
 	data := makeWAVPCM(8, []int32{0, 64, -64, 0}, 44100)
-	pcm, err := DecodeBytes(data, Options{})
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
 	if err != nil {
 		t.Fatalf("DecodeBytes: %v", err)
 	}
@@ -19,7 +19,7 @@ func TestDecodeWAVPCM8(t *testing.T) {
 
 func TestDecodeWAVPCM24(t *testing.T) {
 	data := makeWAVPCM(24, []int32{0, 100000, -100000, 0}, 44100)
-	pcm, err := DecodeBytes(data, Options{})
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
 	if err != nil {
 		t.Fatalf("DecodeBytes: %v", err)
 	}
@@ -30,7 +30,7 @@ func TestDecodeWAVPCM24(t *testing.T) {
 
 func TestDecodeWAVPCM32(t *testing.T) {
 	data := makeWAVPCM(32, []int32{0, 100000, -100000, 0}, 44100)
-	pcm, err := DecodeBytes(data, Options{})
+	pcm, err := DecodeBytes(t.Context(), data, Options{})
 	if err != nil {
 		t.Fatalf("DecodeBytes: %v", err)
 	}
@@ -41,14 +41,14 @@ func TestDecodeWAVPCM32(t *testing.T) {
 
 func TestDecodeWAVUnsupportedFormat(t *testing.T) {
 	data := makeWAVCustom(7, 16, []byte{0, 0}, 44100)
-	if _, err := DecodeBytes(data, Options{}); err == nil {
+	if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
 		t.Fatalf("expected error")
 	}
 }
 
 func TestDecodeWAVUnsupportedBits(t *testing.T) {
 	data := makeWAVCustom(1, 12, []byte{0, 0}, 44100)
-	if _, err := DecodeBytes(data, Options{}); err == nil {
+	if _, err := DecodeBytes(t.Context(), data, Options{}); err == nil {
 		t.Fatalf("expected error")
 	}
 }
@@ -0,0 +1,186 @@
+package dsp
+
+import "math"
+
+const (
+	// tuningResolution is the histogram step of EstimateTuning in
+	// semitones.
+	tuningResolution = 0.01
+	// tuningRangeDB keeps spectral peaks within this many dB of the
+	// frame's loudest bin.
+	tuningRangeDB = 40
+	// chromaCenterHz and chromaOctaveWidth shape the dominance weighting
+	// of ChromaWith: a Gaussian over octaves, as in librosa's chroma
+	// filters.
+	chromaCenterHz    = 880
+	chromaOctaveWidth = 2
+	// harmonicDecay weights the h-th harmonic by harmonicDecay^(h-1).
+	harmonicDecay = 0.6
+	// defaultCENSWindow is the CENS smoothing length in frames.
+	defaultCENSWindow = 41
+)
+
+// EstimateTuning estimates how far the reference pitch of the recording is
+// from A440, in semitones within [-0.5, 0.5). Spectral peaks within 40 dB
+// of each frame's maximum between 50 Hz and 5 kHz are located to a
+// fraction of a bin by parabolic interpolation, and the most common
+// deviation from the nearest equal-tempered note wins. It returns 0 when
+// there are no peaks.
+func EstimateTuning(spec *Spectrogram) float64 {
+	hist := make([]float64, int(math.Round(1/tuningResolution)))
+	bins := spec.Bins
+	for f := 0; f < spec.Frames; f++ {
+		row := spec.Values[f*bins : (f+1)*bins]
+		loudest := math.Inf(-1)
+		for _, v := range row {
+			loudest = math.Max(loudest, v)
+		}
+		for b := 1; b < bins-1; b++ {
+			a, c, e := row[b-1], row[b], row[b+1]
+			if c <= a || c < e || c < loudest-tuningRangeDB {
+				continue
+			}
+			offset := 0.0
+			if den := a - 2*c + e; den < 0 {
+				offset = 0.5 * (a - e) / den
+			}
+			hz := (float64(b) + offset) * spec.BinHz
+			if hz < 50 || hz > 5000 {
+				continue
+			}
+			midi := HzToMIDI(hz)
+			dev := midi - math.Round(midi)
+			idx := int(math.Floor((dev + 0.5) / tuningResolution))
+			hist[min(max(idx, 0), len(hist)-1)]++
+		}
+	}
+	best := 0
+	for i, v := range hist {
+		if v > hist[best] {
+			best = i
+		}
+	}
+	if hist[best] == 0 {
+		return 0
+	}
+	return (float64(best)+0.5)*tuningResolution - 0.5
+}
+
+// ChromaOptions configures ChromaWith.
+type ChromaOptions struct {
+	// Tuning shifts the pitch-class grid by this many semitones from A440,
+	// typically the result of EstimateTuning.
+	Tuning float64
+	// Harmonics, when above 1, also credits each bin to the pitch class of
+	// f/h for h up to Harmonics, weighted 0.6^(h-1), as in harmonic pitch
+	// class profiles, so the upper partials of a note reinforce its own
+	// class instead of the fifths and thirds they fall on.
+	Harmonics int
+}
+
+// ChromaWith computes a 12-bin chromagram (0 = C) from linear power. Unlike
+// ChromaFromPower, bins go to the nearest pitch class of the tuned grid and
+// are weighted by a Gaussian over octaves centred on 880 Hz, so rumble and
+// high partials count less than the melodic range.
+func ChromaWith(spec *Spectrogram, power []float64, opts ChromaOptions) FeatureMap {
+	frames, bins := spec.Frames, spec.Bins
+	harmonics := max(opts.Harmonics, 1)
+
+	// Per bin and harmonic: the pitch class credited and the bin's weight.
+	type target struct {
+		class  int
+		weight float64
+	}
+	var targets [][]target
+	for b := 0; b < bins; b++ {
+		hz := float64(b) * spec.BinHz
+		var ts []target
+		if hz >= 30 {
+			octaves := math.Log2(hz / chromaCenterHz)
+			weight := math.Exp(-0.5 * (octaves / chromaOctaveWidth) * (octaves / chromaOctaveWidth))
+			for h := 1; h <= harmonics; h++ {
+				class := int(math.Round(HzToMIDI(hz/float64(h))-opts.Tuning)) % 12
+				if class < 0 {
+					class += 12
+				}
+				ts = append(ts, target{class: class, weight: weight * math.Pow(harmonicDecay, float64(h-1))})
+			}
+		}
+		targets = append(targets, ts)
+	}
+
+	out := NewFeatureMap(frames, 12)
+	var energy [12]float64
+	for f := 0; f < frames; f++ {
+		energy = [12]float64{}
+		for b, ts := range targets {
+			p := power[f*bins+b]
+			for _, t := range ts {
+				energy[t.class] += p * t.weight
+			}
+		}
+		for c, e := range energy {
+			out.Set(f, c, powerToDB(e))
+		}
+	}
+	return out
+}
+
+// CENS turns a chromagram in dB into Chroma Energy Normalized Statistics
+// (Müller): each frame is normalized to sum 1, quantized to 0-4 by the
+// thresholds 0.05, 0.1, 0.2 and 0.4, smoothed over time with a Hann window
+// of window frames (0 = 41), and scaled to unit length. Values lie in
+// [0, 1]; being coarse and smooth, CENS suits structure analysis better
+// than raw chroma.
+func CENS(chroma FeatureMap, window int) FeatureMap {
+	if window <= 0 {
+		window = defaultCENSWindow
+	}
+	frames, classes := chroma.Width, chroma.Height
+	quantized := make([]float64, len(chroma.Values))
+	for f := 0; f < frames; f++ {
+		total := 0.0
+		for c := 0; c < classes; c++ {
+			total += dbToPower(chroma.At(f, c))
+		}
+		if total <= 0 {
+			continue
+		}
+		for c := 0; c < classes; c++ {
+			share := dbToPower(chroma.At(f, c)) / total
+			level := 0.0
+			for _, threshold := range []float64{0.05, 0.1, 0.2, 0.4} {
+				if share > threshold {
+					level++
+				}
+			}
+			quantized[c*frames+f] = level
+		}
+	}
+
+	smooth := HannWindow(window)
+	half := window / 2
+	out := NewFeatureMap(frames, classes)
+	smoothed := make([]float64, classes)
+	for f := 0; f < frames; f++ {
+		norm := 0.0
+		for c := 0; c < classes; c++ {
+			sum := 0.0
+			for i, w := range smooth {
+				if t := f + i - half; t >= 0 && t < frames {
+					sum += w * quantized[c*frames+t]
+				}
+			}
+			smoothed[c] = sum
+			norm += sum * sum
+		}
+		norm = math.Sqrt(norm)
+		for c, v := range smoothed {
+			if norm > 0 {
+				v /= norm
+			}
+			out.Set(f, c, v)
+		}
+	}
+	return out
+}
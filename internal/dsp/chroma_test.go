@@ -0,0 +1,93 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+// toneSpectrogram renders a sum of full-scale sines at the given MIDI notes.
+func toneSpectrogram(notes ...float64) Spectrogram {
+	const rate = 22050
+	samples := make([]float64, 2*rate)
+	for _, note := range notes {
+		freq := MIDIToHz(note)
+		for i := range samples {
+			samples[i] += math.Sin(2*math.Pi*freq*float64(i)/rate) / float64(len(notes))
+		}
+	}
+	return ComputeSpectrogramWith(samples, rate, SpectrogramOptions{WindowSize: 4096, HopSize: 1024})
+}
+
+func TestEstimateTuning(t *testing.T) {
+	for _, detune := range []float64{0.3, -0.2, 0} {
+		spec := toneSpectrogram(57+detune, 64+detune, 69+detune)
+		if got := EstimateTuning(&spec); math.Abs(got-detune) > 0.05 {
+			t.Fatalf("detune %v: estimated %v", detune, got)
+		}
+	}
+	silent := ComputeSpectrogramWith(make([]float64, 8192), 22050, SpectrogramOptions{WindowSize: 1024, HopSize: 512})
+	if got := EstimateTuning(&silent); got != 0 {
+		t.Fatalf("silence: estimated %v", got)
+	}
+}
+
+func TestChromaWithTuning(t *testing.T) {
+	spec := toneSpectrogram(69.45)
+	power := SpectrogramPower(&spec)
+	share := func(chroma FeatureMap, class int) float64 {
+		frame := chroma.Width / 2
+		total := 0.0
+		for c := 0; c < 12; c++ {
+			total += dbToPower(chroma.At(frame, c))
+		}
+		return dbToPower(chroma.At(frame, class)) / total
+	}
+	legacy := share(ChromaFromPower(&spec, power), 9)
+	tuned := share(ChromaWith(&spec, power, ChromaOptions{Tuning: EstimateTuning(&spec)}), 9)
+	if tuned < 0.95 || tuned <= legacy {
+		t.Fatalf("A share: tuned %.3f, legacy %.3f", tuned, legacy)
+	}
+}
+
+func TestChromaWithHarmonics(t *testing.T) {
+	// A3 with its second and third partials; the third is an E.
+	spec := toneSpectrogram(57, 69, 57+12*math.Log2(3))
+	power := SpectrogramPower(&spec)
+	frame := spec.Frames / 2
+	ratio := func(chroma FeatureMap) float64 {
+		return chroma.At(frame, 4) - chroma.At(frame, 9)
+	}
+	plain := ratio(ChromaWith(&spec, power, ChromaOptions{}))
+	hpcp := ratio(ChromaWith(&spec, power, ChromaOptions{Harmonics: 4}))
+	if hpcp >= plain {
+		t.Fatalf("E relative to A: %.2f dB with harmonics, %.2f dB without", hpcp, plain)
+	}
+}
+
+func TestCENS(t *testing.T) {
+	spec := toneSpectrogram(57, 64, 69)
+	cens := CENS(ChromaWith(&spec, SpectrogramPower(&spec), ChromaOptions{}), 0)
+	if cens.Width != spec.Frames || cens.Height != 12 {
+		t.Fatalf("unexpected size %dx%d", cens.Width, cens.Height)
+	}
+	for f := 0; f < cens.Width; f++ {
+		norm := 0.0
+		for c := 0; c < 12; c++ {
+			v := cens.At(f, c)
+			if v < 0 || v > 1 {
+				t.Fatalf("frame %d class %d: %v outside [0, 1]", f, c, v)
+			}
+			norm += v * v
+		}
+		if math.Abs(norm-1) > 1e-9 {
+			t.Fatalf("frame %d: squared norm %v", f, norm)
+		}
+	}
+	frame := cens.Width / 2
+	for c := 0; c < 12; c++ {
+		want := c == 9 || c == 4
+		if got := cens.At(frame, c) > 0.4; got != want {
+			t.Fatalf("class %d: value %.3f", c, cens.At(frame, c))
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package dsp
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"sync"
+)
+
+const (
+	defaultCQTBinsPerOctave = 12
+	defaultCQTOctaves       = 7
+	// cqtNyquistRatio caps bin centres relative to the sample rate.
+	cqtNyquistRatio = 0.45
+	// cqtPassband is the highest frequency, relative to the decimated rate,
+	// that an octave may reach (kernel bandwidth included) to be computed
+	// after decimation; the decimation filter is flat below it.
+	cqtPassband = 0.36
+	// cqtSparsity drops spectral kernel entries below this fraction of the
+	// kernel's peak.
+	cqtSparsity = 1e-3
+)
+
+// CQTOptions configures ComputeCQT. Zero values pick the defaults: 12 bins
+// per octave over seven octaves from C1 (32.7 Hz), with a 512-sample hop
+// and frames laid out like a 2048-sample spectrogram.
+type CQTOptions struct {
+	BinsPerOctave int
+	// MinFreq is the centre frequency of the lowest bin.
+	MinFreq float64
+	// MaxFreq bounds the highest bin centre. Bins stay below 0.45 times
+	// the sample rate either way.
+	MaxFreq float64
+	HopSize int
+	// WindowSize is the spectrogram window whose frames the CQT lines up
+	// with; 0 is four hops, as in SpectrogramOptions.
+	WindowSize int
+	// Threads bounds the workers computing frames; 0 uses GOMAXPROCS.
+	Threads int
+}
+
+// CQT is a constant-Q magnitude spectrogram in dB, calibrated like
+// Spectrogram. The map is Width frames by Height bins; bin k is centred on
+// MinFreq·2^(k/BinsPerOctave), so rows are evenly spaced in pitch and line
+// up with MIDI notes when MinFreq is a note frequency.
+type CQT struct {
+	FeatureMap
+	SampleRate    int
+	HopSize       int
+	BinsPerOctave int
+	MinFreq       float64
+}
+
+// Freq returns the centre frequency of bin k in Hz.
+func (c *CQT) Freq(k int) float64 {
+	return c.MinFreq * math.Exp2(float64(k)/float64(c.BinsPerOctave))
+}
+
+// MIDI returns the fractional MIDI note number of bin k.
+func (c *CQT) MIDI(k int) float64 {
+	return HzToMIDI(c.Freq(k))
+}
+
+// HzToMIDI converts a frequency to a fractional MIDI note (A4 = 440 Hz = 69).
+func HzToMIDI(hz float64) float64 {
+	return 69 + 12*math.Log2(hz/440)
+}
+
+// MIDIToHz converts a MIDI note number to a frequency.
+func MIDIToHz(note float64) float64 {
+	return 440 * math.Exp2((note-69)/12)
+}
+
+// ComputeCQT computes a constant-Q transform with Hann-windowed kernels
+// spanning Q = 1/(2^(1/BinsPerOctave) - 1) periods. Each octave is evaluated
+// at the lowest rate that still holds it, halving the signal with a
+// low-pass filter per level, so kernels stay a few hundred samples long;
+// frames then go through one FFT and sparse spectral kernels (Brown and
+// Puckette). Frames match ComputeSpectrogramWith with the same hop and
+// window: frame f is centred on sample f*HopSize + WindowSize/2, so CQT
+// panels and CQT chroma stack on STFT features frame for frame.
+func ComputeCQT(samples []float64, sampleRate int, opts CQTOptions) CQT {
+	bpo := opts.BinsPerOctave
+	if bpo <= 0 {
+		bpo = defaultCQTBinsPerOctave
+	}
+	hopSize := opts.HopSize
+	if hopSize <= 0 {
+		hopSize = 512
+	}
+	windowSize := opts.WindowSize
+	if windowSize <= 0 {
+		windowSize = 4 * hopSize
+	}
+	if sampleRate <= 0 {
+		sampleRate = 44100
+	}
+	limit := cqtNyquistRatio * float64(sampleRate)
+	minFreq := opts.MinFreq
+	if minFreq <= 0 {
+		minFreq = MIDIToHz(24)
+	}
+	minFreq = math.Min(minFreq, limit)
+	maxFreq := opts.MaxFreq
+	if maxFreq <= 0 {
+		maxFreq = minFreq * math.Exp2(defaultCQTOctaves-1/float64(bpo))
+	}
+	maxFreq = math.Min(maxFreq, limit)
+	// The epsilon keeps a MaxFreq on an exact bin centre from rounding away.
+	bins := 1 + int(math.Max(0, math.Floor(float64(bpo)*math.Log2(maxFreq/minFreq)+1e-9)))
+	frames := frameCount(len(samples), windowSize, hopSize)
+
+	out := CQT{
+		FeatureMap:    NewFeatureMap(frames, bins),
+		SampleRate:    sampleRate,
+		HopSize:       hopSize,
+		BinsPerOctave: bpo,
+		MinFreq:       minFreq,
+	}
+	q := 1 / (math.Exp2(1/float64(bpo)) - 1)
+	workers := frameWorkers(frames, opts.Threads)
+
+	// Octaves are processed from the top, so each level is decimated from
+	// the previous one as it is first needed.
+	levels := [][]float64{samples}
+	for hi := bins; hi > 0; hi -= bpo {
+		lo := max(0, hi-bpo)
+		// The Hann main lobe reaches 2f/Q past the centre frequency.
+		top := out.Freq(hi-1) * (1 + 2/q)
+		level := len(levels) - 1
+		for top <= cqtPassband*float64(sampleRate)/math.Exp2(float64(level+1)) {
+			level++
+		}
+		for len(levels) <= level {
+			levels = append(levels, decimate(levels[len(levels)-1], opts.Threads))
+		}
+
+		freqs := make([]float64, hi-lo)
+		for k := range freqs {
+			freqs[k] = out.Freq(lo + k)
+		}
+		rate := float64(sampleRate) / math.Exp2(float64(level))
+		kernel := newCQTKernel(freqs, rate, q)
+		signal := levels[level]
+		runFrames(frames, workers, func(int) func(f0, f1 int) {
+			frame := make([]float64, kernel.fftSize)
+			spectrum := make([]complex128, kernel.fftSize/2+1)
+			return func(f0, f1 int) {
+				for f := f0; f < f1; f++ {
+					centre := int(math.Round(float64(f*hopSize+windowSize/2) / math.Exp2(float64(level))))
+					start := centre - kernel.fftSize/2
+					for i := range frame {
+						if idx := start + i; idx >= 0 && idx < len(signal) {
+							frame[i] = signal[idx]
+						} else {
+							frame[i] = 0
+						}
+					}
+					kernel.plan.Transform(frame, spectrum)
+					for k, bin := range kernel.bins {
+						var sum complex128
+						for j, w := range bin.weights {
+							sum += spectrum[bin.start+j] * w
+						}
+						mag := cmplx.Abs(sum)
+						out.Values[(lo+k)*frames+f] = 20 * math.Log10(mag+1e-9)
+					}
+				}
+			}
+		})
+	}
+
+	for _, v := range out.Values {
+		out.Min = math.Min(out.Min, v)
+		out.Max = math.Max(out.Max, v)
+	}
+	return out
+}
+
+// ChromaFromCQT folds a CQT into 12 pitch classes (0 = C), summing the
+// power of the bins nearest each note of a grid tuning semitones from A440.
+func ChromaFromCQT(c *CQT, tuning float64) FeatureMap {
+	frames := c.Width
+	power := make([]float64, frames*12)
+	for k := 0; k < c.Height; k++ {
+		class := int(math.Round(c.MIDI(k)-tuning)) % 12
+		if class < 0 {
+			class += 12
+		}
+		for f := 0; f < frames; f++ {
+			power[class*frames+f] += dbToPower(c.At(f, k))
+		}
+	}
+	out := NewFeatureMap(frames, 12)
+	for class := 0; class < 12; class++ {
+		for f := 0; f < frames; f++ {
+			out.Set(f, class, powerToDB(power[class*frames+f]))
+		}
+	}
+	return out
+}
+
+// cqtKernel holds the spectral kernels of one octave at one sample rate.
+type cqtKernel struct {
+	fftSize int
+	plan    *RealFFTPlan
+	bins    []cqtBin
+}
+
+// cqtBin is the significant span of one bin's spectral kernel: the bin
+// value is the sum of spectrum[start+j] * weights[j].
+type cqtBin struct {
+	start   int
+	weights []complex128
+}
+
+// newCQTKernel builds the spectral kernels for freqs (ascending) at rate.
+// By Parseval, summing x[n]·conj(a[n]) over a frame equals summing
+// X[j]·conj(A[j])/N over its FFT, and A is concentrated around the bin's
+// frequency, so only a few FFT bins contribute.
+func newCQTKernel(freqs []float64, rate, q float64) cqtKernel {
+	longest := int(math.Ceil(q * rate / freqs[0]))
+	fftSize := 1 << bits.Len(uint(longest-1))
+	k := cqtKernel{fftSize: fftSize, plan: RealPlan(fftSize), bins: make([]cqtBin, len(freqs))}
+	plan := complexPlan(fftSize)
+	buf := make([]complex128, fftSize)
+	for b, freq := range freqs {
+		n := int(math.Ceil(q * rate / freq))
+		window := NewWindow(DefaultWindow, n)
+		// Scale a full-scale sinusoid at freq to magnitude 1, as in
+		// ComputeSpectrogramWith.
+		scale := 2 / (window.CoherentGain * float64(n))
+		clear(buf)
+		offset := (fftSize - n) / 2
+		for i, w := range window.Coeffs {
+			angle := 2 * math.Pi * freq * float64(offset+i-fftSize/2) / rate
+			buf[offset+i] = complex(w*math.Cos(angle), w*math.Sin(angle))
+		}
+		plan.Transform(buf)
+
+		spectrum := buf[:fftSize/2+1]
+		peak := 0.0
+		for _, c := range spectrum {
+			peak = math.Max(peak, cmplx.Abs(c))
+		}
+		first, last := 0, len(spectrum)-1
+		for first < last && cmplx.Abs(spectrum[first]) < cqtSparsity*peak {
+			first++
+		}
+		for last > first && cmplx.Abs(spectrum[last]) < cqtSparsity*peak {
+			last--
+		}
+		weights := make([]complex128, last-first+1)
+		for j := range weights {
+			weights[j] = conj(spectrum[first+j]) * complex(scale/float64(fftSize), 0)
+		}
+		k.bins[b] = cqtBin{start: first, weights: weights}
+	}
+	return k
+}
+
+// decimateTaps is the low-pass filter applied before dropping every other
+// sample: a Kaiser-windowed sinc cut off at 0.22 of the input rate, flat to
+// about 0.19 and some 70 dB down from 0.25, where aliases would start.
+var decimateTaps = sync.OnceValue(func() []float64 {
+	const (
+		taps   = 73
+		cutoff = 0.22
+	)
+	window := kaiserWindow(taps, 6.8)
+	h := make([]float64, taps)
+	sum := 0.0
+	for i := range h {
+		x := float64(i - taps/2)
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		h[i] = sinc * window[i]
+		sum += h[i]
+	}
+	for i := range h {
+		h[i] /= sum
+	}
+	return h
+})
+
+// decimate low-pass filters x and keeps every other sample. The filter is
+// centred, so the output is not delayed.
+func decimate(x []float64, threads int) []float64 {
+	const chunk = 4096
+	h := decimateTaps()
+	half := len(h) / 2
+	out := make([]float64, (len(x)+1)/2)
+	chunks := (len(out) + chunk - 1) / chunk
+	runFrames(chunks, frameWorkers(chunks, threads), func(int) func(c0, c1 int) {
+		return func(c0, c1 int) {
+			for m := c0 * chunk; m < min(c1*chunk, len(out)); m++ {
+				centre := 2 * m
+				sum := 0.0
+				for i := max(0, half-centre); i < len(h) && centre+i-half < len(x); i++ {
+					sum += h[i] * x[centre+i-half]
+				}
+				out[m] = sum
+			}
+		}
+	})
+	return out
+}
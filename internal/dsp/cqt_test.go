@@ -0,0 +1,107 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComputeCQTNotes(t *testing.T) {
+	const rate = 22050
+	// A2 lands in a decimated octave, A5 in a higher one.
+	for _, note := range []float64{45, 81} {
+		samples := make([]float64, rate)
+		freq := MIDIToHz(note)
+		for i := range samples {
+			samples[i] = math.Sin(2 * math.Pi * freq * float64(i) / rate)
+		}
+		cqt := ComputeCQT(samples, rate, CQTOptions{BinsPerOctave: 36, HopSize: 256})
+		// Frames follow a 1024-sample spectrogram, four hops by default.
+		if cqt.Height != 7*36 || cqt.Width != 1+(rate-1024+255)/256 {
+			t.Fatalf("unexpected size %dx%d", cqt.Width, cqt.Height)
+		}
+		frame := cqt.Width / 2
+		best := 0
+		for k := 0; k < cqt.Height; k++ {
+			if cqt.At(frame, k) > cqt.At(frame, best) {
+				best = k
+			}
+		}
+		if got := cqt.MIDI(best); math.Abs(got-note) > 1e-6 {
+			t.Fatalf("note %v: peak at MIDI %v", note, got)
+		}
+		if peak := cqt.At(frame, best); math.Abs(peak) > 0.5 {
+			t.Fatalf("note %v: full-scale sine peaks at %.2f dB, want 0", note, peak)
+		}
+		// A semitone away is well outside the kernel's main lobe.
+		if off := cqt.At(frame, best+6); off > -30 {
+			t.Fatalf("note %v: leakage a semitone up is %.2f dB", note, off)
+		}
+
+		chroma := ChromaFromCQT(&cqt, 0)
+		class := 0
+		for c := 0; c < 12; c++ {
+			if chroma.At(frame, c) > chroma.At(frame, class) {
+				class = c
+			}
+		}
+		if class != 9 {
+			t.Fatalf("note %v: chroma peak at class %d, want 9 (A)", note, class)
+		}
+	}
+}
+
+func TestComputeCQTFramesMatchSpectrogram(t *testing.T) {
+	const rate, hop, window = 44100, 512, 2048
+	// A click centred on spectrogram frame 17.
+	samples := make([]float64, 20*window)
+	samples[17*hop+window/2] = 1
+	spec := ComputeSpectrogramWith(samples, rate, SpectrogramOptions{WindowSize: window, HopSize: hop})
+	cqt := ComputeCQT(samples, rate, CQTOptions{HopSize: hop, WindowSize: window})
+	if cqt.Width != spec.Frames {
+		t.Fatalf("cqt has %d frames, spectrogram %d", cqt.Width, spec.Frames)
+	}
+	loudest := func(frames int, at func(f int) float64) int {
+		best := 0
+		for f := 1; f < frames; f++ {
+			if at(f) > at(best) {
+				best = f
+			}
+		}
+		return best
+	}
+	bin := int(2000 / spec.BinHz)
+	specFrame := loudest(spec.Frames, func(f int) float64 { return spec.Values[f*spec.Bins+bin] })
+	cqtFrame := loudest(cqt.Width, func(f int) float64 { return cqt.At(f, cqt.Height-1) })
+	if specFrame != 17 || cqtFrame != specFrame {
+		t.Fatalf("click at spectrogram frame %d, cqt frame %d, want 17", specFrame, cqtFrame)
+	}
+}
+
+func TestComputeCQTBounds(t *testing.T) {
+	cqt := ComputeCQT(make([]float64, 1000), 8000, CQTOptions{MinFreq: 110, MaxFreq: 880})
+	if cqt.Height != 37 || cqt.BinsPerOctave != 12 || cqt.MinFreq != 110 {
+		t.Fatalf("unexpected layout: %d bins, %d per octave, min %v", cqt.Height, cqt.BinsPerOctave, cqt.MinFreq)
+	}
+	// Default range from C1 is cut below 0.45 of the sample rate.
+	cqt = ComputeCQT(make([]float64, 1000), 8000, CQTOptions{})
+	if top := cqt.Freq(cqt.Height - 1); top > 3600 || cqt.Freq(cqt.Height) <= 3600 {
+		t.Fatalf("unexpected top bin %v Hz of %d", top, cqt.Height)
+	}
+	if math.Abs(cqt.MIDI(0)-24) > 1e-9 {
+		t.Fatalf("unexpected default min note %v", cqt.MIDI(0))
+	}
+}
+
+func TestComputeCQTThreads(t *testing.T) {
+	samples := testSignal(20000)
+	serial := ComputeCQT(samples, 44100, CQTOptions{HopSize: 64, Threads: 1})
+	parallel := ComputeCQT(samples, 44100, CQTOptions{HopSize: 64, Threads: 6})
+	for i, v := range parallel.Values {
+		if v != serial.Values[i] {
+			t.Fatalf("value %d differs: %v vs %v", i, v, serial.Values[i])
+		}
+	}
+	if serial.Min != parallel.Min || serial.Max != parallel.Max {
+		t.Fatalf("min/max differ")
+	}
+}
@@ -11,81 +11,45 @@ const (
 	defaultMFCC     = 13
 )
 
-// SpectrogramPower converts log-magnitude spectrogram values to linear power.
+// SpectrogramPower converts log-magnitude spectrogram values to linear power
+// per bin. It divides by the window's ENBW in FFT bins, which zero-padding
+// widens, so that summing bins over a band gives the band's power whichever
+// window and FFT size were used.
 func SpectrogramPower(spec *Spectrogram) []float64 {
+	enbw := spec.binENBW()
 	power := make([]float64, len(spec.Values))
 	for i, v := range spec.Values {
-		power[i] = dbToPower(v)
+		power[i] = dbToPower(v) / enbw
 	}
 	return power
 }
 
+// binENBW returns the window's equivalent noise bandwidth in FFT bins.
+func (s *Spectrogram) binENBW() float64 {
+	enbw := s.Window.ENBW
+	if enbw <= 0 {
+		enbw = 1
+	}
+	if s.WindowSize > 0 && s.FFTSize > s.WindowSize {
+		enbw *= float64(s.FFTSize) / float64(s.WindowSize)
+	}
+	return enbw
+}
+
 // MelSpectrogram computes a mel-scaled spectrogram from log-magnitude FFT data.
 func MelSpectrogram(spec *Spectrogram, bands int, minFreq, maxFreq float64) FeatureMap {
 	return MelSpectrogramFromPower(spec, SpectrogramPower(spec), bands, minFreq, maxFreq)
 }
 
-// MelSpectrogramFromPower computes a mel spectrogram from linear power.
+// MelSpectrogramFromPower computes a mel spectrogram from linear power with
+// the default filterbank shape.
 func MelSpectrogramFromPower(spec *Spectrogram, power []float64, bands int, minFreq, maxFreq float64) FeatureMap {
-	if bands <= 0 {
-		bands = defaultMelBands
-	}
-	if maxFreq <= 0 {
-		maxFreq = float64(spec.SampleRate) / 2
-	}
-	if minFreq < 0 {
-		minFreq = 0
-	}
-	if maxFreq <= minFreq {
-		maxFreq = minFreq + 1
-	}
-
-	bins := spec.Bins
-	frames := spec.Frames
-	points := melFilterBins(spec.BinHz, bins, bands, minFreq, maxFreq)
-	out := NewFeatureMap(frames, bands)
+	return MelSpectrogramWith(spec, power, MelOptions{Bands: bands, MinFreq: minFreq, MaxFreq: maxFreq})
+}
 
-	for f := 0; f < frames; f++ {
-		base := f * bins
-		for m := 0; m < bands; m++ {
-			start := points[m]
-			center := points[m+1]
-			end := points[m+2]
-			if end <= start {
-				continue
-			}
-			if center <= start {
-				center = (start + end) / 2
-			}
-			if center >= end {
-				center = (start + end) / 2
-			}
-			energy := 0.0
-			for b := start; b <= end; b++ {
-				var weight float64
-				if b < center {
-					den := float64(center - start)
-					if den > 0 {
-						weight = float64(b-start) / den
-					}
-				} else {
-					den := float64(end - center)
-					if den > 0 {
-						weight = float64(end-b) / den
-					}
-				}
-				if weight < 0 {
-					weight = 0
-				}
-				if weight > 1 {
-					weight = 1
-				}
-				energy += power[base+b] * weight
-			}
-			out.Set(f, m, powerToDB(energy))
-		}
-	}
-	return out
+// MelSpectrogramWith computes a mel spectrogram from linear power.
+func MelSpectrogramWith(spec *Spectrogram, power []float64, opts MelOptions) FeatureMap {
+	return NewMelFilterbank(spec.BinHz, spec.Bins, opts).Apply(power, spec.Frames, spec.Bins)
 }
 
 // Chroma computes a 12-bin chromagram from log-magnitude FFT data.
@@ -130,34 +94,13 @@ func MFCC(spec *Spectrogram, bands, coeffs int, minFreq, maxFreq float64) Featur
 	return MFCCFromPower(spec, SpectrogramPower(spec), bands, coeffs, minFreq, maxFreq)
 }
 
-// MFCCFromPower computes MFCC coefficients from linear power.
+// MFCCFromPower computes MFCC coefficients from linear power with the
+// classic pipeline.
 func MFCCFromPower(spec *Spectrogram, power []float64, bands, coeffs int, minFreq, maxFreq float64) FeatureMap {
-	if bands <= 0 {
-		bands = defaultMelBands
-	}
-	if coeffs <= 0 {
-		coeffs = defaultMFCC
-	}
-	if coeffs > bands {
-		coeffs = bands
-	}
-	mel := MelSpectrogramFromPower(spec, power, bands, minFreq, maxFreq)
-
-	out := NewFeatureMap(mel.Width, coeffs)
-	tmp := make([]float64, bands)
-	for f := 0; f < mel.Width; f++ {
-		for m := 0; m < bands; m++ {
-			tmp[m] = mel.At(f, m) / 10 * math.Ln10
-		}
-		for k := 0; k < coeffs; k++ {
-			sum := 0.0
-			for n := 0; n < bands; n++ {
-				sum += tmp[n] * math.Cos(math.Pi/float64(bands)*(float64(n)+0.5)*float64(k))
-			}
-			out.Set(f, k, sum)
-		}
-	}
-	return out
+	return MFCCWith(spec, power, MFCCOptions{
+		Coeffs: coeffs,
+		Mel:    MelOptions{Bands: bands, MinFreq: minFreq, MaxFreq: maxFreq},
+	})
 }
 
 // HPSS separates harmonic and percussive content using median filters.
@@ -354,10 +297,7 @@ func RMSFrames(samples []float64, windowSize, hopSize int) []float64 {
 	if hopSize <= 0 {
 		hopSize = windowSize / 4
 	}
-	frames := 1
-	if len(samples) > windowSize {
-		frames = 1 + (len(samples)-windowSize+hopSize-1)/hopSize
-	}
+	frames := frameCount(len(samples), windowSize, hopSize)
 	out := make([]float64, frames)
 	for f := 0; f < frames; f++ {
 		start := f * hopSize
@@ -379,30 +319,6 @@ func RMSFrames(samples []float64, windowSize, hopSize int) []float64 {
 	return out
 }
 
-func melFilterBins(binHz float64, bins, bands int, minFreq, maxFreq float64) []int {
-	minMel := hzToMel(minFreq)
-	maxMel := hzToMel(maxFreq)
-	points := make([]int, bands+2)
-	for i := 0; i < bands+2; i++ {
-		mel := minMel + (maxMel-minMel)*float64(i)/float64(bands+1)
-		hz := melToHz(mel)
-		bin := int(math.Round(hz / binHz))
-		if bin < 0 {
-			bin = 0
-		}
-		if bin > bins-1 {
-			bin = bins - 1
-		}
-		points[i] = bin
-	}
-	for i := 1; i < len(points); i++ {
-		if points[i] < points[i-1] {
-			points[i] = points[i-1]
-		}
-	}
-	return points
-}
-
 func hzToMel(hz float64) float64 {
 	return 2595 * math.Log10(1+hz/700)
 }
@@ -185,18 +185,6 @@ func TestMedian(t *testing.T) {
 	}
 }
 
-func TestMelFilterBins(t *testing.T) {
-	points := melFilterBins(100, 10, 5, 0, 400)
-	if len(points) != 7 {
-		t.Fatalf("points size mismatch")
-	}
-	for i := 1; i < len(points); i++ {
-		if points[i] < points[i-1] {
-			t.Fatalf("points not monotonic")
-		}
-	}
-}
-
 func TestMelSpectrogramBounds(t *testing.T) {
 	spec := testSpectrogram()
 	mel := MelSpectrogram(&spec, 0, 1000, 10)
@@ -1,40 +1,335 @@
 // Package dsp provides spectral analysis utilities.
 package dsp
 
-import "math"
+import (
+	"math"
+	"math/bits"
+	"sync"
+)
 
-// FFTInPlace computes the in-place FFT for length power-of-two slices.
+// FFTInPlace computes the in-place FFT of x, for any length.
 func FFTInPlace(x []complex128) {
-	n := len(x)
-	if n <= 1 {
+	if len(x) <= 1 {
 		return
 	}
+	complexPlan(len(x)).Transform(x)
+}
+
+// maxRadix is the largest prime factor handled by mixed-radix butterflies;
+// sizes with a larger prime factor use Bluestein's algorithm.
+const maxRadix = 31
+
+// FFTPlan holds the precomputed tables for complex FFTs of one size. Powers
+// of two use an iterative radix-2 transform, sizes whose prime factors are
+// all at most maxRadix use mixed-radix Cooley-Tukey, and any other size uses
+// Bluestein's chirp-z algorithm. A plan is read-only after construction and
+// safe for concurrent use.
+type FFTPlan struct {
+	n int
+	// twiddle[k] = e^{-2πik/n}, computed directly rather than by repeated
+	// multiplication. Radix-2 plans only need the first half.
+	twiddle []complex128
+	// rev is the bit-reversal table of a radix-2 plan.
+	rev []int
+	// factors lists the radices of a mixed-radix plan.
+	factors []int
+	// bluestein is set for sizes with a large prime factor.
+	bluestein *bluesteinPlan
+	// scratch holds *[]complex128 work buffers of a mixed-radix or
+	// Bluestein plan.
+	scratch sync.Pool
+}
+
+// NewFFTPlan prepares a complex FFT of size n.
+func NewFFTPlan(n int) *FFTPlan {
+	p := &FFTPlan{n: n}
+	if n <= 1 {
+		return p
+	}
+	if n&(n-1) == 0 {
+		p.twiddle = twiddles(n, n/2)
+		p.rev = make([]int, n)
+		shift := 64 - uint(bits.Len(uint(n))-1)
+		for i := range p.rev {
+			p.rev[i] = int(bits.Reverse64(uint64(i)) >> shift)
+		}
+		return p
+	}
 
-	// Bit-reversal permutation.
-	j := 0
-	for i := 1; i < n; i++ {
-		bit := n >> 1
-		for ; j&bit != 0; bit >>= 1 {
-			j &= ^bit
+	factors := factorize(n)
+	if factors[len(factors)-1] > maxRadix {
+		p.bluestein = newBluesteinPlan(n)
+		return p
+	}
+	p.factors = factors
+	p.twiddle = twiddles(n, n)
+	return p
+}
+
+// twiddles returns e^{-2πik/n} for k < count.
+func twiddles(n, count int) []complex128 {
+	out := make([]complex128, count)
+	for k := range out {
+		angle := -2 * math.Pi * float64(k) / float64(n)
+		out[k] = complex(math.Cos(angle), math.Sin(angle))
+	}
+	return out
+}
+
+// factorize returns the prime factors of n in ascending order.
+func factorize(n int) []int {
+	var out []int
+	for f := 2; f*f <= n; f++ {
+		for n%f == 0 {
+			out = append(out, f)
+			n /= f
 		}
-		j |= bit
+	}
+	if n > 1 {
+		out = append(out, n)
+	}
+	return out
+}
+
+// Len returns the transform size.
+func (p *FFTPlan) Len() int { return p.n }
+
+// Transform computes the forward FFT of x in place; len(x) must be Len().
+func (p *FFTPlan) Transform(x []complex128) {
+	switch {
+	case p.n <= 1:
+	case p.bluestein != nil:
+		buf := p.buffer(p.bluestein.m)
+		p.bluestein.transform(x, *buf)
+		p.scratch.Put(buf)
+	case p.factors != nil:
+		buf := p.buffer(p.n)
+		copy(*buf, x)
+		p.mixed(x, *buf, 1, p.factors)
+		p.scratch.Put(buf)
+	default:
+		p.radix2(x)
+	}
+}
+
+func (p *FFTPlan) buffer(size int) *[]complex128 {
+	if buf, ok := p.scratch.Get().(*[]complex128); ok {
+		return buf
+	}
+	buf := make([]complex128, size)
+	return &buf
+}
+
+func (p *FFTPlan) radix2(x []complex128) {
+	n := p.n
+	for i, j := range p.rev {
 		if i < j {
 			x[i], x[j] = x[j], x[i]
 		}
 	}
-
 	for size := 2; size <= n; size <<= 1 {
-		angle := -2 * math.Pi / float64(size)
-		wlen := complex(math.Cos(angle), math.Sin(angle))
-		for i := 0; i < n; i += size {
-			w := complex(1, 0)
-			for j := 0; j < size/2; j++ {
-				u := x[i+j]
-				v := w * x[i+j+size/2]
-				x[i+j] = u + v
-				x[i+j+size/2] = u - v
-				w *= wlen
+		half := size / 2
+		step := n / size
+		for start := 0; start < n; start += size {
+			for k := 0; k < half; k++ {
+				v := p.twiddle[k*step] * x[start+k+half]
+				u := x[start+k]
+				x[start+k] = u + v
+				x[start+k+half] = u - v
 			}
 		}
 	}
 }
+
+// mixed writes the DFT of in[0], in[stride], ... (len(out) values) to out by
+// decimation in time: one sub-transform per residue modulo the first radix,
+// then radix-sized butterflies across them.
+func (p *FFTPlan) mixed(out, in []complex128, stride int, factors []int) {
+	radix := factors[0]
+	m := len(out) / radix
+	if m == 1 {
+		for j := range radix {
+			out[j] = in[j*stride]
+		}
+	} else {
+		for j := range radix {
+			p.mixed(out[j*m:(j+1)*m], in[j*stride:], stride*radix, factors[1:])
+		}
+	}
+
+	// Sub-transform size is len(out) = n/stride, so its twiddles are every
+	// stride-th entry of the full table, and the radix kernel e^{-2πiqj/radix}
+	// is every (n/radix)-th.
+	var t [maxRadix]complex128
+	kernel := p.n / radix
+	for k := range m {
+		for j := range radix {
+			t[j] = out[j*m+k] * p.twiddle[j*k*stride]
+		}
+		for q := range radix {
+			sum := t[0]
+			for j := 1; j < radix; j++ {
+				sum += t[j] * p.twiddle[(q*j%radix)*kernel]
+			}
+			out[q*m+k] = sum
+		}
+	}
+}
+
+// bluesteinPlan evaluates an n-point DFT as a convolution with a chirp,
+// computed by power-of-two FFTs of size m >= 2n-1.
+type bluesteinPlan struct {
+	n, m int
+	// chirp[k] = e^{-πik²/n}.
+	chirp []complex128
+	// kernel is the FFT of the conjugate chirp, wrapped to length m and
+	// scaled by 1/m for the inverse transform.
+	kernel []complex128
+	fft    *FFTPlan
+}
+
+func newBluesteinPlan(n int) *bluesteinPlan {
+	m := 1 << bits.Len(uint(2*n-2))
+	b := &bluesteinPlan{n: n, m: m, chirp: make([]complex128, n), kernel: make([]complex128, m), fft: complexPlan(m)}
+	for k := range b.chirp {
+		// k² mod 2n keeps the angle small and exact for large k.
+		angle := -math.Pi * float64(k*k%(2*n)) / float64(n)
+		b.chirp[k] = complex(math.Cos(angle), math.Sin(angle))
+	}
+	b.kernel[0] = conj(b.chirp[0])
+	for k := 1; k < n; k++ {
+		b.kernel[k] = conj(b.chirp[k])
+		b.kernel[m-k] = conj(b.chirp[k])
+	}
+	b.fft.Transform(b.kernel)
+	for k := range b.kernel {
+		b.kernel[k] /= complex(float64(m), 0)
+	}
+	return b
+}
+
+func (b *bluesteinPlan) transform(x, a []complex128) {
+	for k := range a {
+		a[k] = 0
+	}
+	for k, v := range x {
+		a[k] = v * b.chirp[k]
+	}
+	b.fft.Transform(a)
+	// Multiply by the kernel and invert through conjugation:
+	// ifft(y) = conj(fft(conj(y))) / m, with 1/m folded into the kernel.
+	for k := range a {
+		a[k] = conj(a[k] * b.kernel[k])
+	}
+	b.fft.Transform(a)
+	for k := range x {
+		x[k] = conj(a[k]) * b.chirp[k]
+	}
+}
+
+// RealFFTPlan computes the spectrum of real input. Even sizes go through a
+// complex FFT of half the size, packing even samples into the real part and
+// odd samples into the imaginary part; odd sizes use a full complex FFT. It
+// is safe for concurrent use.
+type RealFFTPlan struct {
+	n    int
+	half *FFTPlan
+	// full is the complex plan for odd sizes.
+	full *FFTPlan
+	// twiddle[k] = e^{-2πik/n} for the split into even and odd spectra.
+	twiddle []complex128
+	scratch sync.Pool
+}
+
+// NewRealFFTPlan prepares a real FFT of size n.
+func NewRealFFTPlan(n int) *RealFFTPlan {
+	p := &RealFFTPlan{n: n}
+	switch {
+	case n < 2:
+	case n%2 == 1:
+		p.full = complexPlan(n)
+	default:
+		p.half = complexPlan(n / 2)
+		p.twiddle = twiddles(n, n/4+1)
+	}
+	return p
+}
+
+// Len returns the transform size.
+func (p *RealFFTPlan) Len() int { return p.n }
+
+// Transform writes bins 0..n/2 of the FFT of in (length n) to out (length
+// n/2+1). For even sizes out doubles as the working buffer.
+func (p *RealFFTPlan) Transform(in []float64, out []complex128) {
+	n := p.n
+	switch {
+	case n == 0:
+		return
+	case n == 1:
+		out[0] = complex(in[0], 0)
+		return
+	case p.full != nil:
+		buf, ok := p.scratch.Get().(*[]complex128)
+		if !ok {
+			b := make([]complex128, n)
+			buf = &b
+		}
+		x := *buf
+		for i, v := range in {
+			x[i] = complex(v, 0)
+		}
+		p.full.Transform(x)
+		copy(out, x[:len(out)])
+		p.scratch.Put(buf)
+		return
+	}
+
+	m := n / 2
+	for k := 0; k < m; k++ {
+		out[k] = complex(in[2*k], in[2*k+1])
+	}
+	p.half.Transform(out[:m])
+
+	// With Z the half-size spectrum, the even and odd sample spectra are
+	// E[k] = (Z[k] + conj(Z[m-k]))/2 and O[k] = -i(Z[k] - conj(Z[m-k]))/2,
+	// and X[k] = E[k] + W^k O[k]. Bins k and m-k are computed together
+	// from the same pair, since X[m-k] = conj(E[k] - W^k O[k]).
+	z0 := out[0]
+	out[0] = complex(real(z0)+imag(z0), 0)
+	out[m] = complex(real(z0)-imag(z0), 0)
+	for k := 1; k <= m/2; k++ {
+		zk, zmk := out[k], out[m-k]
+		even := (zk + conj(zmk)) / 2
+		odd := complex(0, -1) * (zk - conj(zmk)) / 2
+		w := p.twiddle[k] * odd
+		out[k] = even + w
+		out[m-k] = conj(even - w)
+	}
+}
+
+func conj(z complex128) complex128 {
+	return complex(real(z), -imag(z))
+}
+
+// Plans are immutable, so one per size is shared process-wide.
+var (
+	complexPlans sync.Map // int -> *FFTPlan
+	realPlans    sync.Map // int -> *RealFFTPlan
+)
+
+func complexPlan(n int) *FFTPlan {
+	if p, ok := complexPlans.Load(n); ok {
+		return p.(*FFTPlan)
+	}
+	p, _ := complexPlans.LoadOrStore(n, NewFFTPlan(n))
+	return p.(*FFTPlan)
+}
+
+// RealPlan returns the shared real FFT plan for size n.
+func RealPlan(n int) *RealFFTPlan {
+	if p, ok := realPlans.Load(n); ok {
+		return p.(*RealFFTPlan)
+	}
+	p, _ := realPlans.LoadOrStore(n, NewRealFFTPlan(n))
+	return p.(*RealFFTPlan)
+}
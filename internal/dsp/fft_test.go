@@ -1,6 +1,10 @@
 package dsp
 
-import "testing"
+import (
+	"math"
+	"math/cmplx"
+	"testing"
+)
 
 func TestFFTImpulse(t *testing.T) {
 	x := []complex128{1, 0, 0, 0}
@@ -11,3 +15,94 @@ func TestFFTImpulse(t *testing.T) {
 		}
 	}
 }
+
+func naiveDFT(x []complex128) []complex128 {
+	n := len(x)
+	out := make([]complex128, n)
+	for k := range out {
+		var sum complex128
+		for j, v := range x {
+			angle := -2 * math.Pi * float64(k*j%n) / float64(n)
+			sum += v * complex(math.Cos(angle), math.Sin(angle))
+		}
+		out[k] = sum
+	}
+	return out
+}
+
+func testSignal(n int) []float64 {
+	x := make([]float64, n)
+	for i := range x {
+		x[i] = math.Sin(0.37*float64(i)) + 0.5*math.Cos(1.9*float64(i)*float64(i%7)) - 0.1
+	}
+	return x
+}
+
+func TestFFTPlanMatchesDFT(t *testing.T) {
+	// Radix-2, mixed-radix (1764 = 2²·3²·7², 4410 = 2·3²·5·7²) and
+	// Bluestein (37 and 2·53 have prime factors above maxRadix).
+	for _, n := range []int{1, 2, 3, 4, 8, 12, 37, 64, 106, 512, 1764, 4410} {
+		signal := testSignal(n)
+		x := make([]complex128, n)
+		for i, v := range signal {
+			x[i] = complex(v, signal[n-1-i])
+		}
+		want := naiveDFT(x)
+		NewFFTPlan(n).Transform(x)
+		for k := range x {
+			if cmplx.Abs(x[k]-want[k]) > 1e-9*float64(n) {
+				t.Fatalf("n=%d bin %d: got %v want %v", n, k, x[k], want[k])
+			}
+		}
+	}
+}
+
+func TestRealFFTMatchesDFT(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 4, 8, 15, 16, 74, 256, 1024, 1764} {
+		in := testSignal(n)
+		x := make([]complex128, n)
+		for i, v := range in {
+			x[i] = complex(v, 0)
+		}
+		want := naiveDFT(x)
+		out := make([]complex128, n/2+1)
+		RealPlan(n).Transform(in, out)
+		for k := range out {
+			if cmplx.Abs(out[k]-want[k]) > 1e-9*float64(n) {
+				t.Fatalf("n=%d bin %d: got %v want %v", n, k, out[k], want[k])
+			}
+		}
+	}
+}
+
+func TestRealFFTPrecision(t *testing.T) {
+	// A pure tone on a long transform leaves the other bins near zero.
+	const n = 1 << 16
+	in := make([]float64, n)
+	for i := range in {
+		in[i] = math.Cos(2 * math.Pi * 1234 * float64(i) / n)
+	}
+	out := make([]complex128, n/2+1)
+	RealPlan(n).Transform(in, out)
+	if math.Abs(real(out[1234])-n/2) > 1e-6 {
+		t.Fatalf("peak = %v", out[1234])
+	}
+	for k, v := range out {
+		if k != 1234 && cmplx.Abs(v) > 1e-8 {
+			t.Fatalf("leakage at bin %d: %v", k, cmplx.Abs(v))
+		}
+	}
+}
+
+func TestRealPlanCached(t *testing.T) {
+	if RealPlan(2048) != RealPlan(2048) || RealPlan(2048).Len() != 2048 {
+		t.Fatalf("plans should be cached per size")
+	}
+}
+
+func BenchmarkSpectrogram(b *testing.B) {
+	samples := testSignal(44100 * 10)
+	for b.Loop() {
+		ComputeSpectrogram(samples, 44100, 2048, 512)
+	}
+}
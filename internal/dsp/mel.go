@@ -0,0 +1,173 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// MelScale selects the Hz-to-mel formula.
+type MelScale string
+
+// Mel scales.
+const (
+	// MelHTK is 2595·log10(1 + f/700), as used by HTK.
+	MelHTK MelScale = "htk"
+	// MelSlaney is linear below 1 kHz and logarithmic above, as in
+	// Slaney's Auditory Toolbox and librosa's default.
+	MelSlaney MelScale = "slaney"
+)
+
+// ParseMelScale validates a mel scale name.
+func ParseMelScale(name string) (MelScale, error) {
+	scale := MelScale(strings.ToLower(strings.TrimSpace(name)))
+	switch scale {
+	case "":
+		return MelSlaney, nil
+	case MelHTK, MelSlaney:
+		return scale, nil
+	default:
+		return "", fmt.Errorf("unknown mel scale %q (use htk, slaney)", name)
+	}
+}
+
+// MelNorm selects how mel filters are normalized.
+type MelNorm string
+
+// Mel filter normalizations.
+const (
+	// MelNormSlaney scales each triangle to unit area in Hz, so bands
+	// measure power density and wide high bands do not dominate.
+	MelNormSlaney MelNorm = "slaney"
+	// MelNormNone leaves every triangle with a peak of 1.
+	MelNormNone MelNorm = "none"
+)
+
+// ParseMelNorm validates a mel normalization name.
+func ParseMelNorm(name string) (MelNorm, error) {
+	norm := MelNorm(strings.ToLower(strings.TrimSpace(name)))
+	switch norm {
+	case "":
+		return MelNormSlaney, nil
+	case MelNormSlaney, MelNormNone:
+		return norm, nil
+	default:
+		return "", fmt.Errorf("unknown mel normalization %q (use slaney, none)", name)
+	}
+}
+
+// MelOptions configures a mel filterbank. Zero values pick 40 bands from
+// 0 Hz to Nyquist on the Slaney scale with Slaney (unit-area)
+// normalization, the pairing librosa uses by default.
+type MelOptions struct {
+	Bands   int
+	MinFreq float64
+	// MaxFreq is the upper edge of the top band; 0 is Nyquist.
+	MaxFreq float64
+	Scale   MelScale
+	Norm    MelNorm
+}
+
+// MelFilterbank holds triangular mel filters over FFT bins as a sparse
+// matrix: each band keeps only the weights of the bins it covers.
+type MelFilterbank struct {
+	// Centers are the band centre frequencies in Hz.
+	Centers []float64
+	bands   []melBand
+}
+
+type melBand struct {
+	start   int
+	weights []float64
+}
+
+// NewMelFilterbank builds the filters for bins FFT bins spaced binHz
+// apart. Band edges are evenly spaced on the mel scale and weights are
+// evaluated at each bin's exact frequency, as librosa does, so narrow low
+// bands keep the fraction of the bins inside them instead of collapsing
+// onto whole bins. A band narrower than the bin spacing that holds no bin
+// stays empty.
+func NewMelFilterbank(binHz float64, bins int, opts MelOptions) *MelFilterbank {
+	bands := opts.Bands
+	if bands <= 0 {
+		bands = defaultMelBands
+	}
+	maxFreq := opts.MaxFreq
+	if maxFreq <= 0 {
+		maxFreq = binHz * float64(bins-1)
+	}
+	minFreq := math.Max(opts.MinFreq, 0)
+	if maxFreq <= minFreq {
+		maxFreq = minFreq + 1
+	}
+	toMel, toHz := hzToSlaneyMel, slaneyMelToHz
+	if opts.Scale == MelHTK {
+		toMel, toHz = hzToMel, melToHz
+	}
+
+	minMel, maxMel := toMel(minFreq), toMel(maxFreq)
+	edges := make([]float64, bands+2)
+	for i := range edges {
+		edges[i] = toHz(minMel + (maxMel-minMel)*float64(i)/float64(bands+1))
+	}
+
+	fb := &MelFilterbank{Centers: edges[1 : bands+1], bands: make([]melBand, bands)}
+	for m := range fb.bands {
+		lower, centre, upper := edges[m], edges[m+1], edges[m+2]
+		scale := 1.0
+		if opts.Norm != MelNormNone {
+			scale = 2 / (upper - lower)
+		}
+		first := max(0, int(math.Ceil(lower/binHz)))
+		last := min(bins-1, int(math.Floor(upper/binHz)))
+		band := melBand{start: first}
+		for b := first; b <= last; b++ {
+			hz := float64(b) * binHz
+			w := math.Min((hz-lower)/(centre-lower), (upper-hz)/(upper-centre))
+			band.weights = append(band.weights, math.Max(0, w)*scale)
+		}
+		fb.bands[m] = band
+	}
+	return fb
+}
+
+// Apply computes the mel spectrogram in dB from linear power laid out as
+// in Spectrogram.Values.
+func (fb *MelFilterbank) Apply(power []float64, frames, bins int) FeatureMap {
+	out := NewFeatureMap(frames, len(fb.bands))
+	for f := 0; f < frames; f++ {
+		row := power[f*bins : (f+1)*bins]
+		for m, band := range fb.bands {
+			energy := 0.0
+			for i, w := range band.weights {
+				energy += row[band.start+i] * w
+			}
+			out.Set(f, m, powerToDB(energy))
+		}
+	}
+	return out
+}
+
+// Slaney's mel scale: 200/3 Hz per mel up to 1 kHz (15 mel), then 27 mel
+// per factor of 6.4.
+const (
+	slaneyHzPerMel = 200.0 / 3
+	slaneyBreakHz  = 1000.0
+	slaneyBreakMel = slaneyBreakHz / slaneyHzPerMel
+)
+
+var slaneyLogStep = math.Log(6.4) / 27
+
+func hzToSlaneyMel(hz float64) float64 {
+	if hz < slaneyBreakHz {
+		return hz / slaneyHzPerMel
+	}
+	return slaneyBreakMel + math.Log(hz/slaneyBreakHz)/slaneyLogStep
+}
+
+func slaneyMelToHz(mel float64) float64 {
+	if mel < slaneyBreakMel {
+		return mel * slaneyHzPerMel
+	}
+	return slaneyBreakHz * math.Exp((mel-slaneyBreakMel)*slaneyLogStep)
+}
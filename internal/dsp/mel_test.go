@@ -0,0 +1,91 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSlaneyMel(t *testing.T) {
+	for _, tc := range []struct{ hz, mel float64 }{{0, 0}, {500, 7.5}, {1000, 15}, {6400, 42}} {
+		if got := hzToSlaneyMel(tc.hz); math.Abs(got-tc.mel) > 1e-9 {
+			t.Fatalf("hzToSlaneyMel(%v) = %v, want %v", tc.hz, got, tc.mel)
+		}
+		if got := slaneyMelToHz(tc.mel); math.Abs(got-tc.hz) > 1e-6 {
+			t.Fatalf("slaneyMelToHz(%v) = %v, want %v", tc.mel, got, tc.hz)
+		}
+	}
+}
+
+func TestMelFilterbankWeights(t *testing.T) {
+	// 16 kHz with a 512-point FFT: 31.25 Hz bins, so the lowest of 64
+	// Slaney bands are narrower than a bin.
+	const binHz, bins = 31.25, 257
+	fb := NewMelFilterbank(binHz, bins, MelOptions{Bands: 64, Scale: MelSlaney})
+	if len(fb.Centers) != 64 || len(fb.bands) != 64 {
+		t.Fatalf("unexpected band count %d", len(fb.Centers))
+	}
+	for m, band := range fb.bands {
+		lower, upper := 0.0, fb.Centers[m]
+		if m > 0 {
+			lower = fb.Centers[m-1]
+		}
+		if m+1 < len(fb.Centers) {
+			upper = fb.Centers[m+1]
+		} else {
+			upper = 8000
+		}
+		// Unit area in Hz: the weights sum to 1/binHz once a band spans
+		// several bins.
+		sum := 0.0
+		for _, w := range band.weights {
+			if w < 0 {
+				t.Fatalf("band %d: negative weight", m)
+			}
+			sum += w
+		}
+		if upper-lower > 8*binHz && math.Abs(sum*binHz-1) > 0.02 {
+			t.Fatalf("band %d: area %v, want 1", m, sum*binHz)
+		}
+		if sum == 0 && upper-lower > 2*binHz {
+			t.Fatalf("band %d is empty", m)
+		}
+	}
+
+	// A bin halfway up a triangle gets half the peak weight.
+	peak := NewMelFilterbank(100, 11, MelOptions{Bands: 1, MinFreq: 100, MaxFreq: 500, Scale: MelSlaney, Norm: MelNormNone})
+	if got := peak.bands[0]; got.start != 1 || len(got.weights) != 5 || got.weights[2] != 1 || got.weights[1] != 0.5 {
+		t.Fatalf("unexpected triangle %+v", got)
+	}
+}
+
+func TestMelSpectrogramWithFlat(t *testing.T) {
+	// Flat power of 1 per bin: area-normalized bands read 1/binHz, so
+	// every band sits at the same level whatever its width.
+	spec := Spectrogram{Frames: 1, Bins: 1025, BinHz: 44100.0 / 2048}
+	power := make([]float64, spec.Bins)
+	for i := range power {
+		power[i] = 1
+	}
+	mel := MelSpectrogramWith(&spec, power, MelOptions{Bands: 40, MinFreq: 100, Scale: MelSlaney})
+	want := powerToDB(1 / spec.BinHz)
+	for m := 0; m < mel.Height; m++ {
+		if got := mel.At(0, m); math.Abs(got-want) > 0.5 {
+			t.Fatalf("band %d reads %.2f dB, want %.2f", m, got, want)
+		}
+	}
+}
+
+func TestParseMelOptions(t *testing.T) {
+	if scale, err := ParseMelScale(""); err != nil || scale != MelSlaney {
+		t.Fatalf("expected slaney default, got %q %v", scale, err)
+	}
+	if _, err := ParseMelScale("bark"); err == nil {
+		t.Fatalf("expected scale error")
+	}
+	if norm, err := ParseMelNorm("None"); err != nil || norm != MelNormNone {
+		t.Fatalf("expected none, got %q %v", norm, err)
+	}
+	if _, err := ParseMelNorm("l2"); err == nil {
+		t.Fatalf("expected norm error")
+	}
+}
@@ -0,0 +1,176 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// CMVN selects cepstral mean and variance normalization.
+type CMVN string
+
+// CMVN modes.
+const (
+	CMVNNone CMVN = "none"
+	// CMVNMean subtracts each coefficient's mean over all frames.
+	CMVNMean CMVN = "mean"
+	// CMVNMeanVar also scales each coefficient to unit variance.
+	CMVNMeanVar CMVN = "meanvar"
+)
+
+// ParseCMVN validates a CMVN mode name.
+func ParseCMVN(name string) (CMVN, error) {
+	mode := CMVN(strings.ToLower(strings.TrimSpace(name)))
+	switch mode {
+	case "":
+		return CMVNNone, nil
+	case CMVNNone, CMVNMean, CMVNMeanVar:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown CMVN mode %q (use none, mean, meanvar)", name)
+	}
+}
+
+// defaultDeltaWidth is the regression half-width N of HTK's delta formula.
+const defaultDeltaWidth = 2
+
+// MFCCOptions configures MFCCWith. Zero values give the classic pipeline:
+// 13 coefficients from the default mel filterbank with an unnormalized
+// DCT-II and no liftering, normalization or deltas.
+type MFCCOptions struct {
+	Coeffs int
+	Mel    MelOptions
+	// Ortho scales the DCT-II to be orthonormal, like librosa and scipy's
+	// norm="ortho".
+	Ortho bool
+	// Lifter is the sinusoidal liftering parameter L: coefficient k is
+	// scaled by 1 + (L/2)·sin(π(k+1)/L), as in librosa. 0 disables it.
+	Lifter int
+	CMVN   CMVN
+	// Deltas appends delta (1) or delta and delta-delta (2) features.
+	Deltas int
+	// DeltaWidth is the regression half-width in frames (0 = 2).
+	DeltaWidth int
+}
+
+// MFCCWith computes MFCCs from linear power. The natural log of each mel
+// band goes through a DCT-II, then liftering and CMVN are applied per
+// coefficient. With Deltas the map stacks Coeffs rows of statics, then
+// their deltas and, for 2, the delta-deltas, so its Height is Coeffs times
+// 1+Deltas.
+func MFCCWith(spec *Spectrogram, power []float64, opts MFCCOptions) FeatureMap {
+	mel := MelSpectrogramWith(spec, power, opts.Mel)
+	bands := mel.Height
+	coeffs := opts.Coeffs
+	if coeffs <= 0 {
+		coeffs = defaultMFCC
+	}
+	coeffs = min(coeffs, bands)
+	frames := mel.Width
+
+	// The DCT matrix includes the orthonormal and lifter scales.
+	dct := make([]float64, coeffs*bands)
+	for k := 0; k < coeffs; k++ {
+		scale := 1.0
+		if opts.Ortho {
+			scale = math.Sqrt(2 / float64(bands))
+			if k == 0 {
+				scale = math.Sqrt(1 / float64(bands))
+			}
+		}
+		if opts.Lifter > 0 {
+			l := float64(opts.Lifter)
+			scale *= 1 + l/2*math.Sin(math.Pi*float64(k+1)/l)
+		}
+		for n := 0; n < bands; n++ {
+			dct[k*bands+n] = scale * math.Cos(math.Pi/float64(bands)*(float64(n)+0.5)*float64(k))
+		}
+	}
+
+	// Static coefficients, one row per coefficient as in FeatureMap.
+	static := make([]float64, coeffs*frames)
+	logMel := make([]float64, bands)
+	for f := 0; f < frames; f++ {
+		for m := 0; m < bands; m++ {
+			logMel[m] = mel.At(f, m) / 10 * math.Ln10
+		}
+		for k := 0; k < coeffs; k++ {
+			sum := 0.0
+			for n, v := range logMel {
+				sum += dct[k*bands+n] * v
+			}
+			static[k*frames+f] = sum
+		}
+	}
+	if opts.CMVN == CMVNMean || opts.CMVN == CMVNMeanVar {
+		for k := 0; k < coeffs; k++ {
+			normalizeRow(static[k*frames:(k+1)*frames], opts.CMVN == CMVNMeanVar)
+		}
+	}
+
+	deltas := min(max(opts.Deltas, 0), 2)
+	width := opts.DeltaWidth
+	if width <= 0 {
+		width = defaultDeltaWidth
+	}
+	rows := [][]float64{static}
+	for d := 0; d < deltas; d++ {
+		prev := rows[len(rows)-1]
+		next := make([]float64, len(prev))
+		for k := 0; k < coeffs; k++ {
+			deltaRow(next[k*frames:(k+1)*frames], prev[k*frames:(k+1)*frames], width)
+		}
+		rows = append(rows, next)
+	}
+
+	out := NewFeatureMap(frames, coeffs*len(rows))
+	for i, block := range rows {
+		for k := 0; k < coeffs; k++ {
+			for f := 0; f < frames; f++ {
+				out.Set(f, i*coeffs+k, block[k*frames+f])
+			}
+		}
+	}
+	return out
+}
+
+// normalizeRow subtracts the mean of row and, with variance, divides by its
+// standard deviation when that is non-zero.
+func normalizeRow(row []float64, variance bool) {
+	if len(row) == 0 {
+		return
+	}
+	mean := 0.0
+	for _, v := range row {
+		mean += v
+	}
+	mean /= float64(len(row))
+	sumSq := 0.0
+	for i, v := range row {
+		row[i] = v - mean
+		sumSq += row[i] * row[i]
+	}
+	if std := math.Sqrt(sumSq / float64(len(row))); variance && std > 0 {
+		for i := range row {
+			row[i] /= std
+		}
+	}
+}
+
+// deltaRow writes the HTK regression delta of in to out:
+// Σ n·(c[t+n] - c[t-n]) / (2·Σ n²) for n = 1..width, repeating the edge
+// frames past either end.
+func deltaRow(out, in []float64, width int) {
+	denom := 0.0
+	for n := 1; n <= width; n++ {
+		denom += 2 * float64(n*n)
+	}
+	last := len(in) - 1
+	for t := range out {
+		sum := 0.0
+		for n := 1; n <= width; n++ {
+			sum += float64(n) * (in[min(t+n, last)] - in[max(t-n, 0)])
+		}
+		out[t] = sum / denom
+	}
+}
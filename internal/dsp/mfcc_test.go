@@ -0,0 +1,86 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMFCCWithOrthoLifter(t *testing.T) {
+	spec := testSpectrogram()
+	power := SpectrogramPower(&spec)
+	mel := MelSpectrogramWith(&spec, power, MelOptions{Bands: 20})
+
+	// With every coefficient kept, the orthonormal DCT preserves energy.
+	ortho := MFCCWith(&spec, power, MFCCOptions{Coeffs: 20, Mel: MelOptions{Bands: 20}, Ortho: true})
+	for f := 0; f < spec.Frames; f++ {
+		var in, out float64
+		for m := 0; m < 20; m++ {
+			v := mel.At(f, m) / 10 * math.Ln10
+			in += v * v
+			c := ortho.At(f, m)
+			out += c * c
+		}
+		if math.Abs(in-out) > 1e-9*in {
+			t.Fatalf("frame %d: energy %v became %v", f, in, out)
+		}
+	}
+
+	liftered := MFCCWith(&spec, power, MFCCOptions{Coeffs: 20, Mel: MelOptions{Bands: 20}, Ortho: true, Lifter: 22})
+	for k := 0; k < 20; k++ {
+		want := 1 + 11*math.Sin(math.Pi*float64(k+1)/22)
+		if got := liftered.At(0, k) / ortho.At(0, k); math.Abs(got-want) > 1e-9 {
+			t.Fatalf("coefficient %d lifted by %v, want %v", k, got, want)
+		}
+	}
+}
+
+func TestMFCCWithCMVNDeltas(t *testing.T) {
+	spec := testSpectrogram()
+	power := SpectrogramPower(&spec)
+	mfcc := MFCCWith(&spec, power, MFCCOptions{CMVN: CMVNMeanVar, Deltas: 2})
+	if mfcc.Width != spec.Frames || mfcc.Height != 3*13 {
+		t.Fatalf("unexpected size %dx%d", mfcc.Width, mfcc.Height)
+	}
+	for k := 0; k < 13; k++ {
+		var mean, sq float64
+		for f := 0; f < mfcc.Width; f++ {
+			mean += mfcc.At(f, k)
+		}
+		mean /= float64(mfcc.Width)
+		for f := 0; f < mfcc.Width; f++ {
+			d := mfcc.At(f, k) - mean
+			sq += d * d
+		}
+		// Constant coefficients keep zero variance.
+		if std := math.Sqrt(sq / float64(mfcc.Width)); math.Abs(mean) > 1e-9 || (std > 1e-9 && math.Abs(std-1) > 1e-9) {
+			t.Fatalf("coefficient %d: mean %v, std %v", k, mean, std)
+		}
+	}
+}
+
+func TestDeltaRow(t *testing.T) {
+	in := []float64{0, 1, 2, 3, 4, 5, 6}
+	out := make([]float64, len(in))
+	deltaRow(out, in, 2)
+	// Interior frames see the slope; edges are damped by the repetition.
+	for i := 2; i < len(in)-2; i++ {
+		if math.Abs(out[i]-1) > 1e-12 {
+			t.Fatalf("delta[%d] = %v, want 1", i, out[i])
+		}
+	}
+	if out[0] != 0.5 || out[len(out)-1] != 0.5 {
+		t.Fatalf("unexpected edge deltas %v", out)
+	}
+}
+
+func TestParseCMVN(t *testing.T) {
+	if mode, err := ParseCMVN(""); err != nil || mode != CMVNNone {
+		t.Fatalf("expected none default, got %q %v", mode, err)
+	}
+	if mode, err := ParseCMVN("MeanVar"); err != nil || mode != CMVNMeanVar {
+		t.Fatalf("expected meanvar, got %q %v", mode, err)
+	}
+	if _, err := ParseCMVN("global"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
@@ -0,0 +1,46 @@
+package dsp
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// frameBlock is the number of frames a worker computes at a time.
+const frameBlock = 32
+
+// frameWorkers returns how many workers to use for frames: threads, or
+// GOMAXPROCS when threads is 0, but no more than there are blocks.
+func frameWorkers(frames, threads int) int {
+	if threads <= 0 {
+		threads = runtime.GOMAXPROCS(0)
+	}
+	return max(1, min(threads, (frames+frameBlock-1)/frameBlock))
+}
+
+// runFrames computes frames on workers goroutines. Workers claim fixed
+// blocks of frames, so each frame is computed exactly once and results
+// written by frame index do not depend on scheduling. newWorker is called
+// once per worker with its index and returns the function computing frames
+// f0..f1-1, which may keep per-worker scratch and state.
+func runFrames(frames, workers int, newWorker func(w int) func(f0, f1 int)) {
+	blocks := (frames + frameBlock - 1) / frameBlock
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			compute := newWorker(w)
+			for {
+				block := int(next.Add(1) - 1)
+				if block >= blocks {
+					return
+				}
+				f0 := block * frameBlock
+				compute(f0, min(f0+frameBlock, frames))
+			}
+		}()
+	}
+	wg.Wait()
+}
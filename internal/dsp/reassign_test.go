@@ -0,0 +1,84 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComputeReassignedSine(t *testing.T) {
+	const rate = 48000
+	// 1032 Hz falls halfway between the 46.875 Hz bins of a 1024 window.
+	samples := make([]float64, rate/2)
+	for i := range samples {
+		samples[i] = math.Sin(2 * math.Pi * 1032 * float64(i) / rate)
+	}
+	opts := SpectrogramOptions{WindowSize: 1024, HopSize: 256}
+	plain := ComputeSpectrogramWith(samples, rate, opts)
+	reassigned := ComputeReassigned(samples, rate, opts)
+	if reassigned.Frames != plain.Frames || reassigned.Bins != plain.Bins || reassigned.BinHz != plain.BinHz {
+		t.Fatalf("layout differs from the spectrogram")
+	}
+
+	frame := reassigned.Frames / 2
+	row := reassigned.Values[frame*reassigned.Bins : (frame+1)*reassigned.Bins]
+	best, total := 0, 0.0
+	for b, v := range row {
+		if v > row[best] {
+			best = b
+		}
+		total += math.Pow(10, v/10)
+	}
+	if want := int(math.Round(1032 / reassigned.BinHz)); best != want {
+		t.Fatalf("peak at bin %d, want %d", best, want)
+	}
+	if math.Abs(row[best]) > 0.2 {
+		t.Fatalf("full-scale sine reads %.2f dB, want 0", row[best])
+	}
+	// The plain spectrogram spreads the tone over its main lobe; the
+	// reassigned one puts nearly all of it in one cell.
+	if share := math.Pow(10, row[best]/10) / total; share < 0.95 {
+		t.Fatalf("peak cell holds %.2f of the frame's energy", share)
+	}
+}
+
+func TestComputeReassignedClick(t *testing.T) {
+	samples := make([]float64, 8192)
+	samples[4000] = 1
+	spec := ComputeReassigned(samples, 44100, SpectrogramOptions{WindowSize: 1024, HopSize: 128})
+	// The click sits at sample 4000; frame f is centred on f*128 + 511.5.
+	want := int(math.Round((4000 - 511.5) / 128))
+	energy := make([]float64, spec.Frames)
+	for f := range energy {
+		for b := 0; b < spec.Bins; b++ {
+			energy[f] += math.Pow(10, spec.Values[f*spec.Bins+b]/10)
+		}
+	}
+	best, total := 0, 0.0
+	for f, e := range energy {
+		if e > energy[best] {
+			best = f
+		}
+		total += e
+	}
+	if best != want {
+		t.Fatalf("click reassigned to frame %d, want %d", best, want)
+	}
+	// Without reassignment the click spreads over the eight frames whose
+	// windows cover it.
+	if share := energy[best] / total; share < 0.9 {
+		t.Fatalf("peak frame holds %.2f of the click's energy", share)
+	}
+}
+
+func TestComputeReassignedThreads(t *testing.T) {
+	samples := testSignal(40000)
+	opts := SpectrogramOptions{WindowSize: 512, HopSize: 64, Threads: 1}
+	serial := ComputeReassigned(samples, 44100, opts)
+	opts.Threads = 5
+	parallel := ComputeReassigned(samples, 44100, opts)
+	for i, v := range parallel.Values {
+		if v != serial.Values[i] {
+			t.Fatalf("value %d differs: %v vs %v", i, v, serial.Values[i])
+		}
+	}
+}
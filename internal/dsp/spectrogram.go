@@ -5,7 +5,9 @@ import (
 	"math"
 )
 
-// Spectrogram contains log-magnitude FFT frames.
+// Spectrogram contains log-magnitude FFT frames in dB relative to full
+// scale: a full-scale sinusoid centred on a bin peaks at 0 dB whichever
+// window is used.
 type Spectrogram struct {
 	Frames     int
 	Bins       int
@@ -15,24 +17,131 @@ type Spectrogram struct {
 	SampleRate int
 	WindowSize int
 	HopSize    int
-	BinHz      float64
+	// FFTSize is the transform length; frames are zero-padded from
+	// WindowSize, so BinHz = SampleRate / FFTSize.
+	FFTSize int
+	BinHz   float64
+	// Window is the analysis window, with the calibration constants
+	// applied to the values.
+	Window Window
 }
 
-// HannWindow returns a Hann window of length n.
-func HannWindow(n int) []float64 {
-	w := make([]float64, n)
-	if n == 1 {
-		w[0] = 1
-		return w
-	}
-	for i := 0; i < n; i++ {
-		w[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+// SpectrogramOptions configures ComputeSpectrogramWith. Zero values pick
+// the defaults: a 2048-sample Hann window, a hop of a quarter window and an
+// FFT the size of the window.
+type SpectrogramOptions struct {
+	WindowSize int
+	HopSize    int
+	// FFTSize zero-pads each frame to this length for finer bin spacing;
+	// values below WindowSize use WindowSize.
+	FFTSize int
+	Window  WindowSpec
+	// Threads bounds the workers computing frames; 0 uses GOMAXPROCS.
+	Threads int
+}
+
+// frameOptions holds what every spectrogram worker shares.
+type frameOptions struct {
+	samples []float64
+	window  []float64
+	hopSize int
+	fftSize int
+	scale   float64
+	plan    *RealFFTPlan
+}
+
+// compute writes the dB values of frames f0..f1-1 to out, using frame and
+// spectrum as scratch, and returns their min and max.
+func (o frameOptions) compute(out []float64, f0, f1 int, frame []float64, spectrum []complex128) (minVal, maxVal float64) {
+	const eps = 1e-9
+	minVal, maxVal = math.Inf(1), math.Inf(-1)
+	bins := len(spectrum)
+	for f := f0; f < f1; f++ {
+		start := f * o.hopSize
+		// The padding past the window stays zero.
+		for i, w := range o.window {
+			if idx := start + i; idx < len(o.samples) {
+				frame[i] = o.samples[idx] * w
+			} else {
+				frame[i] = 0
+			}
+		}
+		o.plan.Transform(frame, spectrum)
+		row := out[(f-f0)*bins : (f-f0+1)*bins]
+		for b, c := range spectrum {
+			re, im := real(c), imag(c)
+			mag := math.Sqrt(re*re+im*im) * o.scale
+			if b == 0 || 2*b == o.fftSize {
+				mag /= 2
+			}
+			db := 20 * math.Log10(mag+eps)
+			row[b] = db
+			minVal = math.Min(minVal, db)
+			maxVal = math.Max(maxVal, db)
+		}
 	}
-	return w
+	return minVal, maxVal
 }
 
-// ComputeSpectrogram computes a log-magnitude spectrogram.
+// ComputeSpectrogram computes a log-magnitude spectrogram with a Hann window.
 func ComputeSpectrogram(samples []float64, sampleRate, windowSize, hopSize int) Spectrogram {
+	return ComputeSpectrogramWith(samples, sampleRate, SpectrogramOptions{WindowSize: windowSize, HopSize: hopSize})
+}
+
+// ComputeSpectrogramWith computes a log-magnitude spectrogram.
+func ComputeSpectrogramWith(samples []float64, sampleRate int, opts SpectrogramOptions) Spectrogram {
+	spec := newSpectrogram(len(samples), sampleRate, opts)
+	frames, bins, fftSize, windowSize := spec.Frames, spec.Bins, spec.FFTSize, spec.WindowSize
+	values, window := spec.Values, spec.Window
+	frameOpts := frameOptions{
+		samples: samples,
+		window:  window.Coeffs,
+		hopSize: spec.HopSize,
+		fftSize: fftSize,
+		// Undo the window's coherent gain and fold in the negative
+		// frequencies, which DC and Nyquist do not have.
+		scale: 2 / (window.CoherentGain * float64(windowSize)),
+		plan:  RealPlan(fftSize),
+	}
+
+	// Each worker tracks its own min/max, merged once all frames are done.
+	workers := frameWorkers(frames, opts.Threads)
+	mins := make([]float64, workers)
+	maxs := make([]float64, workers)
+	runFrames(frames, workers, func(w int) func(f0, f1 int) {
+		mins[w], maxs[w] = math.Inf(1), math.Inf(-1)
+		frame := make([]float64, fftSize)
+		spectrum := make([]complex128, bins)
+		return func(f0, f1 int) {
+			lo, hi := frameOpts.compute(values[f0*bins:f1*bins], f0, f1, frame, spectrum)
+			mins[w] = math.Min(mins[w], lo)
+			maxs[w] = math.Max(maxs[w], hi)
+		}
+	})
+	minVal := math.Inf(1)
+	maxVal := math.Inf(-1)
+	for w := range workers {
+		minVal = math.Min(minVal, mins[w])
+		maxVal = math.Max(maxVal, maxs[w])
+	}
+
+	spec.Min, spec.Max = minVal, maxVal
+	return spec
+}
+
+// frameCount returns how many frames of windowSize samples, hopSize apart
+// and starting at sample 0, cover n samples; the last may run past the end.
+func frameCount(n, windowSize, hopSize int) int {
+	if n <= windowSize {
+		return 1
+	}
+	return 1 + (n-windowSize+hopSize-1)/hopSize
+}
+
+// newSpectrogram resolves the defaults of opts for a signal of n samples
+// and returns the spectrogram layout with Values allocated.
+func newSpectrogram(n, sampleRate int, opts SpectrogramOptions) Spectrogram {
+	windowSize, hopSize := opts.WindowSize, opts.HopSize
 	if windowSize <= 0 {
 		windowSize = 2048
 	}
@@ -45,56 +154,19 @@ func ComputeSpectrogram(samples []float64, sampleRate, windowSize, hopSize int)
 	if sampleRate <= 0 {
 		sampleRate = 44100
 	}
+	fftSize := max(opts.FFTSize, windowSize)
 
-	frames := 1
-	if len(samples) > windowSize {
-		frames = 1 + (len(samples)-windowSize+hopSize-1)/hopSize
-	}
-	bins := windowSize/2 + 1
-	values := make([]float64, frames*bins)
-
-	window := HannWindow(windowSize)
-	minVal := math.Inf(1)
-	maxVal := math.Inf(-1)
-	eps := 1e-9
-
-	frame := make([]complex128, windowSize)
-	for f := 0; f < frames; f++ {
-		start := f * hopSize
-		for i := 0; i < windowSize; i++ {
-			idx := start + i
-			if idx < len(samples) {
-				frame[i] = complex(samples[idx]*window[i], 0)
-			} else {
-				frame[i] = 0
-			}
-		}
-		FFTInPlace(frame)
-		for b := 0; b < bins; b++ {
-			re := real(frame[b])
-			im := imag(frame[b])
-			mag := math.Sqrt(re*re + im*im)
-			db := 20 * math.Log10(mag+eps)
-			values[f*bins+b] = db
-			if db < minVal {
-				minVal = db
-			}
-			if db > maxVal {
-				maxVal = db
-			}
-		}
-	}
-
-	binHz := float64(sampleRate) / float64(windowSize)
+	frames := frameCount(n, windowSize, hopSize)
+	bins := fftSize/2 + 1
 	return Spectrogram{
 		Frames:     frames,
 		Bins:       bins,
-		Values:     values,
-		Min:        minVal,
-		Max:        maxVal,
+		Values:     make([]float64, frames*bins),
 		SampleRate: sampleRate,
 		WindowSize: windowSize,
 		HopSize:    hopSize,
-		BinHz:      binHz,
+		FFTSize:    fftSize,
+		BinHz:      float64(sampleRate) / float64(fftSize),
+		Window:     NewWindow(opts.Window, windowSize),
 	}
 }
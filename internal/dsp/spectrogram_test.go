@@ -1,6 +1,9 @@
 package dsp
 
-import "testing"
+import (
+	"math"
+	"testing"
+)
 
 func TestComputeSpectrogram(t *testing.T) {
 	samples := make([]float64, 4096)
@@ -52,3 +55,84 @@ func TestComputeSpectrogramDefaults(t *testing.T) {
 		t.Fatalf("frames = %d", spec.Frames)
 	}
 }
+
+func TestComputeSpectrogramPadded(t *testing.T) {
+	const (
+		window = 1000
+		rate   = 48000
+	)
+	// 1032 Hz falls halfway between the 48 Hz bins of the bare window.
+	samples := make([]float64, window)
+	for i := range samples {
+		samples[i] = 0.5 * math.Sin(2*math.Pi*1032*float64(i)/rate)
+	}
+	bare := ComputeSpectrogramWith(samples, rate, SpectrogramOptions{WindowSize: window, HopSize: window})
+	padded := ComputeSpectrogramWith(samples, rate, SpectrogramOptions{WindowSize: window, HopSize: window, FFTSize: 8 * window})
+	if padded.FFTSize != 8000 || padded.Bins != 4001 || padded.BinHz != 6 || padded.WindowSize != window {
+		t.Fatalf("unexpected padded layout: fft %d, bins %d, binHz %v", padded.FFTSize, padded.Bins, padded.BinHz)
+	}
+	peakBin := func(spec Spectrogram) int {
+		best := 0
+		for b := range spec.Bins {
+			if spec.Values[b] > spec.Values[best] {
+				best = b
+			}
+		}
+		return best
+	}
+	if hz := float64(peakBin(padded)) * padded.BinHz; math.Abs(hz-1032) > padded.BinHz/2 {
+		t.Fatalf("padded peak at %v Hz", hz)
+	}
+	want := 20 * math.Log10(0.5)
+	if math.Abs(padded.Values[peakBin(padded)]-want) > 0.1 || math.Abs(bare.Values[peakBin(bare)]-want) < 0.5 {
+		t.Fatalf("padding should recover the scalloping loss: bare %.2f, padded %.2f dB",
+			bare.Values[peakBin(bare)], padded.Values[peakBin(padded)])
+	}
+
+	// Band power is the same with and without padding.
+	bandPower := func(spec Spectrogram) float64 {
+		power := SpectrogramPower(&spec)
+		var sum float64
+		for b := range spec.Bins {
+			if hz := float64(b) * spec.BinHz; hz > 700 && hz < 1300 {
+				sum += power[b]
+			}
+		}
+		return 10 * math.Log10(sum)
+	}
+	if a, b := bandPower(bare), bandPower(padded); math.Abs(a-b) > 0.1 {
+		t.Fatalf("band power differs: bare %.3f, padded %.3f", a, b)
+	}
+
+	short := ComputeSpectrogramWith(samples, rate, SpectrogramOptions{WindowSize: window, FFTSize: 512})
+	if short.FFTSize != window {
+		t.Fatalf("FFT size below the window should use the window, got %d", short.FFTSize)
+	}
+}
+
+func TestComputeSpectrogramThreads(t *testing.T) {
+	// 1000 frames span several worker blocks, with a partial last block.
+	samples := testSignal(256 + 999*64)
+	opts := SpectrogramOptions{WindowSize: 256, HopSize: 64, Window: WindowSpec{Name: "kaiser", Param: 8.6}}
+	opts.Threads = 1
+	serial := ComputeSpectrogramWith(samples, 44100, opts)
+	opts.Threads = 8
+	parallel := ComputeSpectrogramWith(samples, 44100, opts)
+	if serial.Frames != 1000 || parallel.Frames != serial.Frames {
+		t.Fatalf("unexpected frames: %d, %d", serial.Frames, parallel.Frames)
+	}
+	if serial.Min != parallel.Min || serial.Max != parallel.Max {
+		t.Fatalf("min/max differ: %v/%v vs %v/%v", serial.Min, serial.Max, parallel.Min, parallel.Max)
+	}
+	minVal, maxVal := math.Inf(1), math.Inf(-1)
+	for i, v := range parallel.Values {
+		if v != serial.Values[i] {
+			t.Fatalf("value %d differs: %v vs %v", i, v, serial.Values[i])
+		}
+		minVal = math.Min(minVal, v)
+		maxVal = math.Max(maxVal, v)
+	}
+	if minVal != parallel.Min || maxVal != parallel.Max {
+		t.Fatalf("min/max %v/%v, values span %v/%v", parallel.Min, parallel.Max, minVal, maxVal)
+	}
+}
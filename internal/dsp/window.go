@@ -0,0 +1,205 @@
+package dsp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WindowSpec names a window function and its shape parameter (Kaiser beta,
+// Gaussian sigma, Tukey alpha). Param is ignored by fixed windows.
+type WindowSpec struct {
+	Name  string
+	Param float64
+}
+
+// DefaultWindow is the Hann window.
+var DefaultWindow = WindowSpec{Name: "hann"}
+
+// windowDef generates a symmetric window of length n > 1.
+type windowDef struct {
+	generate func(n int, param float64) []float64
+	// param is the default shape parameter; 0 marks a fixed window.
+	param float64
+}
+
+var windowDefs = map[string]windowDef{
+	"rectangular":     {generate: rectangularWindow},
+	"hann":            {generate: func(n int, _ float64) []float64 { return cosineWindow(n, 0.5, 0.5) }},
+	"hamming":         {generate: func(n int, _ float64) []float64 { return cosineWindow(n, 0.54, 0.46) }},
+	"blackman-harris": {generate: func(n int, _ float64) []float64 { return cosineWindow(n, 0.35875, 0.48829, 0.14128, 0.01168) }},
+	"flattop": {generate: func(n int, _ float64) []float64 {
+		return cosineWindow(n, 0.21557895, 0.41663158, 0.277263158, 0.083578947, 0.006947368)
+	}},
+	"kaiser":   {generate: kaiserWindow, param: 8.6},
+	"gaussian": {generate: gaussianWindow, param: 0.4},
+	"tukey":    {generate: tukeyWindow, param: 0.5},
+}
+
+// ParseWindow parses "name" or "name:param", such as "kaiser:12". Windows
+// with a shape parameter fall back to their default when it is omitted.
+func ParseWindow(s string) (WindowSpec, error) {
+	name, rawParam, hasParam := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+	if name == "" {
+		return DefaultWindow, nil
+	}
+	def, ok := windowDefs[name]
+	if !ok {
+		return WindowSpec{}, fmt.Errorf("unknown window %q (use %s)", name, WindowNames())
+	}
+	spec := WindowSpec{Name: name, Param: def.param}
+	if !hasParam {
+		return spec, nil
+	}
+	if def.param == 0 {
+		return WindowSpec{}, fmt.Errorf("window %s takes no parameter", name)
+	}
+	param, err := strconv.ParseFloat(rawParam, 64)
+	if err != nil || param <= 0 || math.IsInf(param, 0) || (name == "tukey" && param > 1) {
+		return WindowSpec{}, fmt.Errorf("invalid %s parameter %q", name, rawParam)
+	}
+	spec.Param = param
+	return spec, nil
+}
+
+// WindowNames returns the supported window names in deterministic order.
+func WindowNames() string {
+	names := make([]string, 0, len(windowDefs))
+	for name := range windowDefs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// String formats the spec as accepted by ParseWindow.
+func (s WindowSpec) String() string {
+	if windowDefs[s.Name].param == 0 {
+		return s.Name
+	}
+	return s.Name + ":" + strconv.FormatFloat(s.Param, 'g', -1, 64)
+}
+
+// Window holds window coefficients with their calibration constants.
+type Window struct {
+	Spec   WindowSpec
+	Coeffs []float64
+	// CoherentGain is the mean coefficient: a windowed sinusoid's spectral
+	// peak shrinks by this factor.
+	CoherentGain float64
+	// ENBW is the equivalent noise bandwidth in bins: summing the power of
+	// a windowed sinusoid over bins overstates it by this factor.
+	ENBW float64
+}
+
+// NewWindow builds the window for spec with n coefficients. An empty or
+// unknown name yields Hann.
+func NewWindow(spec WindowSpec, n int) Window {
+	def, ok := windowDefs[spec.Name]
+	if !ok {
+		spec, def = DefaultWindow, windowDefs[DefaultWindow.Name]
+	}
+	if def.param != 0 && spec.Param <= 0 {
+		spec.Param = def.param
+	}
+	var coeffs []float64
+	if n == 1 {
+		coeffs = []float64{1}
+	} else {
+		coeffs = def.generate(n, spec.Param)
+	}
+
+	var sum, sumSq float64
+	for _, w := range coeffs {
+		sum += w
+		sumSq += w * w
+	}
+	win := Window{Spec: spec, Coeffs: coeffs}
+	if n > 0 && sum != 0 {
+		win.CoherentGain = sum / float64(n)
+		win.ENBW = float64(n) * sumSq / (sum * sum)
+	}
+	return win
+}
+
+// HannWindow returns a Hann window of length n.
+func HannWindow(n int) []float64 {
+	return NewWindow(DefaultWindow, n).Coeffs
+}
+
+func rectangularWindow(n int, _ float64) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		w[i] = 1
+	}
+	return w
+}
+
+// cosineWindow sums a[k]*cos(2πki/(n-1)) with alternating signs, the form
+// shared by Hann, Hamming, Blackman-Harris and flat-top.
+func cosineWindow(n int, a ...float64) []float64 {
+	w := make([]float64, n)
+	for i := range w {
+		x := 2 * math.Pi * float64(i) / float64(n-1)
+		sign := 1.0
+		for k, ak := range a {
+			w[i] += sign * ak * math.Cos(float64(k)*x)
+			sign = -sign
+		}
+	}
+	return w
+}
+
+func kaiserWindow(n int, beta float64) []float64 {
+	w := make([]float64, n)
+	norm := besselI0(beta)
+	for i := range w {
+		r := 2*float64(i)/float64(n-1) - 1
+		w[i] = besselI0(beta*math.Sqrt(math.Max(0, 1-r*r))) / norm
+	}
+	return w
+}
+
+// gaussianWindow uses sigma as a fraction of the half-width.
+func gaussianWindow(n int, sigma float64) []float64 {
+	w := make([]float64, n)
+	half := float64(n-1) / 2
+	for i := range w {
+		x := (float64(i) - half) / (sigma * half)
+		w[i] = math.Exp(-0.5 * x * x)
+	}
+	return w
+}
+
+// tukeyWindow tapers the outer alpha fraction with a cosine; alpha 1 is Hann.
+func tukeyWindow(n int, alpha float64) []float64 {
+	w := make([]float64, n)
+	edge := alpha * float64(n-1) / 2
+	for i := range w {
+		x := float64(i)
+		if d := float64(n-1) - x; d < x {
+			x = d
+		}
+		if x < edge {
+			w[i] = 0.5 - 0.5*math.Cos(math.Pi*x/edge)
+		} else {
+			w[i] = 1
+		}
+	}
+	return w
+}
+
+// besselI0 is the zeroth-order modified Bessel function of the first kind.
+func besselI0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-16 {
+			break
+		}
+	}
+	return sum
+}
@@ -0,0 +1,113 @@
+package dsp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWindowCalibration(t *testing.T) {
+	cases := []struct {
+		name string
+		cg   float64
+		enbw float64
+	}{
+		{"rectangular", 1, 1},
+		{"hann", 0.5, 1.5},
+		{"hamming", 0.54, 1.363},
+		{"blackman-harris", 0.35875, 2.004},
+		{"flattop", 0.2156, 3.77},
+		{"tukey:1", 0.5, 1.5},
+	}
+	for _, tc := range cases {
+		spec, err := ParseWindow(tc.name)
+		if err != nil {
+			t.Fatalf("ParseWindow(%s): %v", tc.name, err)
+		}
+		w := NewWindow(spec, 4096)
+		if math.Abs(w.CoherentGain-tc.cg) > 1e-3 || math.Abs(w.ENBW-tc.enbw) > 1e-2 {
+			t.Fatalf("%s: coherent gain %.4f, ENBW %.4f", tc.name, w.CoherentGain, w.ENBW)
+		}
+	}
+}
+
+func TestWindowShapes(t *testing.T) {
+	for _, name := range []string{"hann", "hamming", "blackman-harris", "flattop", "kaiser", "gaussian", "tukey"} {
+		w := NewWindow(WindowSpec{Name: name}, 255).Coeffs
+		if math.Abs(w[127]-1) > 1e-6 {
+			t.Fatalf("%s: centre = %v", name, w[127])
+		}
+		for i := range w {
+			if math.Abs(w[i]-w[len(w)-1-i]) > 1e-12 {
+				t.Fatalf("%s: not symmetric at %d", name, i)
+			}
+		}
+	}
+	// A larger Kaiser beta narrows the window.
+	narrow := NewWindow(WindowSpec{Name: "kaiser", Param: 14}, 255)
+	wide := NewWindow(WindowSpec{Name: "kaiser", Param: 2}, 255)
+	if narrow.CoherentGain >= wide.CoherentGain {
+		t.Fatalf("kaiser beta has no effect: %v vs %v", narrow.CoherentGain, wide.CoherentGain)
+	}
+	if w := NewWindow(WindowSpec{Name: "nope"}, 8); w.Spec != DefaultWindow {
+		t.Fatalf("unknown window should fall back to hann, got %v", w.Spec)
+	}
+}
+
+func TestParseWindow(t *testing.T) {
+	spec, err := ParseWindow(" Kaiser:12 ")
+	if err != nil || spec != (WindowSpec{Name: "kaiser", Param: 12}) || spec.String() != "kaiser:12" {
+		t.Fatalf("kaiser:12 = %v, %v", spec, err)
+	}
+	spec, err = ParseWindow("gaussian")
+	if err != nil || spec.Param != 0.4 {
+		t.Fatalf("gaussian default = %v, %v", spec, err)
+	}
+	if spec, err := ParseWindow(""); err != nil || spec != DefaultWindow || spec.String() != "hann" {
+		t.Fatalf("empty = %v, %v", spec, err)
+	}
+	for _, bad := range []string{"bartlett", "hann:2", "kaiser:x", "kaiser:-1", "tukey:1.5"} {
+		if _, err := ParseWindow(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestSpectrogramCalibratedAcrossWindows(t *testing.T) {
+	const (
+		n    = 1024
+		rate = 48000
+	)
+	// Bin 64 exactly, amplitude 0.5 (-6.02 dBFS).
+	freq := 64.0 * rate / n
+	samples := make([]float64, n)
+	for i := range samples {
+		samples[i] = 0.5 * math.Sin(2*math.Pi*freq*float64(i)/rate)
+	}
+	want := 20 * math.Log10(0.5)
+	for _, name := range []string{"rectangular", "hann", "hamming", "blackman-harris", "flattop", "kaiser", "gaussian", "tukey"} {
+		spec := ComputeSpectrogramWith(samples, rate, SpectrogramOptions{WindowSize: n, HopSize: n, Window: WindowSpec{Name: name}})
+		peak := spec.Values[64]
+		// Symmetric windows are not quite DFT-even, so allow a small error.
+		if math.Abs(peak-want) > 0.1 {
+			t.Fatalf("%s: peak %.3f dB, want %.3f", name, peak, want)
+		}
+		power := SpectrogramPower(&spec)
+		var sum float64
+		for b := 54; b <= 74; b++ {
+			sum += power[b]
+		}
+		if got := 10 * math.Log10(sum); math.Abs(got-want) > 0.2 {
+			t.Fatalf("%s: band power %.3f dB, want %.3f", name, got, want)
+		}
+	}
+
+	// Flat-top keeps the level even halfway between bins.
+	freq = 64.5 * rate / n
+	for i := range samples {
+		samples[i] = 0.5 * math.Sin(2*math.Pi*freq*float64(i)/rate)
+	}
+	spec := ComputeSpectrogramWith(samples, rate, SpectrogramOptions{WindowSize: n, HopSize: n, Window: WindowSpec{Name: "flattop"}})
+	if peak := math.Max(spec.Values[64], spec.Values[65]); math.Abs(peak-want) > 0.1 {
+		t.Fatalf("flattop scalloping: peak %.3f dB", peak)
+	}
+}
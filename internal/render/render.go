@@ -20,6 +20,8 @@ type Options struct {
 	MaxDB    float64
 	ClampDB  bool
 	FlipVert bool
+	// Scale maps rows to frequency; empty is linear.
+	Scale FreqScale
 }
 
 // Spectrogram renders a spectrogram into an RGBA image.
@@ -62,7 +64,7 @@ func Spectrogram(spec *dsp.Spectrogram, opts Options) (*image.RGBA, error) {
 		minBin = 0
 		maxBin = spec.Bins - 1
 	}
-	binSpan := maxBin - minBin
+	rows := rowSources(opts.Scale, opts.Height, minBin, maxBin, spec.BinHz)
 
 	img := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
 	frames := spec.Frames
@@ -72,20 +74,9 @@ func Spectrogram(spec *dsp.Spectrogram, opts Options) (*image.RGBA, error) {
 		if frames > 1 && opts.Width > 1 {
 			frame = int(math.Round(float64(x) * float64(frames-1) / float64(opts.Width-1)))
 		}
-		frameOffset := frame * bins
-		for y := 0; y < opts.Height; y++ {
-			pos := 0.0
-			if opts.Height > 1 {
-				pos = float64(y) / float64(opts.Height-1)
-			}
-			bin := minBin + int(math.Round((1-pos)*float64(binSpan)))
-			if bin < minBin {
-				bin = minBin
-			}
-			if bin > maxBin {
-				bin = maxBin
-			}
-			val := spec.Values[frameOffset+bin]
+		frameValues := spec.Values[frame*bins : (frame+1)*bins]
+		for y, row := range rows {
+			val := row.value(frameValues)
 			norm := (val - minDB) / (maxDB - minDB)
 			if norm < 0 {
 				norm = 0
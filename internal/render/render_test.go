@@ -300,3 +300,86 @@ func TestHeatmapFlipVert(t *testing.T) {
 		t.Fatalf("expected flipped pixel")
 	}
 }
+
+func TestRenderSpectrogramFreqScales(t *testing.T) {
+	// 101 bins of 100 Hz; one loud bin at 200 Hz and one at 9 kHz.
+	spec := dsp.Spectrogram{Frames: 1, Bins: 101, Values: make([]float64, 101), Min: -80, Max: 0, BinHz: 100}
+	for b := range spec.Values {
+		spec.Values[b] = -80
+	}
+	spec.Values[2] = 0
+	spec.Values[90] = 0
+	loudRows := func(scale FreqScale) (low, high int) {
+		img, err := Spectrogram(&spec, Options{
+			Width:   1,
+			Height:  40,
+			Scale:   scale,
+			Palette: func(t float64) color.RGBA { return color.RGBA{R: uint8(255 * t), A: 255} },
+		})
+		if err != nil {
+			t.Fatalf("%s: %v", scale, err)
+		}
+		for y := 0; y < 40; y++ {
+			if img.RGBAAt(0, y).R < 128 {
+				continue
+			}
+			if y < 20 {
+				high++
+			} else {
+				low++
+			}
+		}
+		return low, high
+	}
+
+	// Nearest-bin rows on a linear axis hold at most one row per tone.
+	linLow, linHigh := loudRows(FreqLinear)
+	if linLow > 1 || linHigh > 1 {
+		t.Fatalf("linear: expected at most one row per tone, got %d and %d", linLow, linHigh)
+	}
+	for _, scale := range []FreqScale{FreqLog, FreqMel, FreqERB} {
+		low, high := loudRows(scale)
+		// The narrow high rows must keep the 9 kHz peak, and the widened
+		// low end gives 200 Hz more rows than a linear axis does.
+		if high == 0 || low <= linLow {
+			t.Fatalf("%s: expected widened low tone and kept high tone, got %d and %d rows", scale, low, high)
+		}
+	}
+}
+
+func TestParseFreqScale(t *testing.T) {
+	if scale, err := ParseFreqScale(""); err != nil || scale != FreqLinear {
+		t.Fatalf("expected linear default, got %q %v", scale, err)
+	}
+	if scale, err := ParseFreqScale(" LOG "); err != nil || scale != FreqLog {
+		t.Fatalf("expected log, got %q %v", scale, err)
+	}
+	if _, err := ParseFreqScale("bark"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestDrawText(t *testing.T) {
+	if w, h := TextSize("C#m7", 2); w != 46 || h != 14 {
+		t.Fatalf("unexpected text size %dx%d", w, h)
+	}
+	if w, h := TextSize("", 2); w != 0 || h != 0 {
+		t.Fatalf("unexpected empty text size %dx%d", w, h)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	white := color.RGBA{255, 255, 255, 255}
+	DrawText(img, 1, 1, "N7", 1, white)
+	// N's left stem, its diagonal, and the bar of the 7.
+	for _, p := range []image.Point{{1, 1}, {1, 7}, {3, 3}, {7, 1}, {11, 1}} {
+		if img.RGBAAt(p.X, p.Y) != white {
+			t.Fatalf("expected ink at %v", p)
+		}
+	}
+	for _, p := range []image.Point{{2, 1}, {6, 1}, {7, 7}} {
+		if img.RGBAAt(p.X, p.Y) == white {
+			t.Fatalf("unexpected ink at %v", p)
+		}
+	}
+	// Clipped at the edges.
+	DrawText(img, 15, 5, "A", 3, white)
+}
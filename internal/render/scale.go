@@ -0,0 +1,123 @@
+package render
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// FreqScale selects how Spectrogram maps rows to frequency.
+type FreqScale string
+
+// Frequency scales.
+const (
+	FreqLinear FreqScale = "linear"
+	FreqLog    FreqScale = "log"
+	FreqMel    FreqScale = "mel"
+	FreqERB    FreqScale = "erb"
+)
+
+// logFloorHz is the lowest frequency of a log axis that would otherwise
+// start at 0 Hz.
+const logFloorHz = 20
+
+// ParseFreqScale validates a frequency scale name.
+func ParseFreqScale(name string) (FreqScale, error) {
+	scale := FreqScale(strings.ToLower(strings.TrimSpace(name)))
+	switch scale {
+	case "":
+		return FreqLinear, nil
+	case FreqLinear, FreqLog, FreqMel, FreqERB:
+		return scale, nil
+	default:
+		return "", fmt.Errorf("unknown frequency scale %q (use linear, log, mel, erb)", name)
+	}
+}
+
+// warp returns the mapping from Hz onto the scale and its inverse.
+func (s FreqScale) warp() (fwd, inv func(float64) float64) {
+	switch s {
+	case FreqLog:
+		return math.Log, math.Exp
+	case FreqMel:
+		// HTK mel scale.
+		return func(hz float64) float64 { return 2595 * math.Log10(1+hz/700) },
+			func(mel float64) float64 { return 700 * (math.Pow(10, mel/2595) - 1) }
+	case FreqERB:
+		// ERB-rate scale of Glasberg and Moore.
+		return func(hz float64) float64 { return 21.4 * math.Log10(1+0.00437*hz) },
+			func(erb float64) float64 { return (math.Pow(10, erb/21.4) - 1) / 0.00437 }
+	default:
+		identity := func(v float64) float64 { return v }
+		return identity, identity
+	}
+}
+
+// rowSource says where a pixel row reads its value: the maximum of bins
+// lo..hi when the row spans several bins, otherwise bin lo interpolated
+// towards hi by frac.
+type rowSource struct {
+	lo, hi int
+	frac   float64
+	peak   bool
+}
+
+func (r rowSource) value(values []float64) float64 {
+	if r.peak {
+		v := values[r.lo]
+		for _, x := range values[r.lo+1 : r.hi+1] {
+			v = math.Max(v, x)
+		}
+		return v
+	}
+	return values[r.lo] + (values[r.hi]-values[r.lo])*r.frac
+}
+
+// rowSources maps rows (top first) onto bins minBin..maxBin. The linear
+// scale picks the nearest bin. Other scales space rows evenly on the scale,
+// interpolate where a row is narrower than a bin and keep the strongest bin
+// where it is wider, so peaks do not drop out between rows.
+func rowSources(scale FreqScale, height, minBin, maxBin int, binHz float64) []rowSource {
+	rows := make([]rowSource, height)
+	span := maxBin - minBin
+	if scale == FreqLinear || scale == "" || binHz <= 0 || span <= 0 {
+		for y := range rows {
+			pos := 0.0
+			if height > 1 {
+				pos = float64(y) / float64(height-1)
+			}
+			bin := minBin + int(math.Round((1-pos)*float64(span)))
+			bin = min(max(bin, minBin), maxBin)
+			rows[y] = rowSource{lo: bin, hi: bin}
+		}
+		return rows
+	}
+
+	fwd, inv := scale.warp()
+	lowHz := float64(minBin) * binHz
+	if scale == FreqLog {
+		lowHz = math.Max(lowHz, math.Min(logFloorHz, float64(maxBin)*binHz/2))
+	}
+	low, high := fwd(lowHz), fwd(float64(maxBin)*binHz)
+	step := 0.0
+	if height > 1 {
+		step = (high - low) / float64(height-1)
+	}
+	toBin := func(v float64) float64 {
+		return math.Min(math.Max(inv(v)/binHz, float64(minBin)), float64(maxBin))
+	}
+	for y := range rows {
+		centre := high - float64(y)*step
+		first := int(math.Ceil(toBin(centre - step/2)))
+		last := int(math.Floor(toBin(centre + step/2)))
+		if last > first {
+			rows[y] = rowSource{lo: first, hi: last, peak: true}
+			continue
+		}
+		pos := toBin(centre)
+		lo := int(math.Floor(pos))
+		hi := min(lo+1, maxBin)
+		rows[y] = rowSource{lo: lo, hi: hi, frac: pos - float64(lo)}
+	}
+	return rows
+}
@@ -0,0 +1,60 @@
+// Package render turns spectrograms into images.
+package render
+
+import (
+	"image"
+	"image/color"
+)
+
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+// glyphs is a 5x7 bitmap font for chord symbols.
+var glyphs = map[rune][glyphHeight]string{
+	'A': {".###.", "#...#", "#...#", "#####", "#...#", "#...#", "#...#"},
+	'B': {"####.", "#...#", "#...#", "####.", "#...#", "#...#", "####."},
+	'C': {".###.", "#...#", "#....", "#....", "#....", "#...#", ".###."},
+	'D': {"####.", "#...#", "#...#", "#...#", "#...#", "#...#", "####."},
+	'E': {"#####", "#....", "#....", "####.", "#....", "#....", "#####"},
+	'F': {"#####", "#....", "#....", "####.", "#....", "#....", "#...."},
+	'G': {".###.", "#...#", "#....", "#.###", "#...#", "#...#", ".###."},
+	'N': {"#...#", "##..#", "#.#.#", "#..##", "#...#", "#...#", "#...#"},
+	'm': {".....", ".....", "##.#.", "#.#.#", "#.#.#", "#.#.#", "#.#.#"},
+	'#': {".#.#.", ".#.#.", "#####", ".#.#.", "#####", ".#.#.", ".#.#."},
+	'7': {"#####", "....#", "...#.", "..#..", ".#...", ".#...", ".#..."},
+}
+
+// TextSize returns the size in pixels of text drawn by DrawText at scale.
+func TextSize(text string, scale int) (width, height int) {
+	runes := len([]rune(text))
+	if runes == 0 || scale <= 0 {
+		return 0, 0
+	}
+	return (runes*(glyphWidth+1) - 1) * scale, glyphHeight * scale
+}
+
+// DrawText draws text with its top-left corner at (x, y), each font pixel
+// a scale-sized square. The font covers chord symbols (A-G, N, m, # and 7);
+// other runes leave a gap.
+func DrawText(img *image.RGBA, x, y int, text string, scale int, c color.RGBA) {
+	for _, r := range text {
+		glyph := glyphs[r]
+		for row, line := range glyph {
+			for col, dot := range line {
+				if dot != '#' {
+					continue
+				}
+				for dy := 0; dy < scale; dy++ {
+					for dx := 0; dx < scale; dx++ {
+						if p := image.Pt(x+col*scale+dx, y+row*scale+dy); p.In(img.Rect) {
+							img.SetRGBA(p.X, p.Y, c)
+						}
+					}
+				}
+			}
+		}
+		x += (glyphWidth + 1) * scale
+	}
+}
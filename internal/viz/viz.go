@@ -10,6 +10,7 @@ import (
 	"sort"
 	"strings"
 
+	"github.com/steipete/songsee/internal/analysis"
 	"github.com/steipete/songsee/internal/dsp"
 	"github.com/steipete/songsee/internal/render"
 )
@@ -27,6 +28,8 @@ const (
 	Tempogram   Kind = "tempogram"
 	MFCC        Kind = "mfcc"
 	Flux        Kind = "flux"
+	CQT         Kind = "cqt"
+	Reassigned  Kind = "reassigned"
 )
 
 var validKinds = map[Kind]struct{}{
@@ -39,6 +42,38 @@ var validKinds = map[Kind]struct{}{
 	Tempogram:   {},
 	MFCC:        {},
 	Flux:        {},
+	CQT:         {},
+	Reassigned:  {},
+}
+
+// ChromaMode selects how the chroma and selfsim panels compute chroma.
+type ChromaMode string
+
+// Chroma modes.
+const (
+	// ChromaSTFT rounds STFT bins to pitch classes of A440.
+	ChromaSTFT ChromaMode = "stft"
+	// ChromaTuned corrects for the estimated tuning and folds harmonics
+	// into their fundamental's class.
+	ChromaTuned ChromaMode = "tuned"
+	// ChromaCQT folds a tuned three-bins-per-semitone CQT, which resolves
+	// low notes that STFT bins cannot.
+	ChromaCQT ChromaMode = "cqt"
+	// ChromaCENS smooths and quantizes the CQT chroma for structure.
+	ChromaCENS ChromaMode = "cens"
+)
+
+// ParseChromaMode validates a chroma mode name.
+func ParseChromaMode(name string) (ChromaMode, error) {
+	mode := ChromaMode(strings.ToLower(strings.TrimSpace(name)))
+	switch mode {
+	case "":
+		return ChromaSTFT, nil
+	case ChromaSTFT, ChromaTuned, ChromaCQT, ChromaCENS:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown chroma mode %q (use stft, tuned, cqt, cens)", name)
+	}
 }
 
 // ParseList normalizes a list of viz names, allowing comma-separated values.
@@ -87,19 +122,30 @@ type Context struct {
 	SampleRate int
 	WindowSize int
 	HopSize    int
+	Threads    int
 	Spec       dsp.Spectrogram
+	specOpts   dsp.SpectrogramOptions
 	power      []float64
+	tuning     *float64
+	chroma     map[ChromaMode]dsp.FeatureMap
 }
 
 // NewContext analyzes the samples and prepares the base spectrogram.
 func NewContext(samples []float64, sampleRate, windowSize, hopSize int) *Context {
-	spec := dsp.ComputeSpectrogram(samples, sampleRate, windowSize, hopSize)
+	return NewContextWith(samples, sampleRate, dsp.SpectrogramOptions{WindowSize: windowSize, HopSize: hopSize})
+}
+
+// NewContextWith is NewContext with full spectrogram options.
+func NewContextWith(samples []float64, sampleRate int, opts dsp.SpectrogramOptions) *Context {
+	spec := dsp.ComputeSpectrogramWith(samples, sampleRate, opts)
 	return &Context{
 		Samples:    samples,
 		SampleRate: sampleRate,
-		WindowSize: windowSize,
-		HopSize:    hopSize,
+		WindowSize: opts.WindowSize,
+		HopSize:    opts.HopSize,
+		Threads:    opts.Threads,
 		Spec:       spec,
+		specOpts:   opts,
 	}
 }
 
@@ -111,6 +157,73 @@ func (c *Context) Power() []float64 {
 	return c.power
 }
 
+// Tuning returns the cached tuning estimate of the samples in semitones
+// from A440.
+func (c *Context) Tuning() float64 {
+	if c.tuning == nil {
+		tuning := dsp.EstimateTuning(&c.Spec)
+		c.tuning = &tuning
+	}
+	return *c.tuning
+}
+
+// FrameRate returns the spectrogram and chroma frames per second.
+func (c *Context) FrameRate() float64 {
+	return float64(c.Spec.SampleRate) / float64(c.Spec.HopSize)
+}
+
+// Chroma returns the cached chromagram for mode.
+func (c *Context) Chroma(mode ChromaMode) dsp.FeatureMap {
+	if mode == "" {
+		mode = ChromaSTFT
+	}
+	if chroma, ok := c.chroma[mode]; ok {
+		return chroma
+	}
+	var chroma dsp.FeatureMap
+	switch mode {
+	case ChromaTuned:
+		chroma = dsp.ChromaWith(&c.Spec, c.Power(), dsp.ChromaOptions{Tuning: c.Tuning(), Harmonics: chromaHarmonics})
+	case ChromaCQT:
+		cqt := dsp.ComputeCQT(c.Samples, c.SampleRate, dsp.CQTOptions{
+			BinsPerOctave: 36,
+			MinFreq:       dsp.MIDIToHz(24 + c.Tuning()),
+			HopSize:       c.Spec.HopSize,
+			WindowSize:    c.Spec.WindowSize,
+			Threads:       c.Threads,
+		})
+		chroma = dsp.ChromaFromCQT(&cqt, c.Tuning())
+	case ChromaCENS:
+		chroma = dsp.CENS(c.Chroma(ChromaCQT), 0)
+	default:
+		chroma = dsp.ChromaFromPower(&c.Spec, c.Power())
+	}
+	if c.chroma == nil {
+		c.chroma = map[ChromaMode]dsp.FeatureMap{}
+	}
+	c.chroma[mode] = chroma
+	return chroma
+}
+
+// Harmony is the estimated key and chord timeline of a signal.
+type Harmony struct {
+	Key    analysis.Key
+	Chords []analysis.ChordSegment
+}
+
+// Harmony estimates the key and chords from the chroma of mode. CENS
+// values are not energies, so cens uses the cqt chroma it is built from.
+func (c *Context) Harmony(mode ChromaMode) Harmony {
+	if mode == ChromaCENS {
+		mode = ChromaCQT
+	}
+	chroma := c.Chroma(mode)
+	return Harmony{
+		Key:    analysis.EstimateKey(chroma),
+		Chords: analysis.EstimateChords(chroma, analysis.ChordOptions{FrameRate: c.FrameRate()}),
+	}
+}
+
 // RenderOptions configures a visualization render.
 type RenderOptions struct {
 	Width   int
@@ -118,6 +231,19 @@ type RenderOptions struct {
 	Palette render.Palette
 	MinFreq float64
 	MaxFreq float64
+	// BinsPerOctave sets the CQT resolution (0 = 12, one bin per semitone).
+	BinsPerOctave int
+	// FreqScale sets the frequency axis of the spectrogram panels.
+	FreqScale render.FreqScale
+	// Mel shapes the mel filterbank; its frequency range comes from
+	// MinFreq and MaxFreq.
+	Mel dsp.MelOptions
+	// MFCC configures the mfcc panel; its filterbank is Mel.
+	MFCC dsp.MFCCOptions
+	// ChromaMode selects the chroma of the chroma and selfsim panels.
+	ChromaMode ChromaMode
+	// ChordLabels draws the estimated chords over the chroma panel.
+	ChordLabels bool
 }
 
 // Render builds a visualization panel image for the given kind.
@@ -134,9 +260,10 @@ func Render(kind Kind, ctx *Context, opts RenderOptions) (*image.RGBA, error) {
 			MinDB:   minDB,
 			MaxDB:   maxDB,
 			ClampDB: true,
+			Scale:   opts.FreqScale,
 		})
 	case Mel:
-		mel := dsp.MelSpectrogramFromPower(&ctx.Spec, ctx.Power(), 0, opts.MinFreq, opts.MaxFreq)
+		mel := dsp.MelSpectrogramWith(&ctx.Spec, ctx.Power(), opts.melOptions())
 		minVal, maxVal := percentileRange(mel.Values, 0.05, 0.98)
 		return render.Heatmap(&mel, render.HeatmapOptions{
 			Width:    opts.Width,
@@ -148,9 +275,9 @@ func Render(kind Kind, ctx *Context, opts RenderOptions) (*image.RGBA, error) {
 			FlipVert: true,
 		})
 	case Chroma:
-		chroma := dsp.ChromaFromPower(&ctx.Spec, ctx.Power())
+		chroma := ctx.Chroma(opts.ChromaMode)
 		minVal, maxVal := percentileRange(chroma.Values, 0.1, 0.98)
-		return render.Heatmap(&chroma, render.HeatmapOptions{
+		img, err := render.Heatmap(&chroma, render.HeatmapOptions{
 			Width:    opts.Width,
 			Height:   opts.Height,
 			Palette:  opts.Palette,
@@ -159,10 +286,31 @@ func Render(kind Kind, ctx *Context, opts RenderOptions) (*image.RGBA, error) {
 			Clamp:    true,
 			FlipVert: true,
 		})
+		if err == nil && opts.ChordLabels {
+			drawChordLabels(img, ctx.Harmony(opts.ChromaMode).Chords, chroma.Width)
+		}
+		return img, err
 	case MFCC:
-		mfcc := dsp.MFCCFromPower(&ctx.Spec, ctx.Power(), 0, 0, opts.MinFreq, opts.MaxFreq)
-		minVal, maxVal := percentileRange(mfcc.Values, 0.05, 0.98)
-		return render.Heatmap(&mfcc, render.HeatmapOptions{
+		return renderMFCC(ctx, opts)
+	case Reassigned:
+		spec := dsp.ComputeReassigned(ctx.Samples, ctx.SampleRate, ctx.specOpts)
+		// Most cells receive no energy; scale the palette to those that do.
+		minDB, maxDB := percentileRange(aboveFloor(spec.Values, -170), 0.05, 0.995)
+		return render.Spectrogram(&spec, render.Options{
+			Width:   opts.Width,
+			Height:  opts.Height,
+			MinFreq: opts.MinFreq,
+			MaxFreq: opts.MaxFreq,
+			Palette: opts.Palette,
+			MinDB:   minDB,
+			MaxDB:   maxDB,
+			ClampDB: true,
+			Scale:   opts.FreqScale,
+		})
+	case CQT:
+		cqt := computeCQT(ctx, opts)
+		minVal, maxVal := percentileRange(cqt.Values, 0.05, 0.98)
+		return render.Heatmap(&cqt.FeatureMap, render.HeatmapOptions{
 			Width:    opts.Width,
 			Height:   opts.Height,
 			Palette:  opts.Palette,
@@ -174,7 +322,7 @@ func Render(kind Kind, ctx *Context, opts RenderOptions) (*image.RGBA, error) {
 	case HPSS:
 		return renderHPSS(ctx, opts)
 	case SelfSim:
-		chroma := dsp.ChromaFromPower(&ctx.Spec, ctx.Power())
+		chroma := ctx.Chroma(opts.ChromaMode)
 		self := dsp.SelfSimilarity(chroma, 200)
 		applyGamma(&self, 1.4)
 		minVal, maxVal := percentileRange(self.Values, 0.1, 0.98)
@@ -211,13 +359,124 @@ func Render(kind Kind, ctx *Context, opts RenderOptions) (*image.RGBA, error) {
 	}
 }
 
+// computeCQT runs the constant-Q transform for the cqt panel. A --min-freq
+// is snapped to the nearest note so that rows line up with MIDI notes; the
+// default starts at C1.
+func computeCQT(ctx *Context, opts RenderOptions) dsp.CQT {
+	minFreq := opts.MinFreq
+	if minFreq > 0 {
+		minFreq = dsp.MIDIToHz(math.Round(dsp.HzToMIDI(minFreq)))
+	}
+	return dsp.ComputeCQT(ctx.Samples, ctx.SampleRate, dsp.CQTOptions{
+		BinsPerOctave: opts.BinsPerOctave,
+		MinFreq:       minFreq,
+		MaxFreq:       opts.MaxFreq,
+		HopSize:       ctx.HopSize,
+		WindowSize:    ctx.WindowSize,
+		Threads:       ctx.Threads,
+	})
+}
+
+// chromaHarmonics is how many partials the tuned chroma folds together.
+const chromaHarmonics = 4
+
+// drawChordLabels marks each chord change of a panel showing frames frames
+// with a tick and the chord symbol on a dark box along the top edge. Labels
+// that would overlap the previous one and "N" are skipped.
+func drawChordLabels(img *image.RGBA, chords []analysis.ChordSegment, frames int) {
+	width, height := img.Bounds().Dx(), img.Bounds().Dy()
+	if frames <= 0 {
+		return
+	}
+	scale := max(1, height/200)
+	white := color.RGBA{255, 255, 255, 255}
+	backing := &image.Uniform{C: color.RGBA{0, 0, 0, 255}}
+	free := 0
+	for _, seg := range chords {
+		x := seg.Start * width / frames
+		if seg.Chord.Quality == analysis.NoChord || x < free {
+			continue
+		}
+		label := seg.Chord.String()
+		w, h := render.TextSize(label, scale)
+		pad := scale
+		draw.Draw(img, image.Rect(x, 0, x+w+2*pad+scale, h+2*pad), backing, image.Point{}, draw.Src)
+		draw.Draw(img, image.Rect(x, 0, x+scale, height), &image.Uniform{C: white}, image.Point{}, draw.Src)
+		render.DrawText(img, x+scale+pad, pad, label, scale, white)
+		free = x + w + 2*pad + 2*scale
+	}
+}
+
+func (o RenderOptions) melOptions() dsp.MelOptions {
+	mel := o.Mel
+	mel.MinFreq, mel.MaxFreq = o.MinFreq, o.MaxFreq
+	return mel
+}
+
+// renderMFCC draws the static coefficients, then any deltas and
+// delta-deltas below them, each block with its own contrast range.
+func renderMFCC(ctx *Context, opts RenderOptions) (*image.RGBA, error) {
+	mfccOpts := opts.MFCC
+	mfccOpts.Mel = opts.melOptions()
+	mfcc := dsp.MFCCWith(&ctx.Spec, ctx.Power(), mfccOpts)
+	blocks := 1 + min(max(mfccOpts.Deltas, 0), 2)
+	coeffs := mfcc.Height / blocks
+
+	gap := 4
+	blockHeight := (opts.Height - gap*(blocks-1)) / blocks
+	if blockHeight <= 0 {
+		return nil, fmt.Errorf("invalid output size")
+	}
+	canvas := image.NewRGBA(image.Rect(0, 0, opts.Width, opts.Height))
+	draw.Draw(canvas, canvas.Bounds(), &image.Uniform{C: color.RGBA{0, 0, 0, 255}}, image.Point{}, draw.Src)
+	for i := 0; i < blocks; i++ {
+		block := featureRows(mfcc, i*coeffs, (i+1)*coeffs)
+		height := blockHeight
+		if i == blocks-1 {
+			height = opts.Height - i*(blockHeight+gap)
+		}
+		minVal, maxVal := percentileRange(block.Values, 0.05, 0.98)
+		img, err := render.Heatmap(&block, render.HeatmapOptions{
+			Width:    opts.Width,
+			Height:   height,
+			Palette:  opts.Palette,
+			Min:      minVal,
+			Max:      maxVal,
+			Clamp:    true,
+			FlipVert: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		top := i * (blockHeight + gap)
+		draw.Draw(canvas, image.Rect(0, top, opts.Width, top+height), img, image.Point{}, draw.Over)
+	}
+	return canvas, nil
+}
+
+// featureRows returns rows y0..y1-1 of m, sharing its values.
+func featureRows(m dsp.FeatureMap, y0, y1 int) dsp.FeatureMap {
+	out := dsp.FeatureMap{Width: m.Width, Height: y1 - y0, Values: m.Values[y0*m.Width : y1*m.Width]}
+	out.Min, out.Max = math.Inf(1), math.Inf(-1)
+	for _, v := range out.Values {
+		out.Min = math.Min(out.Min, v)
+		out.Max = math.Max(out.Max, v)
+	}
+	return out
+}
+
 func renderHPSS(ctx *Context, opts RenderOptions) (*image.RGBA, error) {
 	gap := 4
 	half := (opts.Height - gap) / 2
 	if half <= 0 {
 		return nil, fmt.Errorf("invalid output size")
 	}
-	harm, perc := dsp.HPSS(&ctx.Spec, 9, 9)
+	// Keep the frequency median the same width in Hz when frames are padded.
+	freqWidth := 9
+	if ctx.Spec.WindowSize > 0 && ctx.Spec.FFTSize > ctx.Spec.WindowSize {
+		freqWidth = 9 * ctx.Spec.FFTSize / ctx.Spec.WindowSize
+	}
+	harm, perc := dsp.HPSS(&ctx.Spec, 9, freqWidth)
 	hMin, hMax := percentileRange(harm.Values, 0.05, 0.98)
 	top, err := render.Heatmap(&harm, render.HeatmapOptions{
 		Width:    opts.Width,
@@ -306,6 +565,16 @@ func sampleValues(values []float64, maxSamples int) []float64 {
 	return out
 }
 
+func aboveFloor(values []float64, floor float64) []float64 {
+	out := make([]float64, 0, len(values))
+	for _, v := range values {
+		if v > floor {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func clampMax(values []float64, maxVal float64) []float64 {
 	if len(values) == 0 {
 		return values
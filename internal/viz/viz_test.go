@@ -35,7 +35,7 @@ func TestRenderAllKinds(t *testing.T) {
 		Height:  80,
 		Palette: colorRGBA,
 	}
-	kinds := []Kind{Spectrogram, Mel, Chroma, MFCC, HPSS, SelfSim, Loudness, Tempogram, Flux}
+	kinds := []Kind{Spectrogram, Mel, Chroma, MFCC, HPSS, SelfSim, Loudness, Tempogram, Flux, CQT, Reassigned}
 	for _, kind := range kinds {
 		img, err := Render(kind, ctx, opts)
 		if err != nil {
@@ -47,6 +47,96 @@ func TestRenderAllKinds(t *testing.T) {
 	}
 }
 
+func TestComputeCQTSnapsToNotes(t *testing.T) {
+	ctx := NewContext(testSamples(), 44100, 512, 128)
+	cqt := computeCQT(ctx, RenderOptions{MinFreq: 450, MaxFreq: 1000, BinsPerOctave: 24})
+	if math.Abs(cqt.MIDI(0)-69) > 1e-9 || cqt.Height != 29 || cqt.HopSize != 128 {
+		t.Fatalf("unexpected CQT layout: MIDI %v, %d bins, hop %d", cqt.MIDI(0), cqt.Height, cqt.HopSize)
+	}
+	if math.Abs(cqt.MIDI(2)-70) > 1e-9 {
+		t.Fatalf("expected a semitone per two bins, got MIDI %v", cqt.MIDI(2))
+	}
+}
+
+func TestRenderMFCCDeltas(t *testing.T) {
+	ctx := NewContext(testSamples(), 44100, 512, 128)
+	img, err := Render(MFCC, ctx, RenderOptions{
+		Width:   60,
+		Height:  80,
+		Palette: colorRGBA,
+		MFCC:    dsp.MFCCOptions{Ortho: true, Lifter: 22, CMVN: dsp.CMVNMeanVar, Deltas: 2},
+	})
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	// Blocks of 24, 24 and 24 rows with black 4-row gaps between them.
+	for _, y := range []int{24, 27, 52, 55} {
+		if c := img.RGBAAt(10, y); c.R != 0 || c.G != 0 || c.B != 0 {
+			t.Fatalf("expected gap at row %d, got %v", y, c)
+		}
+	}
+	if _, err := Render(MFCC, ctx, RenderOptions{Width: 60, Height: 8, Palette: colorRGBA, MFCC: dsp.MFCCOptions{Deltas: 2}}); err == nil {
+		t.Fatalf("expected size error")
+	}
+}
+
+func TestComputeChromaModes(t *testing.T) {
+	ctx := NewContext(testSamples(), 44100, 512, 128)
+	for _, name := range []string{"", "stft", "Tuned", "cqt", "cens"} {
+		mode, err := ParseChromaMode(name)
+		if err != nil {
+			t.Fatalf("ParseChromaMode %q: %v", name, err)
+		}
+		chroma := ctx.Chroma(mode)
+		if chroma.Height != 12 || chroma.Width == 0 {
+			t.Fatalf("%s: unexpected size %dx%d", mode, chroma.Width, chroma.Height)
+		}
+		if mode == ChromaCENS && (chroma.Min < 0 || chroma.Max > 1) {
+			t.Fatalf("cens: values span %v..%v", chroma.Min, chroma.Max)
+		}
+		if _, err := Render(SelfSim, ctx, RenderOptions{Width: 40, Height: 40, Palette: colorRGBA, ChromaMode: mode}); err != nil {
+			t.Fatalf("Render selfsim %s: %v", mode, err)
+		}
+	}
+	if _, err := ParseChromaMode("nope"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
+
+func TestRenderChordLabels(t *testing.T) {
+	// One second of A minor.
+	samples := make([]float64, 44100)
+	for _, note := range []float64{57, 60, 64} {
+		freq := dsp.MIDIToHz(note)
+		for i := range samples {
+			samples[i] += 0.2 * math.Sin(2*math.Pi*freq*float64(i)/44100)
+		}
+	}
+	ctx := NewContext(samples, 44100, 2048, 512)
+	harmony := ctx.Harmony(ChromaCENS)
+	if harmony.Key.String() != "A minor" || len(harmony.Chords) != 1 || harmony.Chords[0].Chord.String() != "Am" {
+		t.Fatalf("unexpected harmony: %s, %v", harmony.Key, harmony.Chords)
+	}
+	opts := RenderOptions{Width: 100, Height: 60, Palette: colorRGBA}
+	plain, err := Render(Chroma, ctx, opts)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	opts.ChordLabels = true
+	labeled, err := Render(Chroma, ctx, opts)
+	if err != nil {
+		t.Fatalf("Render labels: %v", err)
+	}
+	// A tick down the left edge and the label beside it.
+	white := color.RGBA{255, 255, 255, 255}
+	if labeled.RGBAAt(0, 50) != white || labeled.RGBAAt(3, 1) != white {
+		t.Fatalf("expected tick and label, got %v and %v", labeled.RGBAAt(0, 50), labeled.RGBAAt(3, 1))
+	}
+	if labeled.RGBAAt(50, 50) != plain.RGBAAt(50, 50) {
+		t.Fatalf("labels should leave the rest of the panel alone")
+	}
+}
+
 func TestKindsHelp(t *testing.T) {
 	if KindsHelp() == "" {
 		t.Fatalf("expected help text")